@@ -0,0 +1,3 @@
+module github.com/goghcrow/go-parsec/cmd
+
+go 1.19
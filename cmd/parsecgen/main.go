@@ -0,0 +1,73 @@
+// parsecgen 读取 EBNF 文法, 生成使用 parsec 的 Go 解析器代码
+//
+//	parsecgen -pkg calc -o calc_gen.go calc.ebnf
+//
+// 文法语法见 grammar.File
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goghcrow/go-parsec/grammar"
+)
+
+func main() {
+	pkg := flag.String("pkg", "", "package name of generated code, default is the output directory name")
+	out := flag.String("o", "", "output file, default is stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: parsecgen [-pkg name] [-o file] grammar.ebnf\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *pkg, *out); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(in, pkg, out string) error {
+	src, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	if pkg == "" {
+		pkg = defaultPkg(out)
+	}
+	code, err := generate(string(src), pkg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(out, code, 0644)
+}
+
+func generate(src, pkg string) ([]byte, error) {
+	f, err := grammar.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return grammar.Codegen(f, pkg)
+}
+
+func defaultPkg(out string) string {
+	dir, err := filepath.Abs(filepath.Dir(out))
+	if err != nil {
+		return "main"
+	}
+	name := strings.ReplaceAll(filepath.Base(dir), "-", "_")
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		return "main"
+	}
+	return name
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// 生成的代码需要与 example/calcgen 中提交的版本一致
+func TestGenerateCalc(t *testing.T) {
+	src, err := os.ReadFile("../../example/calcgen/calc.ebnf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := os.ReadFile("../../example/calcgen/calc_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := generate(string(src), "calcgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != string(expect) {
+		t.Errorf("calc_gen.go is out of date, run go generate in example/calcgen")
+	}
+}
@@ -8,17 +8,23 @@ import (
 	. "github.com/goghcrow/go-parsec/parsec"
 )
 
-func Calc(s string) float64 { return calculator(s) }
+func Calc(s string) float64 { return must(calculator(s)) }
 
-func Show(s string) string { return printer(s) }
+func Show(s string) string { return must(printer(s)) }
+
+// Eval 同 Calc, 返回词法, 语法错误与超出 float64 范围的数字
+func Eval(s string) (float64, error) { return calculator(s) }
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
 
 var calculator = BuildParser[float64](
-	func(s string) float64 {
-		num, err := strconv.ParseFloat(s, 64)
-		if err != nil {
-			panic(err)
-		}
-		return num
+	func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
 	},
 	func(op Op, a float64) float64 {
 		switch op {
@@ -46,7 +52,7 @@ var calculator = BuildParser[float64](
 	},
 )
 var printer = BuildParser[string](
-	func(s string) string { return s },
+	func(s string) (string, error) { return s, nil },
 	func(op Op, a string) string { return fmt.Sprintf("(%s %s)", op, a) },
 	func(op Op, l string, r string) string { return fmt.Sprintf("(%s %s %s)", op, l, r) },
 )
@@ -80,80 +86,104 @@ func (k TokenKind) String() string {
 type Op = string
 
 func BuildParser[Val any](
-	val func(string) Val,
+	val func(string) (Val, error),
 	unary func(Op, Val) Val,
 	binary func(Op, Val, Val) Val,
-) func(s string) Val {
-	lex := lexer.BuildLexer(func(lex *lexer.Lexicon[TokenKind]) {
-		lex.Regex(Number, `\d+(\.\d+)?`)
-		lex.Oper(Add, "+")
-		lex.Oper(Sub, "-")
-		lex.Oper(Mul, "*")
-		lex.Oper(Div, "/")
-		lex.Str(LParen, "(")
-		lex.Str(RParen, ")")
-		lex.Regex(Space, `\s+`).Skip()
-	})
-
-	strOf := func(toMatch string) Parser[TokenKind, Token[TokenKind]] {
-		return Str[TokenKind](toMatch)
-	}
+) func(s string) (Val, error) {
+	g := NewGrammar[TokenKind]().Import(Rules(val, unary, binary))
+	return Compile[Val](Lexicon(), g, "EXP")
+}
 
-	applyNum := func(v Token[TokenKind]) Val { return val(v.Lexeme()) }
-	applyUnary := func(v Cons[Token[TokenKind], Val]) Val {
-		return unary(v.Car.Lexeme(), v.Cdr)
-	}
-	applyBinary := func(a Val, b Cons[Token[TokenKind], Val]) Val {
-		return binary(b.Car.Lexeme(), a, b.Cdr)
+// Lexicon calc 的词法, 方言通过 With 在其基础上添加规则
+func Lexicon() lexer.Lexicon[TokenKind] {
+	lex := lexer.NewLexicon[TokenKind]()
+	lex.Regex(Number, `\d+(\.\d+)?`)
+	lex.Oper(Add, "+")
+	lex.Oper(Sub, "-")
+	lex.Oper(Mul, "*")
+	lex.Oper(Div, "/")
+	lex.Str(LParen, "(")
+	lex.Str(RParen, ")")
+	lex.Regex(Space, `\s+`).Skip()
+	return lex
+}
+
+// Rules calc 的文法, 定义规则 TERM, FACTOR, EXP;
+// 方言导入之后通过 Extend, Override 修改规则, e.g. Extend(g, "TERM", abs)
+func Rules[Val any](
+	val func(string) (Val, error),
+	unary func(Op, Val) Val,
+	binary func(Op, Val, Val) Val,
+) Module[TokenKind] {
+	return func(g *Grammar[TokenKind]) {
+		strOf := func(toMatch string) Parser[TokenKind, Token[TokenKind]] {
+			return Str[TokenKind](toMatch)
+		}
+
+		applyNum := func(v Token[TokenKind]) (Val, error) { return val(v.Lexeme()) }
+		applyUnary := func(v Cons[Token[TokenKind], Val]) Val {
+			return unary(v.Car.Lexeme(), v.Cdr)
+		}
+		applyBinary := func(a Val, b Cons[Token[TokenKind], Val]) Val {
+			return binary(b.Car.Lexeme(), a, b.Cdr)
+		}
+
+		// TERM
+		//  	= NUMBER
+		//  	= ('+' | '-') TERM
+		//  	= '(' EXP ')'
+		// FACTOR
+		//  	= TERM
+		//  	= FACTOR ('*' | '/') TERM
+		// EXP
+		//  	= FACTOR
+		//  	= EXP ('+' | '-') FACTOR
+
+		TERM := Define[TokenKind, Val](g, "TERM")
+		FACTOR := Define[TokenKind, Val](g, "FACTOR")
+		EXP := Define[TokenKind, Val](g, "EXP")
+
+		term := TERM.Parser()
+		factor := FACTOR.Parser()
+		exp := EXP.Parser()
+
+		sign := Seq2(AltSc(strOf("+"), strOf("-")), term)
+		TERM.SetPattern("TERM", AltSc(
+			ApplyE(Tok(Number), applyNum),
+			Apply(sign, applyUnary),
+			KMid(strOf("("), exp, strOf(")")),
+		))
+		FACTOR.SetPattern("FACTOR", LRecSc(
+			term,
+			Seq2(AltSc(strOf("*"), strOf("/")), term),
+			applyBinary,
+		))
+		EXP.SetPattern("EXP", LRecSc(
+			factor,
+			Seq2(AltSc(strOf("+"), strOf("-")), factor),
+			applyBinary,
+		))
 	}
+}
 
-	// TERM
-	//  	= NUMBER
-	//  	= ('+' | '-') TERM
-	//  	= '(' EXP ')'
-	// FACTOR
-	//  	= TERM
-	//  	= FACTOR ('*' | '/') TERM
-	// EXP
-	//  	= FACTOR
-	//  	= EXP ('+' | '-') FACTOR
-
-	TERM := NewRule[TokenKind, Val]()
-	FACTOR := NewRule[TokenKind, Val]()
-	EXP := NewRule[TokenKind, Val]()
-
-	term := TERM.Parser()
-	factor := FACTOR.Parser()
-	exp := EXP.Parser()
-
-	sign := Seq2(AltSc(strOf("+"), strOf("-")), term)
-	TERM.Pattern = AltSc(
-		Apply(Tok(Number), applyNum),
-		Apply(sign, applyUnary),
-		KMid(strOf("("), exp, strOf(")")),
-	)
-	FACTOR.Pattern = LRecSc(
-		term,
-		Seq2(AltSc(strOf("*"), strOf("/")), term),
-		applyBinary,
-	)
-	EXP.Pattern = LRecSc(
-		factor,
-		Seq2(AltSc(strOf("+"), strOf("-")), factor),
-		applyBinary,
-	)
-
-	return func(s string) Val {
-		xs := lex.MustLex(s)
+// Compile 构建 g, 返回从 start 规则开始解析的函数, g 中的规则可以来自 calc 或者方言
+func Compile[Val any](lex lexer.Lexicon[TokenKind], g *Grammar[TokenKind], start string) func(s string) (Val, error) {
+	g.MustBuild()
+	rule, ok := Lookup[TokenKind, Val](g, start)
+	if !ok {
+		panic(fmt.Sprintf("start rule %s not found", start))
+	}
+	l := lexer.NewLexer(lex)
+	return func(s string) (Val, error) {
+		xs, err := l.Lex(s)
+		if err != nil {
+			return *new(Val), err
+		}
 		toks := make([]Token[TokenKind], len(xs))
 		for i, t := range xs {
 			toks[i] = t
 		}
-		out := EXP.Parse(toks)
-		v, err := ExpectSingleResult(ExpectEOF(out))
-		if err != nil {
-			panic(err)
-		}
-		return v
+		out := rule.Parse(toks)
+		return ExpectSingleResult(ExpectEOF(out))
 	}
 }
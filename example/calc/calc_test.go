@@ -1,7 +1,14 @@
 package calc
 
 import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/goghcrow/go-parsec/lexer"
+	. "github.com/goghcrow/go-parsec/parsec"
 )
 
 func TestRec(t *testing.T) {
@@ -34,3 +41,115 @@ func TestRec(t *testing.T) {
 		})
 	}
 }
+
+func TestEvalError(t *testing.T) {
+	big := strings.Repeat("9", 400)
+	for _, tt := range []struct {
+		input string
+		error string
+	}{
+		{"1 + " + big, `strconv.ParseFloat: parsing "` + big + `": value out of range in pos 5-405 line 1 col 5`},
+		{"1 +", "Nothing to consume expect `number` in end of input"},
+		{"1 % 2", "syntax error: nothing token matched in pos 3-4 line 1 col 3"},
+	} {
+		t.Run(tt.input[:3], func(t *testing.T) {
+			_, err := Eval(tt.input)
+			if err == nil || err.Error() != tt.error {
+				t.Errorf("expect %s actual %v", tt.error, err)
+			}
+		})
+	}
+
+	_, err := Eval("1 + " + big)
+	if !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("expect %v actual %v", strconv.ErrRange, err)
+	}
+}
+
+// 方言的 token
+const (
+	Bar TokenKind = Space + iota + 1
+	Mod
+)
+
+func TestDialect(t *testing.T) {
+	num := func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
+	unary := func(op Op, a float64) float64 {
+		if op == "-" {
+			return -a
+		}
+		return a
+	}
+	binary := func(op Op, l float64, r float64) float64 {
+		switch op {
+		case "+":
+			return l + r
+		case "-":
+			return l - r
+		case "*":
+			return l * r
+		case "/":
+			return l / r
+		default:
+			return math.Mod(l, r)
+		}
+	}
+	base := Lexicon()
+	lex := base.With(func(lex *lexer.Lexicon[TokenKind]) {
+		lex.Str(Bar, "|")
+		lex.Str(Mod, "%")
+	})
+
+	// 为 TERM 添加 |EXP|, 替换 FACTOR 以支持 %, 隐藏内部规则
+	g := NewGrammar[TokenKind]().Import(Rules(num, unary, binary))
+	term := Ref[TokenKind, float64](g, "TERM").Parser()
+	exp := Ref[TokenKind, float64](g, "EXP").Parser()
+	Extend(g, "TERM", Apply(KMid(Str[TokenKind]("|"), exp, Str[TokenKind]("|")), math.Abs))
+	Override(g, "FACTOR", LRecSc(
+		term,
+		Seq2(AltSc(Str[TokenKind]("*"), Str[TokenKind]("/"), Str[TokenKind]("%")), term),
+		func(a float64, b Cons[Token[TokenKind], float64]) float64 { return binary(b.Car.Lexeme(), a, b.Cdr) },
+	))
+	g.Hide("TERM", "FACTOR")
+	dialect := Compile[float64](lex, g, "EXP")
+
+	for _, tt := range []struct {
+		input  string
+		expect float64
+	}{
+		{"|1 - 3|", 2},
+		{"7 % 4 * 2", 6},
+		// 原有规则引用的 TERM, FACTOR 均为修改后的规则
+		{"-(|-2| + 10 % 3)", -3},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			v, err := dialect(tt.input)
+			if err != nil || v != tt.expect {
+				t.Errorf("expect %f actual %f %v", tt.expect, v, err)
+			}
+		})
+	}
+
+	if actual := strings.Join(g.Names(), " "); actual != "EXP" {
+		t.Errorf("expect EXP actual %s", actual)
+	}
+	// 基础文法不受影响
+	if _, err := Eval("|1|"); err == nil {
+		t.Errorf("expect error")
+	}
+}
+
+func TestDialectError(t *testing.T) {
+	g := NewGrammar[TokenKind]().Import(Rules(
+		func(s string) (string, error) { return s, nil },
+		func(Op, string) string { return "" },
+		func(Op, string, string) string { return "" },
+	))
+	Extend(g, "STMT", Tok(Number))
+	Override(g, "EXP", Tok(Number))
+	g.Hide("DECL")
+	expect := "Grammar build failed: cannot extend undefined rule STMT, rule EXP is defined with a different type, cannot hide undefined rule DECL."
+	if err := g.Build(); err == nil || err.Error() != expect {
+		t.Errorf("expect %s actual %v", expect, err)
+	}
+}
@@ -0,0 +1,260 @@
+// Code generated by parsecgen. DO NOT EDIT.
+
+package calcgen
+
+import (
+	"github.com/goghcrow/go-parsec/lexer"
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+type TokenKind int
+
+const (
+	SPACE TokenKind = iota + 1
+	NUMBER
+	PLUS        // "+"
+	MINUS       // "-"
+	STAR        // "*"
+	SLASH       // "/"
+	LEFT_PAREN  // "("
+	RIGHT_PAREN // ")"
+)
+
+func (k TokenKind) String() string {
+	return map[TokenKind]string{
+		SPACE:       "SPACE",
+		NUMBER:      "NUMBER",
+		PLUS:        "+",
+		MINUS:       "-",
+		STAR:        "*",
+		SLASH:       "/",
+		LEFT_PAREN:  "(",
+		RIGHT_PAREN: ")",
+	}[k]
+}
+
+var Lexer = lexer.BuildLexer(func(lex *lexer.Lexicon[TokenKind]) {
+	lex.Regex(SPACE, `\s+`).Skip()
+	lex.Str(PLUS, "+")
+	lex.Str(MINUS, "-")
+	lex.Str(STAR, "*")
+	lex.Str(SLASH, "/")
+	lex.Str(LEFT_PAREN, "(")
+	lex.Str(RIGHT_PAREN, ")")
+	lex.Regex(NUMBER, `\d+(\.\d+)?`)
+})
+
+// Lex 对 input 分词
+func Lex(input string) ([]parsec.Token[TokenKind], error) {
+	xs, err := lexer.NewLexer(Lexer.Lexicon).Lex(input)
+	if err != nil {
+		return nil, err
+	}
+	toks := make([]parsec.Token[TokenKind], len(xs))
+	for i, t := range xs {
+		toks[i] = t
+	}
+	return toks, nil
+}
+
+// Node AST 节点
+type Node interface {
+	// Tokens 按源码顺序返回节点覆盖的 token
+	Tokens() []parsec.Token[TokenKind]
+}
+
+var (
+	ExpRule    = parsec.NewRule[TokenKind, *Exp]()
+	FactorRule = parsec.NewRule[TokenKind, *Factor]()
+	TermRule   = parsec.NewRule[TokenKind, Term]()
+)
+
+func init() {
+	ExpRule.SetPattern("EXP", parsec.Apply(parsec.Seq2(FactorRule.Parser(), parsec.RepSc(parsec.Apply(parsec.Seq2(parsec.Alt(
+		parsec.Tok(PLUS),
+		parsec.Tok(MINUS),
+	), FactorRule.Parser()), func(v parsec.Cons[parsec.Token[TokenKind], *Factor]) *ExpItem1 {
+		return &ExpItem1{Choice: v.Car, Factor: v.Cdr}
+	}))), func(v parsec.Cons[*Factor, []*ExpItem1]) *Exp {
+		return &Exp{Factor: v.Car, Items: v.Cdr}
+	}))
+	FactorRule.SetPattern("FACTOR", parsec.Apply(parsec.Seq2(TermRule.Parser(), parsec.RepSc(parsec.Apply(parsec.Seq2(parsec.Alt(
+		parsec.Tok(STAR),
+		parsec.Tok(SLASH),
+	), TermRule.Parser()), func(v parsec.Cons[parsec.Token[TokenKind], Term]) *FactorItem1 {
+		return &FactorItem1{Choice: v.Car, Term: v.Cdr}
+	}))), func(v parsec.Cons[Term, []*FactorItem1]) *Factor {
+		return &Factor{Term: v.Car, Items: v.Cdr}
+	}))
+	TermRule.SetPattern("TERM", parsec.AltSc(
+		parsec.Apply(parsec.Apply(parsec.Tok(NUMBER), func(v parsec.Token[TokenKind]) *TermNum {
+			return &TermNum{Number: v}
+		}), func(v *TermNum) Term { return v }),
+		parsec.Apply(parsec.Apply(parsec.Seq2(parsec.Alt(
+			parsec.Tok(PLUS),
+			parsec.Tok(MINUS),
+		), TermRule.Parser()), func(v parsec.Cons[parsec.Token[TokenKind], Term]) *TermUnary {
+			return &TermUnary{Choice: v.Car, Term: v.Cdr}
+		}), func(v *TermUnary) Term { return v }),
+		parsec.Apply(parsec.Apply(parsec.Seq2(parsec.Tok(LEFT_PAREN), parsec.Seq2(ExpRule.Parser(), parsec.Tok(RIGHT_PAREN))), func(v parsec.Cons[parsec.Token[TokenKind], parsec.Cons[*Exp, parsec.Token[TokenKind]]]) *TermParen {
+			return &TermParen{LeftParen: v.Car, Exp: v.Cdr.Car, RightParen: v.Cdr.Cdr}
+		}), func(v *TermParen) Term { return v }),
+	))
+}
+
+// Parse 从 EXP 开始解析 input, 必须消费所有 token 且结果唯一
+func Parse(input string) (*Exp, error) {
+	toks, err := Lex(input)
+	if err != nil {
+		return nil, err
+	}
+	return parsec.ExpectSingleResult(parsec.ExpectEOF(ExpRule.Parse(toks)))
+}
+
+type ExpItem1 struct {
+	Choice parsec.Token[TokenKind]
+	Factor *Factor
+}
+
+func (n *ExpItem1) Tokens() []parsec.Token[TokenKind] {
+	if n == nil {
+		return nil
+	}
+	var toks []parsec.Token[TokenKind]
+	if n.Choice != nil {
+		toks = append(toks, n.Choice)
+	}
+	if n.Factor != nil {
+		toks = append(toks, n.Factor.Tokens()...)
+	}
+	return toks
+}
+
+type Exp struct {
+	Factor *Factor
+	Items  []*ExpItem1
+}
+
+func (n *Exp) Tokens() []parsec.Token[TokenKind] {
+	if n == nil {
+		return nil
+	}
+	var toks []parsec.Token[TokenKind]
+	if n.Factor != nil {
+		toks = append(toks, n.Factor.Tokens()...)
+	}
+	for _, x0 := range n.Items {
+		if x0 != nil {
+			toks = append(toks, x0.Tokens()...)
+		}
+	}
+	return toks
+}
+
+type FactorItem1 struct {
+	Choice parsec.Token[TokenKind]
+	Term   Term
+}
+
+func (n *FactorItem1) Tokens() []parsec.Token[TokenKind] {
+	if n == nil {
+		return nil
+	}
+	var toks []parsec.Token[TokenKind]
+	if n.Choice != nil {
+		toks = append(toks, n.Choice)
+	}
+	if n.Term != nil {
+		toks = append(toks, n.Term.Tokens()...)
+	}
+	return toks
+}
+
+type Factor struct {
+	Term  Term
+	Items []*FactorItem1
+}
+
+func (n *Factor) Tokens() []parsec.Token[TokenKind] {
+	if n == nil {
+		return nil
+	}
+	var toks []parsec.Token[TokenKind]
+	if n.Term != nil {
+		toks = append(toks, n.Term.Tokens()...)
+	}
+	for _, x0 := range n.Items {
+		if x0 != nil {
+			toks = append(toks, x0.Tokens()...)
+		}
+	}
+	return toks
+}
+
+type Term interface {
+	Node
+	isTerm()
+}
+
+type TermNum struct {
+	Number parsec.Token[TokenKind]
+}
+
+func (n *TermNum) Tokens() []parsec.Token[TokenKind] {
+	if n == nil {
+		return nil
+	}
+	var toks []parsec.Token[TokenKind]
+	if n.Number != nil {
+		toks = append(toks, n.Number)
+	}
+	return toks
+}
+
+func (*TermNum) isTerm() {}
+
+type TermUnary struct {
+	Choice parsec.Token[TokenKind]
+	Term   Term
+}
+
+func (n *TermUnary) Tokens() []parsec.Token[TokenKind] {
+	if n == nil {
+		return nil
+	}
+	var toks []parsec.Token[TokenKind]
+	if n.Choice != nil {
+		toks = append(toks, n.Choice)
+	}
+	if n.Term != nil {
+		toks = append(toks, n.Term.Tokens()...)
+	}
+	return toks
+}
+
+func (*TermUnary) isTerm() {}
+
+type TermParen struct {
+	LeftParen  parsec.Token[TokenKind]
+	Exp        *Exp
+	RightParen parsec.Token[TokenKind]
+}
+
+func (n *TermParen) Tokens() []parsec.Token[TokenKind] {
+	if n == nil {
+		return nil
+	}
+	var toks []parsec.Token[TokenKind]
+	if n.LeftParen != nil {
+		toks = append(toks, n.LeftParen)
+	}
+	if n.Exp != nil {
+		toks = append(toks, n.Exp.Tokens()...)
+	}
+	if n.RightParen != nil {
+		toks = append(toks, n.RightParen)
+	}
+	return toks
+}
+
+func (*TermParen) isTerm() {}
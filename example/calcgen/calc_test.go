@@ -0,0 +1,57 @@
+package calcgen
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCorpus(t *testing.T) {
+	src, err := os.ReadFile("calc.corpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(src)), "\n") {
+		t.Run(line, func(t *testing.T) {
+			n, err := Parse(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			toks, _ := Lex(line)
+			expect := make([]string, len(toks))
+			for i, tok := range toks {
+				expect[i] = tok.Lexeme()
+			}
+			actual := make([]string, 0, len(toks))
+			for _, tok := range n.Tokens() {
+				actual = append(actual, tok.Lexeme())
+			}
+			if strings.Join(expect, " ") != strings.Join(actual, " ") {
+				t.Errorf("expect %v actual %v", expect, actual)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		expect float64
+	}{
+		{"1 + 2 * 3 + 4", 11},
+		{"(1 + 2) * (3 + 4)", 21},
+		{"1.2--3.4", 4.6},
+	} {
+		n, err := Parse(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := Eval(n); v != tt.expect {
+			t.Errorf("%s: expect %v actual %v", tt.input, tt.expect, v)
+		}
+	}
+
+	if _, err := Parse("1 + (2"); err == nil {
+		t.Errorf("expect error")
+	}
+}
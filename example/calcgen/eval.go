@@ -0,0 +1,45 @@
+package calcgen
+
+import "strconv"
+
+// Eval 对生成的 AST 求值
+func Eval(n *Exp) float64 {
+	v := evalFactor(n.Factor)
+	for _, x := range n.Items {
+		if x.Choice.Lexeme() == "+" {
+			v += evalFactor(x.Factor)
+		} else {
+			v -= evalFactor(x.Factor)
+		}
+	}
+	return v
+}
+
+func evalFactor(n *Factor) float64 {
+	v := evalTerm(n.Term)
+	for _, x := range n.Items {
+		if x.Choice.Lexeme() == "*" {
+			v *= evalTerm(x.Term)
+		} else {
+			v /= evalTerm(x.Term)
+		}
+	}
+	return v
+}
+
+func evalTerm(n Term) float64 {
+	switch n := n.(type) {
+	case *TermNum:
+		v, _ := strconv.ParseFloat(n.Number.Lexeme(), 64)
+		return v
+	case *TermUnary:
+		if n.Choice.Lexeme() == "-" {
+			return -evalTerm(n.Term)
+		}
+		return evalTerm(n.Term)
+	case *TermParen:
+		return Eval(n.Exp)
+	default:
+		panic("unreached")
+	}
+}
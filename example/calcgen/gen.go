@@ -0,0 +1,3 @@
+package calcgen
+
+//go:generate go run github.com/goghcrow/go-parsec/cmd/parsecgen -pkg calcgen -o calc_gen.go calc.ebnf
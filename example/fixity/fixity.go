@@ -0,0 +1,167 @@
+package fixity
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/goghcrow/go-parsec/lexer"
+	. "github.com/goghcrow/go-parsec/parsec"
+)
+
+// 用户自定义操作符: infixl 6 <+>; 声明之后的语句(块内声明到块结束)可以使用该操作符
+// e.g. Parse("infixl 6 <+>; 1 <+> 2 * 3;") == ["(<+> 1 (* 2 3))"]
+
+type TokenKind int
+
+const (
+	Number TokenKind = iota + 1
+	Infixl
+	Infixr
+	Infix
+	Ident
+	Oper
+	LParen
+	RParen
+	LBrace
+	RBrace
+	Semi
+	Space
+)
+
+func (k TokenKind) String() string {
+	return map[TokenKind]string{
+		Number: "number",
+		Infixl: "infixl",
+		Infixr: "infixr",
+		Infix:  "infix",
+		Ident:  "ident",
+		Oper:   "operator",
+		LParen: "(",
+		RParen: ")",
+		LBrace: "{",
+		RBrace: "}",
+		Semi:   ";",
+		Space:  "<space>",
+	}[k]
+}
+
+type Operator = lexer.Operator[TokenKind]
+
+var builtinOpers = []Operator{
+	{TokenKind: Oper, Lexeme: "+", BP: 6, Fixity: lexer.INFIX_L},
+	{TokenKind: Oper, Lexeme: "-", BP: 6, Fixity: lexer.INFIX_L},
+	{TokenKind: Oper, Lexeme: "*", BP: 7, Fixity: lexer.INFIX_L},
+	{TokenKind: Oper, Lexeme: "/", BP: 7, Fixity: lexer.INFIX_L},
+}
+
+// Parse 返回每个表达式语句的 S-expression
+func Parse(s string) ([]string, error) { return parser(s) }
+
+var parser = buildParser()
+
+func buildParser() func(string) ([]string, error) {
+	lex := lexer.BuildLexer(func(lex *lexer.Lexicon[TokenKind]) {
+		lex.Regex(Space, `\s+`).Skip()
+		lex.Regex(Number, `\d+(\.\d+)?`)
+		lex.Keyword(Infixl, "infixl")
+		lex.Keyword(Infixr, "infixr")
+		lex.Keyword(Infix, "infix")
+		lex.Regex(Ident, lexer.RegIdent)
+		lex.Str(LParen, "(")
+		lex.Str(RParen, ")")
+		lex.Str(LBrace, "{")
+		lex.Str(RBrace, "}")
+		lex.Str(Semi, ";")
+		lex.Regex(Oper, lexer.RegOper)
+	})
+
+	// 操作符扩展点, 后声明的操作符在前, Greedy 下遮蔽之前同名的声明
+	operOf := func(o Operator) Parser[TokenKind, Operator] {
+		return Apply(Str[TokenKind](o.Lexeme), func(Token[TokenKind]) Operator { return o })
+	}
+	var builtins []Parser[TokenKind, Operator]
+	for _, o := range builtinOpers {
+		builtins = append(builtins, operOf(o))
+	}
+	opers := NewSyntax(builtins...)
+
+	// decl = ('infixl' | 'infixr' | 'infix') NUMBER OPER ';'
+	fixity := map[TokenKind]lexer.Fixity{Infixl: lexer.INFIX_L, Infixr: lexer.INFIX_R, Infix: lexer.INFIX_N}
+	decl := ApplyE(
+		KLeft(Seq3(AltSc(Tok(Infixl), Tok(Infixr), Tok(Infix)), Tok(Number), Tok(Oper)), Tok(Semi)),
+		func(v Cons[Token[TokenKind], Cons[Token[TokenKind], Token[TokenKind]]]) (Operator, error) {
+			bp, err := strconv.ParseFloat(v.Cdr.Car.Lexeme(), 32)
+			if err != nil {
+				return Operator{}, err
+			}
+			return Operator{TokenKind: Oper, Lexeme: v.Cdr.Cdr.Lexeme(), BP: lexer.BP(bp), Fixity: fixity[v.Car.Kind()]}, nil
+		},
+	)
+	declare := func(o Operator) Extension[TokenKind] { return opers.Add(operOf(o)) }
+
+	// term = NUMBER | IDENT | '(' expr ')'
+	// expr = term {OPER term}
+	expr := NewRule[TokenKind, string]()
+	lexeme := func(t Token[TokenKind]) string { return t.Lexeme() }
+	term := AltSc(
+		Apply(Tok(Number), lexeme),
+		Apply(Tok(Ident), lexeme),
+		KMid(Tok(LParen), expr.Parser(), Tok(RParen)),
+	)
+	expr.SetPattern("expr", ApplyE(Seq2(term, RepSc(Seq2(opers.Parser(), term))), func(v Cons[string, []Cons[Operator, string]]) (string, error) {
+		return fold(v.Car, v.Cdr)
+	}))
+
+	// prog = decl prog | expr ';' prog | '{' prog '}' prog | ε
+	prog := NewRule[TokenKind, []string]()
+	prepend := func(v Cons[string, []string]) []string { return append([]string{v.Car}, v.Cdr...) }
+	prog.SetPattern("prog", Alt(
+		Apply(Scoped(decl, declare, prog.Parser()), func(v Cons[Operator, []string]) []string { return v.Cdr }),
+		Apply(Seq2(KLeft(expr.Parser(), Tok(Semi)), prog.Parser()), prepend),
+		Apply(Seq2(KMid(Tok(LBrace), prog.Parser(), Tok(RBrace)), prog.Parser()), func(v Cons[[]string, []string]) []string {
+			return append(v.Car, v.Cdr...)
+		}),
+		Succ[TokenKind, []string](nil),
+	))
+	p := Greedy(prog.Parser())
+
+	return func(s string) ([]string, error) {
+		xs, err := lex.Lex(s)
+		if err != nil {
+			return nil, err
+		}
+		toks := make([]Token[TokenKind], len(xs))
+		for i, t := range xs {
+			toks[i] = t
+		}
+		return ExpectSingleResult(ExpectEOF(p.Parse(toks)))
+	}
+}
+
+// fold 按优先级与结合性组合 x0 op1 x1 op2 x2 ..., precedence climbing
+func fold(first string, rest []Cons[Operator, string]) (string, error) {
+	i := 0
+	var climb func(lhs string, min lexer.BP) (string, error)
+	climb = func(lhs string, min lexer.BP) (string, error) {
+		for i < len(rest) && rest[i].Car.BP >= min {
+			op, rhs := rest[i].Car, rest[i].Cdr
+			i++
+			for i < len(rest) {
+				next := rest[i].Car
+				if next.BP == op.BP && (op.Fixity != next.Fixity || op.Fixity == lexer.INFIX_N) {
+					return "", fmt.Errorf("cannot mix `%s` and `%s` of the same precedence", op.Lexeme, next.Lexeme)
+				}
+				if next.BP < op.BP || next.BP == op.BP && op.Fixity == lexer.INFIX_L {
+					break
+				}
+				var err error
+				if rhs, err = climb(rhs, next.BP); err != nil {
+					return "", err
+				}
+			}
+			lhs = fmt.Sprintf("(%s %s %s)", op.Lexeme, lhs, rhs)
+		}
+		return lhs, nil
+	}
+	return climb(first, 0)
+}
@@ -0,0 +1,38 @@
+package fixity
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		expect string
+	}{
+		{"1 + 2 * 3;", "[(+ 1 (* 2 3))]"},
+		{"infixl 6 <+>; 1 <+> 2 * 3 <+> 4;", "[(<+> (<+> 1 (* 2 3)) 4)]"},
+		{"infixr 8 ^; 2 ^ 3 ^ 4 * 5;", "[(* (^ 2 (^ 3 4)) 5)]"},
+		{"infixr 5 ++; a ++ b ++ c;", "[(++ a (++ b c))]"},
+		// 声明只作用于其后的语句
+		{"1; infixl 6 <+>; 1 <+> 2;", "[1 (<+> 1 2)]"},
+		{"1 <+> 2; infixl 6 <+>;", "Unable to consume token `<+>` expect `+` in pos 3-6 line 1 col 3"},
+		// 块内的声明作用到块结束
+		{"{ infixl 6 <+>; 1 <+> 2; } 3 <+> 4;", "Unable to consume token `<+>` expect `+` in pos 30-33 line 1 col 30"},
+		{"infixl 6 <+>; { 1 <+> 2; } 3 <+> 4;", "[(<+> 1 2) (<+> 3 4)]"},
+		// 遮蔽之前的声明
+		{"infixl 9 +; 1 * 2 + 3;", "[(* 1 (+ 2 3))]"},
+		{"infix 4 ==; 1 == 2 == 3;", "cannot mix `==` and `==` of the same precedence in pos 13-24 line 1 col 13"},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			xs, err := Parse(tt.input)
+			actual := fmt.Sprint(xs)
+			if err != nil {
+				actual = err.Error()
+			}
+			if actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+		})
+	}
+}
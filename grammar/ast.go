@@ -0,0 +1,324 @@
+package grammar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goghcrow/go-parsec/lexer"
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+// ----------------------------------------------------------------
+// AST
+// ----------------------------------------------------------------
+
+// File 语法文件
+//
+//	file  = { rule | token } ;
+//	token = ( "%token" | "%skip" ) IDENT "=" STRING ";" ;
+//	rule  = IDENT "=" expr ";" ;
+//	expr  = seq { ( "|" | "/" ) seq } ;
+//	seq   = { item } [ "@" IDENT ] ;
+//	item  = [ "&" | "!" ] atom [ "*" | "+" | "?" ] ;
+//	atom  = IDENT | STRING | "(" expr ")" | "[" expr "]" | "{" expr "}" ;
+//
+// `|` 返回所有分支结果, `/` 是 PEG 的有序选择, 同一层不能混用;
+// 重复与可选都是贪婪的; `// ...` 为行注释;
+// %token 声明词法规则(正则, 推荐用 `...` 书写), 供代码生成使用, Load 的终结符来自 lexicon
+type File struct {
+	Rules  []*Rule
+	Tokens []*TokenDef
+	rules  map[string]*Rule
+}
+
+type TokenDef struct {
+	lexer.Pos
+	Name    string
+	Pattern string
+	Skip    bool
+}
+
+type Rule struct {
+	lexer.Pos
+	Name string
+	Expr Expr
+}
+
+type Expr interface {
+	Loc() (idx, idxEnd, col, ln int)
+	String() string
+}
+
+type (
+	// Alt a | b, a / b
+	Alt struct {
+		lexer.Pos
+		Ordered bool
+		Alts    []Expr
+		mixed   *lexer.Pos // 混用 | 与 / 的位置
+	}
+	// Seq a b c @action
+	Seq struct {
+		lexer.Pos
+		Items  []Expr
+		Action string
+	}
+	// Rep {a}, a*, a+
+	Rep struct {
+		lexer.Pos
+		Min  int
+		Expr Expr
+	}
+	// Opt [a], a?
+	Opt struct {
+		lexer.Pos
+		Expr Expr
+	}
+	// Pred &a, !a
+	Pred struct {
+		lexer.Pos
+		Not  bool
+		Expr Expr
+	}
+	// Ref 规则引用或 TokenKind
+	Ref struct {
+		lexer.Pos
+		Name string
+	}
+	// Lit "literal"
+	Lit struct {
+		lexer.Pos
+		Text string
+	}
+)
+
+func (r *Rule) String() string { return fmt.Sprintf("%s = %s ;", r.Name, r.Expr) }
+func (t *TokenDef) String() string {
+	decl := "%token"
+	if t.Skip {
+		decl = "%skip"
+	}
+	return fmt.Sprintf("%s %s = `%s` ;", decl, t.Name, t.Pattern)
+}
+
+func (a *Alt) String() string {
+	sep := " | "
+	if a.Ordered {
+		sep = " / "
+	}
+	xs := make([]string, len(a.Alts))
+	for i, x := range a.Alts {
+		xs[i] = x.String()
+	}
+	return strings.Join(xs, sep)
+}
+func (s *Seq) String() string {
+	xs := make([]string, 0, len(s.Items)+1)
+	for _, x := range s.Items {
+		if _, ok := x.(*Alt); ok {
+			xs = append(xs, "("+x.String()+")")
+		} else {
+			xs = append(xs, x.String())
+		}
+	}
+	if s.Action != "" {
+		xs = append(xs, "@"+s.Action)
+	}
+	return strings.Join(xs, " ")
+}
+func (r *Rep) String() string {
+	if r.Min == 0 {
+		return "{ " + r.Expr.String() + " }"
+	}
+	return "(" + r.Expr.String() + ")+"
+}
+func (o *Opt) String() string { return "[ " + o.Expr.String() + " ]" }
+func (p *Pred) String() string {
+	if p.Not {
+		return "!(" + p.Expr.String() + ")"
+	}
+	return "&(" + p.Expr.String() + ")"
+}
+func (r *Ref) String() string { return r.Name }
+func (l *Lit) String() string { return strconv.Quote(l.Text) }
+
+// ----------------------------------------------------------------
+// Parse
+// ----------------------------------------------------------------
+
+// Parse 解析语法文件, 错误携带位置信息
+func Parse(src string) (*File, error) {
+	xs, err := metaLexer.Lex(src)
+	if err != nil {
+		return nil, err
+	}
+	toks := make([]parsec.Token[tokKind], len(xs))
+	for i, t := range xs {
+		toks[i] = t
+	}
+	return parsec.ExpectSingleResult(parsec.ExpectEOF(metaParser.Parse(toks)))
+}
+
+type tokKind int
+
+const (
+	tIdent tokKind = iota + 1
+	tString
+	tPunct
+	tSpace
+	tComment
+)
+
+func (k tokKind) String() string {
+	return map[tokKind]string{
+		tIdent:   "identifier",
+		tString:  "string",
+		tPunct:   "punctuation",
+		tSpace:   "<space>",
+		tComment: "<comment>",
+	}[k]
+}
+
+var metaLexer = lexer.BuildLexer(func(lex *lexer.Lexicon[tokKind]) {
+	lex.Regex(tSpace, `\s+`).Skip()
+	lex.Regex(tComment, `//[^\n]*`).Skip()
+	lex.Regex(tIdent, lexer.RegIdent)
+	lex.Regex(tString, `"(?:[^"\\\n]|\\.)*"`)
+	lex.Regex(tString, `'(?:[^'\\\n]|\\.)*'`)
+	lex.Regex(tString, "`[^`]*`")
+	lex.Str(tPunct, "%token")
+	lex.Str(tPunct, "%skip")
+	for _, p := range []string{"=", ";", "|", "/", "(", ")", "[", "]", "{", "}", "*", "+", "?", "@", "&", "!"} {
+		lex.Str(tPunct, p)
+	}
+})
+
+var metaParser = buildMetaParser()
+
+type tok = parsec.Token[tokKind]
+
+func posOf(t tok) lexer.Pos { return t.(*lexer.Token[tokKind]).Pos }
+
+func buildMetaParser() parsec.Parser[tokKind, *File] {
+	str := func(s string) parsec.Parser[tokKind, tok] { return parsec.Str[tokKind](s) }
+
+	EXPR := parsec.NewRule[tokKind, Expr]()
+	expr := EXPR.Parser()
+
+	ref := parsec.Apply(parsec.Tok(tIdent), func(t tok) Expr {
+		return &Ref{Pos: posOf(t), Name: t.Lexeme()}
+	})
+	lit := parsec.Apply(parsec.Tok(tString), func(t tok) Expr {
+		return &Lit{Pos: posOf(t), Text: unquote(t.Lexeme())}
+	})
+	group := parsec.KMid(str("("), expr, str(")"))
+	opt := parsec.Apply(parsec.Seq3(str("["), expr, str("]")), func(v parsec.Cons[tok, parsec.Cons[Expr, tok]]) Expr {
+		return &Opt{Pos: posOf(v.Car), Expr: v.Cdr.Car}
+	})
+	rep := parsec.Apply(parsec.Seq3(str("{"), expr, str("}")), func(v parsec.Cons[tok, parsec.Cons[Expr, tok]]) Expr {
+		return &Rep{Pos: posOf(v.Car), Expr: v.Cdr.Car}
+	})
+	atom := parsec.AltSc(ref, lit, group, opt, rep)
+
+	suffixed := parsec.Apply(parsec.Seq2(atom, parsec.OptSc(parsec.AltSc(str("*"), str("+"), str("?")))), func(v parsec.Cons[Expr, tok]) Expr {
+		if v.Cdr == nil {
+			return v.Car
+		}
+		pos := posOf(v.Cdr)
+		switch v.Cdr.Lexeme() {
+		case "*":
+			return &Rep{Pos: pos, Expr: v.Car}
+		case "+":
+			return &Rep{Pos: pos, Min: 1, Expr: v.Car}
+		default:
+			return &Opt{Pos: pos, Expr: v.Car}
+		}
+	})
+	item := parsec.Apply(parsec.Seq2(parsec.OptSc(parsec.AltSc(str("&"), str("!"))), suffixed), func(v parsec.Cons[tok, Expr]) Expr {
+		if v.Car == nil {
+			return v.Cdr
+		}
+		return &Pred{Pos: posOf(v.Car), Not: v.Car.Lexeme() == "!", Expr: v.Cdr}
+	})
+	seq := parsec.Apply(parsec.Seq2(parsec.RepSc(item), parsec.OptSc(parsec.KRight(str("@"), parsec.Tok(tIdent)))), func(v parsec.Cons[[]Expr, tok]) Expr {
+		s := &Seq{Items: v.Car}
+		if len(v.Car) != 0 {
+			idx, end, col, ln := v.Car[0].Loc()
+			s.Pos = lexer.Pos{Idx: idx, IdxEnd: end, Col: col, Line: ln}
+		}
+		if v.Cdr != nil {
+			s.Action = v.Cdr.Lexeme()
+			if len(v.Car) == 0 {
+				s.Pos = posOf(v.Cdr)
+			}
+		}
+		if len(s.Items) == 1 && s.Action == "" {
+			return s.Items[0]
+		}
+		return s
+	})
+	EXPR.SetPattern("expr", parsec.Apply(parsec.Seq2(seq, parsec.RepSc(parsec.Seq2(parsec.AltSc(str("|"), str("/")), seq))),
+		func(v parsec.Cons[Expr, []parsec.Cons[tok, Expr]]) Expr {
+			if len(v.Cdr) == 0 {
+				return v.Car
+			}
+			idx, end, col, ln := v.Car.Loc()
+			alt := &Alt{
+				Pos:     lexer.Pos{Idx: idx, IdxEnd: end, Col: col, Line: ln},
+				Ordered: v.Cdr[0].Car.Lexeme() == "/",
+				Alts:    []Expr{v.Car},
+			}
+			for _, x := range v.Cdr {
+				if (x.Car.Lexeme() == "/") != alt.Ordered && alt.mixed == nil {
+					pos := posOf(x.Car)
+					alt.mixed = &pos
+				}
+				alt.Alts = append(alt.Alts, x.Cdr)
+			}
+			return alt
+		},
+	))
+
+	rule := parsec.Apply(parsec.Seq4(parsec.Tok(tIdent), str("="), expr, str(";")), func(v parsec.Cons[tok, parsec.Cons[tok, parsec.Cons[Expr, tok]]]) any {
+		return &Rule{Pos: posOf(v.Car), Name: v.Car.Lexeme(), Expr: v.Cdr.Cdr.Car}
+	})
+	token := parsec.Apply(
+		parsec.Seq5(parsec.AltSc(str("%token"), str("%skip")), parsec.Tok(tIdent), str("="), parsec.Tok(tString), str(";")),
+		func(v parsec.Cons[tok, parsec.Cons[tok, parsec.Cons[tok, parsec.Cons[tok, tok]]]]) any {
+			return &TokenDef{
+				Pos:     posOf(v.Cdr.Car),
+				Name:    v.Cdr.Car.Lexeme(),
+				Pattern: unquote(v.Cdr.Cdr.Cdr.Car.Lexeme()),
+				Skip:    v.Car.Lexeme() == "%skip",
+			}
+		},
+	)
+	return parsec.Apply(parsec.RepSc(parsec.AltSc(rule, token)), func(decls []any) *File {
+		f := &File{}
+		for _, d := range decls {
+			switch d := d.(type) {
+			case *Rule:
+				f.Rules = append(f.Rules, d)
+			case *TokenDef:
+				f.Tokens = append(f.Tokens, d)
+			}
+		}
+		return f
+	})
+}
+
+// unquote 支持 "..." '...' `...` 三种字符串, 无法转义时保留原文(e.g. '\d+')
+func unquote(s string) string {
+	switch s[0] {
+	case '`':
+		return s[1 : len(s)-1]
+	case '\'':
+		s = `"` + strings.ReplaceAll(s[1:len(s)-1], `"`, `\"`) + `"`
+	}
+	text, err := strconv.Unquote(s)
+	if err != nil {
+		return s[1 : len(s)-1]
+	}
+	return text
+}
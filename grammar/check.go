@@ -0,0 +1,153 @@
+package grammar
+
+import (
+	"strings"
+
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+// ----------------------------------------------------------------
+// Check
+// ----------------------------------------------------------------
+
+// check 检查重复定义与左递归
+func (f *File) check() error {
+	if len(f.Rules) == 0 {
+		return errorf(parsec.EOFPos, "empty grammar")
+	}
+	defined := make(map[string]bool)
+	for _, t := range f.Tokens {
+		if defined[t.Name] {
+			return errorf(t.Pos, "duplicate token %s", t.Name)
+		}
+		defined[t.Name] = true
+	}
+	f.rules = make(map[string]*Rule)
+	for _, r := range f.Rules {
+		if defined[r.Name] {
+			return errorf(r.Pos, "duplicate rule %s", r.Name)
+		}
+		defined[r.Name] = true
+		f.rules[r.Name] = r
+	}
+	return f.checkLeftRecursion()
+}
+
+// checkLeftRecursion 组合子无法处理左递归, 加载时报错, 避免解析时死循环
+func (f *File) checkLeftRecursion() error {
+	nullable := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, r := range f.Rules {
+			if !nullable[r.Name] && f.nullable(r.Expr, nullable) {
+				nullable[r.Name] = true
+				changed = true
+			}
+		}
+	}
+
+	left := make(map[string][]*Ref)
+	for _, r := range f.Rules {
+		left[r.Name] = f.leftRefs(r.Expr, nullable, nil)
+	}
+
+	const (
+		white = iota
+		grey
+		black
+	)
+	color := make(map[string]int)
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		color[name] = grey
+		path = append(path, name)
+		for _, ref := range left[name] {
+			switch color[ref.Name] {
+			case grey:
+				i := indexOf(path, ref.Name)
+				cycle := append(append([]string{}, path[i:]...), ref.Name)
+				return errorf(ref.Pos, "left recursion %s", strings.Join(cycle, " -> "))
+			case white:
+				if err := visit(ref.Name); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		color[name] = black
+		return nil
+	}
+	for _, r := range f.Rules {
+		if color[r.Name] == white {
+			if err := visit(r.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *File) nullable(e Expr, rules map[string]bool) bool {
+	switch e := e.(type) {
+	case *Ref:
+		return rules[e.Name]
+	case *Lit:
+		return false
+	case *Seq:
+		for _, x := range e.Items {
+			if !f.nullable(x, rules) {
+				return false
+			}
+		}
+		return true
+	case *Alt:
+		for _, x := range e.Alts {
+			if f.nullable(x, rules) {
+				return true
+			}
+		}
+		return false
+	case *Rep:
+		return e.Min == 0 || f.nullable(e.Expr, rules)
+	default: // Opt, Pred
+		return true
+	}
+}
+
+// leftRefs 不消费 token 即可到达的规则引用
+func (f *File) leftRefs(e Expr, nullable map[string]bool, acc []*Ref) []*Ref {
+	switch e := e.(type) {
+	case *Ref:
+		if _, ok := f.rules[e.Name]; ok {
+			acc = append(acc, e)
+		}
+	case *Seq:
+		for _, x := range e.Items {
+			acc = f.leftRefs(x, nullable, acc)
+			if !f.nullable(x, nullable) {
+				break
+			}
+		}
+	case *Alt:
+		for _, x := range e.Alts {
+			acc = f.leftRefs(x, nullable, acc)
+		}
+	case *Rep:
+		acc = f.leftRefs(e.Expr, nullable, acc)
+	case *Opt:
+		acc = f.leftRefs(e.Expr, nullable, acc)
+	case *Pred:
+		acc = f.leftRefs(e.Expr, nullable, acc)
+	}
+	return acc
+}
+
+func indexOf(xs []string, x string) int {
+	for i, s := range xs {
+		if s == x {
+			return i
+		}
+	}
+	return -1
+}
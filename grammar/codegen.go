@@ -0,0 +1,589 @@
+package grammar
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goghcrow/go-parsec/lexer"
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+// ----------------------------------------------------------------
+// Codegen
+// ----------------------------------------------------------------
+
+// Codegen 根据文法生成使用 parsec 的 Go 源码
+// 生成 TokenKind 与 lexer.BuildLexer 词法(%token %skip 与字面量), 类型化 AST, NewRule/SetPattern 规则, 以及 Parse 入口;
+// 多分支规则生成 interface, 每个分支生成一个实现 struct, 分支名取自 `@name`;
+// 所有 AST 节点实现 Node, Tokens() 按源码顺序返回节点覆盖的 token
+func Codegen(f *File, pkg string) ([]byte, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	g := &codegen{
+		File:      f,
+		tokens:    make(map[string]bool),
+		lits:      make(map[string]string),
+		ruleTypes: make(map[string]*goType),
+		idents:    make(map[string]bool),
+	}
+	if err := g.gen(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by parsecgen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	buf.WriteString("import (\n\t\"github.com/goghcrow/go-parsec/lexer\"\n\t\"github.com/goghcrow/go-parsec/parsec\"\n)\n\n")
+	buf.Write(g.head.Bytes())
+	buf.Write(g.rules.Bytes())
+	buf.Write(g.types.Bytes())
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+type typKind int
+
+const (
+	tyToken typKind = iota
+	tyNode          // *Struct, interface
+	tySlice
+	tyUnit // 断言, 无值
+)
+
+type goType struct {
+	kind typKind
+	name string
+	elem *goType
+}
+
+func (t *goType) String() string {
+	switch t.kind {
+	case tyToken:
+		return "parsec.Token[TokenKind]"
+	case tySlice:
+		return "[]" + t.elem.String()
+	case tyUnit:
+		return "struct{}"
+	default:
+		return t.name
+	}
+}
+
+var (
+	tokenType = &goType{kind: tyToken}
+	unitType  = &goType{kind: tyUnit}
+	nodeType  = &goType{kind: tyNode, name: "Node"}
+)
+
+type kindDef struct {
+	name    string
+	lit     string // 字面量
+	pattern string
+	skip    bool
+}
+
+type codegen struct {
+	*File
+	kinds     []kindDef
+	tokens    map[string]bool   // %token 名字
+	lits      map[string]string // 字面量 -> TokenKind 名字
+	ruleTypes map[string]*goType
+	idents    map[string]bool // 生成的顶层标识符, 检查冲突
+
+	head, rules, types bytes.Buffer
+
+	nested int // 当前规则内嵌套 struct 计数
+}
+
+func (g *codegen) declare(pos parsec.Pos, name string) error {
+	if token.IsKeyword(name) || !token.IsIdentifier(name) {
+		return errorf(pos, "invalid identifier %s", name)
+	}
+	if g.idents[name] || name == "parsec" || name == "lexer" {
+		return errorf(pos, "generated identifier %s conflicts", name)
+	}
+	g.idents[name] = true
+	return nil
+}
+
+func (g *codegen) gen() error {
+	for _, name := range []string{"TokenKind", "Node", "Lexer", "Lex", "Parse"} {
+		g.idents[name] = true
+	}
+	if err := g.genKinds(); err != nil {
+		return err
+	}
+	for _, r := range g.Rules {
+		t := &goType{kind: tyNode, name: "*" + camel(r.Name)}
+		if alt, ok := r.Expr.(*Alt); ok && len(alt.Alts) > 1 {
+			t.name = camel(r.Name)
+		}
+		g.ruleTypes[r.Name] = t
+		if err := g.declare(r.Pos, camel(r.Name)); err != nil {
+			return err
+		}
+		if err := g.declare(r.Pos, camel(r.Name)+"Rule"); err != nil {
+			return err
+		}
+	}
+
+	g.rules.WriteString("var (\n")
+	for _, r := range g.Rules {
+		fmt.Fprintf(&g.rules, "%sRule = parsec.NewRule[TokenKind, %s]()\n", camel(r.Name), g.ruleTypes[r.Name])
+	}
+	g.rules.WriteString(")\n\nfunc init() {\n")
+	for _, r := range g.Rules {
+		code, err := g.genRule(r)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.rules, "%sRule.SetPattern(%s, %s)\n", camel(r.Name), strconv.Quote(r.Name), code)
+	}
+	g.rules.WriteString("}\n\n")
+
+	start := g.Rules[0]
+	fmt.Fprintf(&g.rules, `// Parse 从 %s 开始解析 input, 必须消费所有 token 且结果唯一
+func Parse(input string) (%s, error) {
+	toks, err := Lex(input)
+	if err != nil {
+		return nil, err
+	}
+	return parsec.ExpectSingleResult(parsec.ExpectEOF(%sRule.Parse(toks)))
+}
+
+`, start.Name, g.ruleTypes[start.Name], camel(start.Name))
+	return nil
+}
+
+// ----------------------------------------------------------------
+// Lexicon
+// ----------------------------------------------------------------
+
+var punctNames = map[rune]string{
+	'+': "PLUS", '-': "MINUS", '*': "STAR", '/': "SLASH", '%': "PERCENT", '^': "CARET",
+	'=': "EQ", '<': "LT", '>': "GT", '!': "BANG", '?': "QUESTION", '&': "AMP", '|': "PIPE",
+	'~': "TILDE", '@': "AT", '#': "HASH", '$': "DOLLAR", '.': "DOT", ',': "COMMA",
+	':': "COLON", ';': "SEMICOLON", '(': "LEFT_PAREN", ')': "RIGHT_PAREN",
+	'[': "LEFT_BRACKET", ']': "RIGHT_BRACKET", '{': "LEFT_BRACE", '}': "RIGHT_BRACE",
+}
+
+func litName(lit string) string {
+	if lexer.IsIdentOp(lit) {
+		return strings.ToUpper(lit)
+	}
+	var xs []string
+	for _, r := range lit {
+		n, ok := punctNames[r]
+		if !ok {
+			return ""
+		}
+		xs = append(xs, n)
+	}
+	return strings.Join(xs, "_")
+}
+
+func (g *codegen) genKinds() error {
+	var lits []*Lit
+	for _, r := range g.Rules {
+		walk(r.Expr, func(e Expr) {
+			if l, ok := e.(*Lit); ok {
+				if _, ok := g.lits[l.Text]; !ok {
+					g.lits[l.Text] = ""
+					lits = append(lits, l)
+				}
+			}
+		})
+	}
+	for _, t := range g.Tokens {
+		if err := g.declare(t.Pos, t.Name); err != nil {
+			return err
+		}
+		g.tokens[t.Name] = true
+		g.kinds = append(g.kinds, kindDef{name: t.Name, pattern: t.Pattern, skip: t.Skip})
+	}
+	for i, l := range lits {
+		if l.Text == "" {
+			return errorf(l.Pos, "empty literal")
+		}
+		name := litName(l.Text)
+		if name == "" || g.idents[name] {
+			name = fmt.Sprintf("LIT_%d", i+1)
+		}
+		if err := g.declare(l.Pos, name); err != nil {
+			return err
+		}
+		g.lits[l.Text] = name
+		g.kinds = append(g.kinds, kindDef{name: name, lit: l.Text})
+	}
+
+	h := &g.head
+	h.WriteString("type TokenKind int\n\nconst (\n")
+	for i, k := range g.kinds {
+		if i == 0 {
+			fmt.Fprintf(h, "%s TokenKind = iota + 1", k.name)
+		} else {
+			h.WriteString(k.name)
+		}
+		if k.lit != "" {
+			fmt.Fprintf(h, " // %s", strconv.Quote(k.lit))
+		}
+		h.WriteString("\n")
+	}
+	h.WriteString(")\n\nfunc (k TokenKind) String() string {\nreturn map[TokenKind]string{\n")
+	for _, k := range g.kinds {
+		s := k.name
+		if k.lit != "" {
+			s = k.lit
+		}
+		fmt.Fprintf(h, "%s: %s,\n", k.name, strconv.Quote(s))
+	}
+	h.WriteString("}[k]\n}\n\n")
+
+	// 顺序匹配: skip 规则, 字面量(长的在前), %token
+	h.WriteString("var Lexer = lexer.BuildLexer(func(lex *lexer.Lexicon[TokenKind]) {\n")
+	for _, k := range g.kinds {
+		if k.skip {
+			fmt.Fprintf(h, "lex.Regex(%s, %s).Skip()\n", k.name, quoteRegex(k.pattern))
+		}
+	}
+	sorted := append([]*Lit{}, lits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].Text) > len(sorted[j].Text) })
+	for _, l := range sorted {
+		if lexer.IsIdentOp(l.Text) {
+			fmt.Fprintf(h, "lex.Keyword(%s, %s)\n", g.lits[l.Text], strconv.Quote(l.Text))
+		} else {
+			fmt.Fprintf(h, "lex.Str(%s, %s)\n", g.lits[l.Text], strconv.Quote(l.Text))
+		}
+	}
+	for _, k := range g.kinds {
+		if k.pattern != "" && !k.skip {
+			fmt.Fprintf(h, "lex.Regex(%s, %s)\n", k.name, quoteRegex(k.pattern))
+		}
+	}
+	h.WriteString("})\n\n")
+
+	h.WriteString(`// Lex 对 input 分词
+func Lex(input string) ([]parsec.Token[TokenKind], error) {
+	xs, err := lexer.NewLexer(Lexer.Lexicon).Lex(input)
+	if err != nil {
+		return nil, err
+	}
+	toks := make([]parsec.Token[TokenKind], len(xs))
+	for i, t := range xs {
+		toks[i] = t
+	}
+	return toks, nil
+}
+
+// Node AST 节点
+type Node interface {
+	// Tokens 按源码顺序返回节点覆盖的 token
+	Tokens() []parsec.Token[TokenKind]
+}
+
+`)
+	return nil
+}
+
+func quoteRegex(s string) string {
+	if strings.ContainsRune(s, '`') {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// ----------------------------------------------------------------
+// Rules & Types
+// ----------------------------------------------------------------
+
+func (g *codegen) genRule(r *Rule) (string, error) {
+	g.nested = 0
+	name := camel(r.Name)
+	alt, ok := r.Expr.(*Alt)
+	if !ok || len(alt.Alts) == 1 {
+		return g.genStruct(r.Pos, name, r.Name, items(r.Expr))
+	}
+
+	fmt.Fprintf(&g.types, "type %s interface {\nNode\nis%s()\n}\n\n", name, name)
+	codes := make([]string, len(alt.Alts))
+	for i, x := range alt.Alts {
+		variant := name + strconv.Itoa(i+1)
+		if s, ok := x.(*Seq); ok && s.Action != "" {
+			variant = name + camel(s.Action)
+		}
+		if err := g.declare(x, variant); err != nil {
+			return "", err
+		}
+		code, err := g.genStruct(x, variant, r.Name, items(x))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&g.types, "func (*%s) is%s() {}\n\n", variant, name)
+		codes[i] = fmt.Sprintf("parsec.Apply(%s, func(v *%s) %s { return v })", code, variant, name)
+	}
+	return choice(alt.Ordered, codes), nil
+}
+
+func items(e Expr) []Expr {
+	if s, ok := e.(*Seq); ok {
+		return s.Items
+	}
+	return []Expr{e}
+}
+
+func choice(ordered bool, codes []string) string {
+	if ordered {
+		return "parsec.AltSc(\n" + strings.Join(codes, ",\n") + ",\n)"
+	}
+	return "parsec.Alt(\n" + strings.Join(codes, ",\n") + ",\n)"
+}
+
+// genStruct 生成 struct 类型及构造该 struct 的 parser
+func (g *codegen) genStruct(pos parsec.Pos, name, rule string, es []Expr) (string, error) {
+	type field struct {
+		name string
+		typ  *goType
+	}
+	var (
+		codes  []string
+		typs   []*goType
+		fields []*field
+		seen   = make(map[string]int)
+	)
+	for _, e := range es {
+		code, typ, err := g.expr(e, rule)
+		if err != nil {
+			return "", err
+		}
+		codes = append(codes, code)
+		typs = append(typs, typ)
+		if typ.kind == tyUnit {
+			fields = append(fields, nil)
+			continue
+		}
+		fname := fieldName(e)
+		seen[fname]++
+		if seen[fname] > 1 {
+			fname += strconv.Itoa(seen[fname])
+		}
+		fields = append(fields, &field{fname, typ})
+	}
+
+	t := &g.types
+	fmt.Fprintf(t, "type %s struct {\n", name)
+	for _, f := range fields {
+		if f != nil {
+			fmt.Fprintf(t, "%s %s\n", f.name, f.typ)
+		}
+	}
+	t.WriteString("}\n\n")
+	fmt.Fprintf(t, "func (n *%s) Tokens() []parsec.Token[TokenKind] {\nif n == nil {\nreturn nil\n}\nvar toks []parsec.Token[TokenKind]\n", name)
+	for _, f := range fields {
+		if f != nil {
+			t.WriteString(tokensCode("n."+f.name, f.typ, 0))
+		}
+	}
+	t.WriteString("return toks\n}\n\n")
+
+	if len(codes) == 0 {
+		return fmt.Sprintf("parsec.Succ[TokenKind, *%s](&%s{})", name, name), nil
+	}
+
+	// 嵌套 Seq2 右结合, v.Car, v.Cdr.Car, ..., v.Cdr...Cdr
+	seq := codes[len(codes)-1]
+	typ := typs[len(typs)-1].String()
+	for i := len(codes) - 2; i >= 0; i-- {
+		seq = fmt.Sprintf("parsec.Seq2(%s, %s)", codes[i], seq)
+		typ = fmt.Sprintf("parsec.Cons[%s, %s]", typs[i], typ)
+	}
+	var inits []string
+	for i, f := range fields {
+		if f == nil {
+			continue
+		}
+		acc := "v" + strings.Repeat(".Cdr", i)
+		if i != len(fields)-1 {
+			acc += ".Car"
+		}
+		inits = append(inits, f.name+": "+acc)
+	}
+	return fmt.Sprintf("parsec.Apply(%s, func(v %s) *%s {\nreturn &%s{%s}\n})",
+		seq, typ, name, name, strings.Join(inits, ", ")), nil
+}
+
+func tokensCode(x string, t *goType, depth int) string {
+	switch t.kind {
+	case tyToken:
+		return fmt.Sprintf("if %s != nil {\ntoks = append(toks, %s)\n}\n", x, x)
+	case tyNode:
+		return fmt.Sprintf("if %s != nil {\ntoks = append(toks, %s.Tokens()...)\n}\n", x, x)
+	case tySlice:
+		v := "x" + strconv.Itoa(depth)
+		return fmt.Sprintf("for _, %s := range %s {\n%s}\n", v, x, tokensCode(v, t.elem, depth+1))
+	default:
+		return ""
+	}
+}
+
+func (g *codegen) expr(e Expr, rule string) (string, *goType, error) {
+	switch e := e.(type) {
+	case *Ref:
+		if t, ok := g.ruleTypes[e.Name]; ok {
+			return camel(e.Name) + "Rule.Parser()", t, nil
+		}
+		if g.tokens[e.Name] {
+			return "parsec.Tok(" + e.Name + ")", tokenType, nil
+		}
+		return "", nil, errorf(e.Pos, "undefined symbol %s", e.Name)
+	case *Lit:
+		return "parsec.Tok(" + g.lits[e.Text] + ")", tokenType, nil
+	case *Seq:
+		if len(e.Items) == 1 {
+			return g.expr(e.Items[0], rule)
+		}
+		return g.nestedStruct(e, rule, e.Items)
+	case *Alt:
+		var codes []string
+		var typs []*goType
+		same := true
+		for _, x := range e.Alts {
+			code, typ, err := g.expr(x, rule)
+			if err != nil {
+				return "", nil, err
+			}
+			same = same && (len(typs) == 0 || typ.String() == typs[0].String())
+			codes = append(codes, code)
+			typs = append(typs, typ)
+		}
+		if same {
+			return choice(e.Ordered, codes), typs[0], nil
+		}
+		// 分支类型不同, 统一为 Node
+		for i, x := range e.Alts {
+			if typs[i].kind != tyNode {
+				code, typ, err := g.nestedStruct(x, rule, []Expr{x})
+				if err != nil {
+					return "", nil, err
+				}
+				codes[i], typs[i] = code, typ
+			}
+			codes[i] = fmt.Sprintf("parsec.Apply(%s, func(v %s) Node { return v })", codes[i], typs[i])
+		}
+		return choice(e.Ordered, codes), nodeType, nil
+	case *Rep:
+		code, typ, err := g.expr(e.Expr, rule)
+		if err != nil {
+			return "", nil, err
+		}
+		if e.Min == 1 {
+			return "parsec.Many1Sc(" + code + ")", &goType{kind: tySlice, elem: typ}, nil
+		}
+		return "parsec.RepSc(" + code + ")", &goType{kind: tySlice, elem: typ}, nil
+	case *Opt:
+		code, typ, err := g.expr(e.Expr, rule)
+		if err != nil {
+			return "", nil, err
+		}
+		return "parsec.OptSc(" + code + ")", typ, nil
+	case *Pred:
+		code, typ, err := g.expr(e.Expr, rule)
+		if err != nil {
+			return "", nil, err
+		}
+		if e.Not {
+			return fmt.Sprintf("parsec.Apply(parsec.NotFollowedBy(%s), func(%s) struct{} { return struct{}{} })", code, typ), unitType, nil
+		}
+		return fmt.Sprintf("parsec.Apply(parsec.LookAhead(%s), func([]%s) struct{} { return struct{}{} })", code, typ), unitType, nil
+	default:
+		panic("unreached")
+	}
+}
+
+func (g *codegen) nestedStruct(pos parsec.Pos, rule string, es []Expr) (string, *goType, error) {
+	g.nested++
+	name := camel(rule) + "Item" + strconv.Itoa(g.nested)
+	if err := g.declare(pos, name); err != nil {
+		return "", nil, err
+	}
+	code, err := g.genStruct(pos, name, rule, es)
+	if err != nil {
+		return "", nil, err
+	}
+	return code, &goType{kind: tyNode, name: "*" + name}, nil
+}
+
+func fieldName(e Expr) string {
+	switch e := e.(type) {
+	case *Ref:
+		return camel(e.Name)
+	case *Lit:
+		if n := litName(e.Text); n != "" {
+			return camel(n)
+		}
+		return "Lit"
+	case *Rep:
+		return fieldName(e.Expr) + "s"
+	case *Opt:
+		return fieldName(e.Expr)
+	case *Seq:
+		if len(e.Items) == 1 {
+			return fieldName(e.Items[0])
+		}
+		return "Item"
+	case *Alt:
+		return "Choice"
+	default:
+		return "Pred"
+	}
+}
+
+// camel EXP -> Exp, call_args -> CallArgs, callArgs -> CallArgs
+func camel(s string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(s, "_") {
+		if part == "" {
+			continue
+		}
+		rs := []rune(part)
+		upper := strings.ToUpper(part) == part
+		for i, r := range rs {
+			if i == 0 {
+				b.WriteRune(unicode.ToUpper(r))
+			} else if upper {
+				b.WriteRune(unicode.ToLower(r))
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+func walk(e Expr, f func(Expr)) {
+	f(e)
+	switch e := e.(type) {
+	case *Seq:
+		for _, x := range e.Items {
+			walk(x, f)
+		}
+	case *Alt:
+		for _, x := range e.Alts {
+			walk(x, f)
+		}
+	case *Rep:
+		walk(e.Expr, f)
+	case *Opt:
+		walk(e.Expr, f)
+	case *Pred:
+		walk(e.Expr, f)
+	}
+}
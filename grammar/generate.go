@@ -0,0 +1,344 @@
+package grammar
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/goghcrow/go-parsec/lexer"
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+// ----------------------------------------------------------------
+// Generate, 按文法随机生成输入, 用于 fuzz 与性质测试
+// ----------------------------------------------------------------
+
+// GenOptions 控制生成的规模与分布, 零值使用默认值
+type GenOptions struct {
+	MaxDepth    int                  // 规则嵌套深度, 超过后只选择最浅的展开, 默认 16
+	MaxSize     int                  // token 数, 超过后只选择最浅的展开, 默认 64
+	MaxRep      int                  // {a} a* a+ 的最大重复次数, 默认 3, 只用于 Grammar.Generate
+	Weights     map[string][]float64 // 按规则名指定顶层 Alt 各分支的权重, 缺省为 1, 0 表示不选择; 嵌套的 Alt 均匀选择, 只用于 Grammar.Generate
+	RuleWeights map[string]float64   // 按期望的 token 所在的最内层规则名加权, 缺省为 1, 0 表示不选择, 只用于 Generate
+	Sep         string               // token 之间的分隔符, 需要被 lexicon 跳过, 默认空格
+	Attempts    int                  // 生成的输入不能被解析时的重试次数, 默认 16
+}
+
+func (o GenOptions) withDefault() GenOptions {
+	if o.MaxDepth <= 0 {
+		o.MaxDepth = 16
+	}
+	if o.MaxSize <= 0 {
+		o.MaxSize = 64
+	}
+	if o.MaxRep <= 0 {
+		o.MaxRep = 3
+	}
+	if o.Sep == "" {
+		o.Sep = " "
+	}
+	if o.Attempts <= 0 {
+		o.Attempts = 16
+	}
+	return o
+}
+
+// Generate 从 Load 加载的 EBNF 规则 name 随机展开文法, 生成可以被 ParseRule 完整解析的输入, 返回文本与分词结果
+// 终结符的文本来自 lexicon: 字符串原样输出, TokenKind 按 lexicon 规则采样(见 lexer.Lexicon.Sample);
+// 断言不参与生成, 有序选择与贪婪重复也可能使展开结果无法解析, 所以每次生成都会校验, 超过 Attempts 次返回错误
+func (g *Grammar[K]) Generate(name string, r *rand.Rand, opts GenOptions) (string, []parsec.Token[K], error) {
+	p, ok := g.Rule(name)
+	if !ok {
+		return "", nil, fmt.Errorf("undefined rule %s", name)
+	}
+	gen := &generator[K]{g: g, r: r, opts: opts.withDefault(), height: g.minHeight()}
+	if gen.height[name] >= infHeight {
+		return "", nil, fmt.Errorf("rule %s can not derive finite input", name)
+	}
+
+	for i := 0; i < gen.opts.Attempts; i++ {
+		gen.out = gen.out[:0]
+		if err := gen.rule(name, 0); err != nil {
+			return "", nil, err
+		}
+		input := strings.Join(gen.out, gen.opts.Sep)
+		toks, err := g.Lex(input)
+		if err != nil {
+			continue
+		}
+		if parsec.ExpectEOF(p.Parse(toks)).Success {
+			return input, toks, nil
+		}
+	}
+	return "", nil, fmt.Errorf("unable to generate valid input of rule %s in %d attempts", name, gen.opts.Attempts)
+}
+
+// Generate 从组合子构造的文法 p(e.g. parsec.Grammar 中的规则)随机生成可以被 ExpectEOF 完整解析的输入, 返回文本与分词结果;
+// 组合子是不透明的闭包, 所以通过 parsec.Expected 遍历文法: 每步解析已生成的前缀, 从输入末尾期望的 token 中按 RuleWeights 选择一个追加,
+// 前缀完整时同样可以选择结束; 深度为期望所在的规则层数, 超过 MaxDepth 的期望不被选择, token 数达到 MaxSize 后只选择最浅的期望并尽快结束;
+// 终结符的文本同 Grammar.Generate, Satisfy 等只有描述的期望无法生成; 追加的 token 不能继续解析时回退, 超过 Attempts 次返回错误
+func Generate[K parsec.TK, R any](p parsec.Parser[K, R], lexicon lexer.Lexicon[K], r *rand.Rand, opts GenOptions) (string, []parsec.Token[K], error) {
+	gen := &exploration[K, R]{p: p, lexicon: lexicon, r: r, opts: opts.withDefault()}
+	for i := 0; i < gen.opts.Attempts; i++ {
+		if input, toks, ok := gen.attempt(); ok {
+			return input, toks, nil
+		}
+	}
+	return "", nil, fmt.Errorf("unable to generate valid input in %d attempts", gen.opts.Attempts)
+}
+
+// exploration 按 parsec.Expected 逐个 token 生成输入
+type exploration[K parsec.TK, R any] struct {
+	p       parsec.Parser[K, R]
+	lexicon lexer.Lexicon[K]
+	r       *rand.Rand
+	opts    GenOptions
+}
+
+// attempt 生成一次, 回退与追加的总步数不超过 4 * MaxSize
+func (gen *exploration[K, R]) attempt() (string, []parsec.Token[K], bool) {
+	var out []string
+	for steps := 0; steps < 4*gen.opts.MaxSize; steps++ {
+		input := strings.Join(out, gen.opts.Sep)
+		toks, err := lex(gen.lexicon, input)
+		if err != nil {
+			out = out[:len(out)-1]
+			continue
+		}
+		done := parsec.ExpectEOF(gen.p.Parse(toks)).Success
+		if done && len(out) >= gen.opts.MaxSize {
+			return input, toks, true
+		}
+		texts, ws := gen.choices(parsec.Expected(gen.p, toks, len(toks)), len(out) >= gen.opts.MaxSize)
+		if done {
+			// 最后一个选择表示结束
+			texts, ws = append(texts, ""), append(ws, 1)
+		}
+		if len(texts) == 0 {
+			if len(out) == 0 {
+				return "", nil, false
+			}
+			out = out[:len(out)-1]
+			continue
+		}
+		i := pick(gen.r, ws)
+		if done && i == len(texts)-1 {
+			return input, toks, true
+		}
+		out = append(out, texts[i])
+	}
+	return "", nil, false
+}
+
+// choices 可以追加的 token 文本与权重, limited 时只保留最浅的期望
+func (gen *exploration[K, R]) choices(xs []parsec.Expectation[K], limited bool) (texts []string, ws []float64) {
+	depth := infHeight
+	for _, x := range xs {
+		if x.Label == "" && len(x.Rules) < depth {
+			depth = len(x.Rules)
+		}
+	}
+	for _, x := range xs {
+		if x.Label != "" || len(x.Rules) > gen.opts.MaxDepth && len(x.Rules) > depth || limited && len(x.Rules) > depth {
+			continue
+		}
+		w := 1.0
+		if len(x.Rules) != 0 {
+			if rw, ok := gen.opts.RuleWeights[x.Rules[len(x.Rules)-1]]; ok {
+				w = rw
+			}
+		}
+		if w <= 0 {
+			continue
+		}
+		text := x.Literal
+		if text == "" {
+			s, ok := gen.lexicon.Sample(x.Kind, gen.r)
+			if !ok {
+				continue
+			}
+			text = s
+		}
+		texts, ws = append(texts, text), append(ws, w)
+	}
+	return
+}
+
+// pick 按权重随机选择, ws 中至少有一个正数
+func pick(r *rand.Rand, ws []float64) int {
+	total := 0.0
+	for _, w := range ws {
+		total += w
+	}
+	n := r.Float64() * total
+	for i, w := range ws {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	return len(ws) - 1
+}
+
+const infHeight = 1 << 30
+
+// minHeight 各规则最浅展开的推导树高度, 不动点迭代, 不能终止的规则为 infHeight
+// 按最浅分支展开时, 引用的规则高度严格递减, 保证终止
+func (g *Grammar[K]) minHeight() map[string]int {
+	height := make(map[string]int, len(g.Rules))
+	for _, r := range g.Rules {
+		height[r.Name] = infHeight
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range g.Rules {
+			if h := exprHeight(r.Expr, height); h < infHeight && h+1 < height[r.Name] {
+				height[r.Name] = h + 1
+				changed = true
+			}
+		}
+	}
+	return height
+}
+
+func exprHeight(e Expr, height map[string]int) int {
+	switch e := e.(type) {
+	case *Ref:
+		return height[e.Name] // TokenKind 为 0
+	case *Lit:
+		return 0
+	case *Seq:
+		h := 0
+		for _, x := range e.Items {
+			if m := exprHeight(x, height); m > h {
+				h = m
+			}
+		}
+		return h
+	case *Alt:
+		h := infHeight
+		for _, x := range e.Alts {
+			if m := exprHeight(x, height); m < h {
+				h = m
+			}
+		}
+		return h
+	case *Rep:
+		if e.Min == 0 {
+			return 0
+		}
+		return exprHeight(e.Expr, height)
+	case *Opt, *Pred:
+		return 0
+	default:
+		panic("unreached")
+	}
+}
+
+type generator[K parsec.TK] struct {
+	g      *Grammar[K]
+	r      *rand.Rand
+	opts   GenOptions
+	height map[string]int
+	out    []string
+}
+
+// limited 超过深度或规模, 只选择最浅展开
+func (gen *generator[K]) limited(depth int) bool {
+	return depth >= gen.opts.MaxDepth || len(gen.out) >= gen.opts.MaxSize
+}
+
+func (gen *generator[K]) rule(name string, depth int) error {
+	e := gen.g.File.rules[name].Expr
+	if alt, ok := e.(*Alt); ok {
+		return gen.alt(alt, depth, gen.opts.Weights[name])
+	}
+	return gen.expr(e, depth)
+}
+
+func (gen *generator[K]) expr(e Expr, depth int) error {
+	switch e := e.(type) {
+	case *Ref:
+		if _, ok := gen.height[e.Name]; ok {
+			return gen.rule(e.Name, depth+1)
+		}
+		k, ok := gen.g.kinds[e.Name]
+		if !ok {
+			return errorf(e.Pos, "undefined symbol %s", e.Name)
+		}
+		s, ok := gen.g.lexicon.Sample(k, gen.r)
+		if !ok {
+			return errorf(e.Pos, "unable to sample token %s", e.Name)
+		}
+		gen.out = append(gen.out, s)
+	case *Lit:
+		gen.out = append(gen.out, e.Text)
+	case *Seq:
+		for _, x := range e.Items {
+			if err := gen.expr(x, depth); err != nil {
+				return err
+			}
+		}
+	case *Alt:
+		return gen.alt(e, depth, nil)
+	case *Rep:
+		n := e.Min
+		if !gen.limited(depth) && exprHeight(e.Expr, gen.height) < infHeight && gen.opts.MaxRep > e.Min {
+			n += gen.r.Intn(gen.opts.MaxRep - e.Min + 1)
+		}
+		for i := 0; i < n; i++ {
+			if err := gen.expr(e.Expr, depth); err != nil {
+				return err
+			}
+		}
+	case *Opt:
+		if !gen.limited(depth) && exprHeight(e.Expr, gen.height) < infHeight && gen.r.Intn(2) == 0 {
+			return gen.expr(e.Expr, depth)
+		}
+	case *Pred:
+	default:
+		panic("unreached")
+	}
+	return nil
+}
+
+// alt 按权重随机选择可以终止的分支, 超过限制时选择最浅分支
+func (gen *generator[K]) alt(e *Alt, depth int, weights []float64) error {
+	if gen.limited(depth) {
+		min, idx := infHeight, 0
+		for i, x := range e.Alts {
+			if n := exprHeight(x, gen.height); n < min {
+				min, idx = n, i
+			}
+		}
+		return gen.expr(e.Alts[idx], depth)
+	}
+
+	ws := make([]float64, len(e.Alts))
+	total := 0.0
+	for i, x := range e.Alts {
+		if exprHeight(x, gen.height) >= infHeight {
+			continue
+		}
+		ws[i] = 1
+		if i < len(weights) && weights[i] >= 0 {
+			ws[i] = weights[i]
+		}
+		total += ws[i]
+	}
+	if total <= 0 {
+		return errorf(e.Pos, "no alternative to choose in %s", e)
+	}
+	n, idx := gen.r.Float64()*total, 0
+	for i, w := range ws {
+		if w <= 0 {
+			continue
+		}
+		idx = i
+		if n < w {
+			break
+		}
+		n -= w
+	}
+	return gen.expr(e.Alts[idx], depth)
+}
@@ -0,0 +1,3 @@
+module github.com/goghcrow/go-parsec/grammar
+
+go 1.19
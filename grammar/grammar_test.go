@@ -0,0 +1,365 @@
+package grammar
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/goghcrow/go-parsec/lexer"
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+type tokenKind int
+
+const (
+	Number tokenKind = iota + 1
+	Ident
+	Oper
+	Space
+)
+
+func (k tokenKind) String() string {
+	return map[tokenKind]string{
+		Number: "NUMBER",
+		Ident:  "IDENT",
+		Oper:   "OPER",
+		Space:  "SPACE",
+	}[k]
+}
+
+var lexicon = func() lexer.Lexicon[tokenKind] {
+	lex := lexer.NewLexicon[tokenKind]()
+	lex.Regex(Space, `\s+`).Skip()
+	lex.Regex(Number, `\d+(\.\d+)?`)
+	lex.Regex(Ident, lexer.RegIdent)
+	lex.Regex(Oper, `[-+*/(),]`)
+	return lex
+}()
+
+const calcGrammar = `
+// 四则运算
+EXP    = FACTOR { ("+" | "-") FACTOR } @fold ;
+FACTOR = TERM { ("*" | "/") TERM } @fold ;
+TERM   = NUMBER @num
+       | ("+" | "-") TERM @neg
+       | "(" EXP ")" @paren ;
+`
+
+var calcActions = map[string]Action{
+	"num": func(v any) any {
+		n, _ := strconv.ParseFloat(v.(parsec.Token[tokenKind]).Lexeme(), 64)
+		return n
+	},
+	"neg": func(v any) any {
+		xs := v.([]any)
+		if xs[0].(parsec.Token[tokenKind]).Lexeme() == "-" {
+			return -xs[1].(float64)
+		}
+		return xs[1]
+	},
+	"paren": func(v any) any { return v.([]any)[1] },
+	"fold": func(v any) any {
+		xs := v.([]any)
+		acc := xs[0].(float64)
+		for _, x := range xs[1].([]any) {
+			opr := x.([]any)
+			r := opr[1].(float64)
+			switch opr[0].(parsec.Token[tokenKind]).Lexeme() {
+			case "+":
+				acc += r
+			case "-":
+				acc -= r
+			case "*":
+				acc *= r
+			case "/":
+				acc /= r
+			}
+		}
+		return acc
+	},
+}
+
+func TestLoad(t *testing.T) {
+	g := MustLoad(calcGrammar, lexicon, calcActions)
+	for _, tt := range []struct {
+		input  string
+		expect float64
+	}{
+		{"1", 1},
+		{"-1.5", -1.5},
+		{"1 + 2 * 3 + 4", 11},
+		{"(1 + 2) * (3 + 4)", 21},
+		{"1.2--3.4", 4.6},
+		{"8 / 2 / 2", 2},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			v, err := g.Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != tt.expect {
+				t.Errorf("expect %v actual %v", tt.expect, v)
+			}
+		})
+	}
+
+	_, err := g.Parse("1 + ")
+	if err == nil || err.Error() != "Nothing to consume expect `NUMBER` in end of input" {
+		t.Errorf("unexpected error %v", err)
+	}
+	var perr *parsec.Error
+	if !errors.As(err, &perr) || perr.Pos != parsec.EOFPos {
+		t.Errorf("expect *parsec.Error actual %#v", err)
+	}
+
+	_, err = g.Parse("1 % 2")
+	var lerr *lexer.Error
+	if !errors.As(err, &lerr) || lerr.Col != 2 {
+		t.Errorf("expect *lexer.Error actual %#v", err)
+	}
+}
+
+func TestLoadWithoutActions(t *testing.T) {
+	g := MustLoad(`
+LIST  = "(" [ ITEMS ] ")" ;
+ITEMS = ITEM { "," ITEM } ;
+ITEM  = IDENT / NUMBER / LIST ;
+`, lexicon, nil)
+	v, err := g.Parse("(a, (1, b), ())")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := g.Rule("ITEM")
+	if !ok || r == nil {
+		t.Errorf("expect rule ITEM")
+	}
+	xs := v.([]any)
+	if len(xs) != 3 || xs[1].([]any)[0].(parsec.Token[tokenKind]).Lexeme() != "a" {
+		t.Errorf("unexpected value %v", v)
+	}
+}
+
+func TestLoadPredicate(t *testing.T) {
+	g := MustLoad(`
+START = NAME* ;
+NAME  = !"let" IDENT @name ;
+`, lexicon, map[string]Action{
+		"name": func(v any) any { return v.([]any)[1].(parsec.Token[tokenKind]).Lexeme() },
+	})
+	v, err := g.Parse("a b c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.([]any)) != 3 {
+		t.Errorf("unexpected value %v", v)
+	}
+	if _, err = g.Parse("a let c"); err == nil {
+		t.Errorf("expect error")
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input string
+		error string
+	}{
+		{
+			"syntax",
+			"EXP = NUMBER",
+			"Nothing to consume expect `*` in end of input",
+		},
+		{
+			"syntax",
+			"EXP = NUMBER ;\nTERM = ( NUMBER ;",
+			"Unable to consume token `;` expect `*` in pos 32-33 line 2 col 17",
+		},
+		{
+			"lex",
+			"EXP = NUMBER % ;",
+			"syntax error: nothing token matched in pos 14-15 line 1 col 14",
+		},
+		{
+			"undefined symbol",
+			"EXP = NUMBER\n  | NAME ;",
+			"undefined symbol NAME in pos 18-22 line 2 col 5",
+		},
+		{
+			"undefined action",
+			"EXP = NUMBER @num ;",
+			"undefined action num in pos 7-13 line 1 col 7",
+		},
+		{
+			"duplicate",
+			"EXP = NUMBER ;\nEXP = IDENT ;",
+			"duplicate rule EXP in pos 16-19 line 2 col 1",
+		},
+		{
+			"mixed",
+			"EXP = NUMBER | IDENT / OPER ;",
+			"mixed `|` and `/` in one choice, use parentheses in pos 22-23 line 1 col 22",
+		},
+		{
+			"left recursion",
+			"EXP = TERM | EXP \"+\" TERM ;\nTERM = [ \"-\" ] FACTOR ;\nFACTOR = NUMBER | EXP ;",
+			"left recursion EXP -> TERM -> FACTOR -> EXP in pos 71-74 line 3 col 19",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.input, lexicon, nil)
+			if err == nil {
+				t.Fatalf("expect error %s", tt.error)
+			}
+			if err.Error() != tt.error {
+				t.Errorf("\nexpect: %s\nactual: %s", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestCodegenError(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input string
+		error string
+	}{
+		{
+			"undefined symbol",
+			"%token NUMBER = `\\d+` ;\nEXP = NUMBER | IDENT ;",
+			"undefined symbol IDENT in pos 40-45 line 2 col 16",
+		},
+		{
+			"duplicate token",
+			"%token NUMBER = `\\d+` ;\nNUMBER = \"1\" ;",
+			"duplicate rule NUMBER in pos 25-31 line 2 col 1",
+		},
+		{
+			"conflict",
+			"%token Exp = `\\d+` ;\nEXP = Exp ;",
+			"generated identifier Exp conflicts in pos 22-25 line 2 col 1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Codegen(f, "gen")
+			if err == nil || err.Error() != tt.error {
+				t.Errorf("\nexpect: %s\nactual: %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	g := MustLoad(calcGrammar, lexicon, calcActions)
+	r := rand.New(rand.NewSource(42))
+	for i := 0; i < 200; i++ {
+		input, toks, err := g.Generate("EXP", r, GenOptions{MaxSize: 32})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(toks) == 0 {
+			t.Fatalf("expect tokens of %q", input)
+		}
+		if _, err := g.Parse(input); err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+	}
+
+	// 只生成数字
+	opts := GenOptions{Weights: map[string][]float64{"TERM": {1, 0, 0}}}
+	for i := 0; i < 50; i++ {
+		input, _, err := g.Generate("TERM", r, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := strconv.ParseFloat(input, 64); err != nil {
+			t.Errorf("expect number actual %q", input)
+		}
+	}
+
+	if _, _, err := g.Generate("NOPE", r, GenOptions{}); err == nil {
+		t.Errorf("expect error")
+	}
+	// 超过深度后按最浅分支展开
+	nest := MustLoad(`A = "(" A ")" | B ; B = "(" B ")" | IDENT ;`, lexicon, nil)
+	if input, _, err := nest.Generate("A", r, GenOptions{MaxDepth: 1, Weights: map[string][]float64{"A": {1, 0}}}); err != nil || strings.Count(input, "(") != 1 {
+		t.Errorf("unexpected %q %v", input, err)
+	}
+	inf := MustLoad(`A = "(" A ")" ;`, lexicon, nil)
+	if _, _, err := inf.Generate("A", r, GenOptions{}); err == nil || err.Error() != "rule A can not derive finite input" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestGenerateParser(t *testing.T) {
+	// 组合子构造的四则运算
+	tok := func(s string) parsec.Parser[tokenKind, parsec.Token[tokenKind]] { return parsec.Str[tokenKind](s) }
+	g := parsec.NewGrammar[tokenKind]()
+	exp := parsec.Define[tokenKind, int](g, "exp")
+	term := parsec.Define[tokenKind, int](g, "term")
+	paren := parsec.Define[tokenKind, int](g, "paren")
+	count := func(parsec.Token[tokenKind]) int { return 1 }
+	exp.Pattern = parsec.LRecSc(term.Parser(), parsec.Seq2(parsec.AltSc(tok("+"), tok("*")), term.Parser()),
+		func(l int, r parsec.Cons[parsec.Token[tokenKind], int]) int { return l + r.Cdr })
+	term.Pattern = parsec.AltSc(parsec.Apply(parsec.Tok(Number), count), paren.Parser())
+	paren.Pattern = parsec.KMid(tok("("), exp.Parser(), tok(")"))
+	g.MustBuild()
+
+	r := rand.New(rand.NewSource(42))
+	for i := 0; i < 100; i++ {
+		input, toks, err := Generate(exp.Parser(), lexicon, r, GenOptions{MaxSize: 16})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(toks) == 0 || len(toks) > 32 {
+			t.Errorf("unexpected size %d of %q", len(toks), input)
+		}
+		if !parsec.ExpectEOF(exp.Parse(toks)).Success {
+			t.Fatalf("%q can not be parsed", input)
+		}
+	}
+
+	// 按规则加权: 不选择括号
+	for i := 0; i < 50; i++ {
+		input, _, err := Generate(exp.Parser(), lexicon, r, GenOptions{RuleWeights: map[string]float64{"paren": 0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(input, "(") {
+			t.Errorf("expect no paren actual %q", input)
+		}
+	}
+
+	// 只有描述的期望无法生成
+	label := parsec.Satisfy[tokenKind](func(parsec.Token[tokenKind]) bool { return true }, "anything")
+	if _, _, err := Generate(label, lexicon, r, GenOptions{}); err == nil {
+		t.Errorf("expect error")
+	}
+}
+
+// FuzzParse 以 Generate 生成的输入作为种子
+// go test -fuzz=FuzzParse
+func FuzzParse(f *testing.F) {
+	g := MustLoad(calcGrammar, lexicon, calcActions)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 16; i++ {
+		input, _, err := g.Generate("EXP", r, GenOptions{})
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(input)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		v, err := g.Parse(input)
+		if err == nil {
+			if _, ok := v.(float64); !ok {
+				t.Errorf("unexpected value %v of %q", v, input)
+			}
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package grammar
+
+import (
+	"fmt"
+
+	"github.com/goghcrow/go-parsec/lexer"
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+// Action 语义动作, 参数为规则(或分支)的值
+// Token -> parsec.Token[K], 序列 -> []any, 重复 -> []any, 可选 -> 值或 nil, 断言 -> nil
+type Action func(v any) any
+
+// Grammar 运行时加载的文法, 规则值统一为 any
+type Grammar[K parsec.TK] struct {
+	*File
+	lexicon lexer.Lexicon[K]
+	kinds   map[string]K
+	rules   map[string]*parsec.SyntaxRule[K, any]
+}
+
+// Load 解析 EBNF/PEG 文法文本, 构造 parsec 规则
+// 标识符优先解析为规则引用, 否则按 K.String() 匹配 lexicon 中的 TokenKind, 字符串按文本匹配 token;
+// 以规则名命名的 action 作用于整条规则, `@name` 标注的 action 作用于所在分支
+func Load[K parsec.TK](src string, lexicon lexer.Lexicon[K], actions map[string]Action) (*Grammar[K], error) {
+	f, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	g := &Grammar[K]{
+		File:    f,
+		lexicon: lexicon,
+		kinds:   make(map[string]K),
+		rules:   make(map[string]*parsec.SyntaxRule[K, any]),
+	}
+	for _, k := range lexicon.Kinds() {
+		g.kinds[k.String()] = k
+	}
+	c := &compiler[K]{g: g, actions: actions}
+	if err := c.compile(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// MustLoad 同 Load, 出错 panic
+func MustLoad[K parsec.TK](src string, lexicon lexer.Lexicon[K], actions map[string]Action) *Grammar[K] {
+	g, err := Load(src, lexicon, actions)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// Rule 按名字查找规则
+func (g *Grammar[K]) Rule(name string) (parsec.Parser[K, any], bool) {
+	r, ok := g.rules[name]
+	if !ok {
+		return nil, false
+	}
+	return r, true
+}
+
+// Start 第一条规则为开始规则
+func (g *Grammar[K]) Start() parsec.Parser[K, any] {
+	return g.rules[g.Rules[0].Name]
+}
+
+// Parse 从开始规则解析 input
+func (g *Grammar[K]) Parse(input string) (any, error) {
+	return g.ParseRule(g.Rules[0].Name, input)
+}
+
+// ParseRule 从指定规则解析 input, 必须消费所有 token 且结果唯一
+func (g *Grammar[K]) ParseRule(name string, input string) (any, error) {
+	p, ok := g.Rule(name)
+	if !ok {
+		return nil, fmt.Errorf("undefined rule %s", name)
+	}
+	toks, err := g.Lex(input)
+	if err != nil {
+		return nil, err
+	}
+	return parsec.ExpectSingleResult(parsec.ExpectEOF(p.Parse(toks)))
+}
+
+// Lex 使用 lexicon 对 input 分词
+func (g *Grammar[K]) Lex(input string) ([]parsec.Token[K], error) {
+	return lex(g.lexicon, input)
+}
+
+func lex[K parsec.TK](lexicon lexer.Lexicon[K], input string) ([]parsec.Token[K], error) {
+	xs, err := lexer.NewLexer(lexicon).Lex(input)
+	if err != nil {
+		return nil, err
+	}
+	toks := make([]parsec.Token[K], len(xs))
+	for i, t := range xs {
+		toks[i] = t
+	}
+	return toks, nil
+}
+
+// ----------------------------------------------------------------
+// Compile
+// ----------------------------------------------------------------
+
+type compiler[K parsec.TK] struct {
+	g       *Grammar[K]
+	actions map[string]Action
+}
+
+func errorf(pos parsec.Pos, format string, a ...any) *parsec.Error {
+	return &parsec.Error{Pos: pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+func (c *compiler[K]) compile() error {
+	if err := c.g.File.check(); err != nil {
+		return err
+	}
+	for _, r := range c.g.Rules {
+		c.g.rules[r.Name] = parsec.NewRule[K, any]()
+	}
+	for _, r := range c.g.Rules {
+		p, err := c.expr(r.Expr)
+		if err != nil {
+			return err
+		}
+		if f, ok := c.actions[r.Name]; ok {
+			p = parsec.Apply(p, f)
+		}
+		c.g.rules[r.Name].SetPattern(r.Name, p)
+	}
+	return nil
+}
+
+func (c *compiler[K]) expr(e Expr) (parsec.Parser[K, any], error) {
+	switch e := e.(type) {
+	case *Ref:
+		if r, ok := c.g.rules[e.Name]; ok {
+			return r, nil
+		}
+		if k, ok := c.g.kinds[e.Name]; ok {
+			return parsec.Apply(parsec.Tok(k), toAny[parsec.Token[K]]), nil
+		}
+		return nil, errorf(e.Pos, "undefined symbol %s", e.Name)
+	case *Lit:
+		return parsec.Apply(parsec.Str[K](e.Text), toAny[parsec.Token[K]]), nil
+	case *Seq:
+		ps, err := c.exprs(e.Items)
+		if err != nil {
+			return nil, err
+		}
+		var p parsec.Parser[K, any]
+		if len(ps) == 1 {
+			p = ps[0]
+		} else {
+			p = parsec.Apply(parsec.Seq(ps...), toAny[[]any])
+		}
+		if e.Action != "" {
+			f, ok := c.actions[e.Action]
+			if !ok {
+				return nil, errorf(e.Pos, "undefined action %s", e.Action)
+			}
+			p = parsec.Apply(p, f)
+		}
+		return p, nil
+	case *Alt:
+		if e.mixed != nil {
+			return nil, errorf(*e.mixed, "mixed `|` and `/` in one choice, use parentheses")
+		}
+		ps, err := c.exprs(e.Alts)
+		if err != nil {
+			return nil, err
+		}
+		if e.Ordered {
+			return parsec.AltSc(ps...), nil
+		}
+		return parsec.Alt(ps...), nil
+	case *Rep:
+		p, err := c.expr(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if e.Min == 1 {
+			return parsec.Apply(parsec.Many1Sc(p), toAny[[]any]), nil
+		}
+		return parsec.Apply(parsec.RepSc(p), toAny[[]any]), nil
+	case *Opt:
+		p, err := c.expr(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return parsec.OptSc(p), nil
+	case *Pred:
+		p, err := c.expr(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if e.Not {
+			return parsec.Apply(parsec.NotFollowedBy(p), func(any) any { return nil }), nil
+		}
+		return parsec.Apply(parsec.LookAhead(p), func([]any) any { return nil }), nil
+	default:
+		panic("unreached")
+	}
+}
+
+func (c *compiler[K]) exprs(es []Expr) ([]parsec.Parser[K, any], error) {
+	ps := make([]parsec.Parser[K, any], len(es))
+	for i, e := range es {
+		p, err := c.expr(e)
+		if err != nil {
+			return nil, err
+		}
+		ps[i] = p
+	}
+	return ps, nil
+}
+
+func toAny[T any](v T) any { return v }
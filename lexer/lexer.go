@@ -70,7 +70,8 @@ func (l *Lexer[K]) next() (tok *Token[K], keep bool, err error) {
 			return &Token[K]{kind: rl.K, lexeme: string(matched), Pos: pos}, rl.keep, nil
 		}
 	}
-	return nil, false, fmt.Errorf("syntax error in %s: nothing token matched", l.Pos)
+	pos.IdxEnd = pos.Idx + 1
+	return nil, false, &Error{Pos: pos, Msg: "syntax error: nothing token matched"}
 }
 
 func (l *Lexer[K]) Move(r rune) {
@@ -131,6 +132,7 @@ func (p Pos) Span(runes []rune) string { return string(runes[p.Idx:p.IdxEnd]) }
 // Error
 // ----------------------------------------------------------------
 
+// Error 词法错误, Lex 返回 *Error, 可以用 errors.As 取出位置
 type Error struct {
 	Pos
 	Msg string
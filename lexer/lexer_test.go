@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"fmt"
+	"math/rand"
 	"strings"
 	"testing"
 )
@@ -93,3 +94,59 @@ func fmtToks(toks []*Token[tokKind]) string {
 	}
 	return strings.Join(xs, "🍌")
 }
+
+func TestSample(t *testing.T) {
+	lex := NewLexicon[tokKind]()
+	lex.Keyword(NumId, "let")
+	lex.Regex(Ident, `[a-z]{1,3}`)
+	lex.Regex(Number, `-?\d+(\.\d+)?|0x[0-9a-f]+`)
+	lex.Str(Comma, ",")
+	lexer := NewLexer(lex)
+
+	r := rand.New(rand.NewSource(1))
+	for _, k := range []tokKind{NumId, Ident, Number, Comma} {
+		for i := 0; i < 100; i++ {
+			s, ok := lex.Sample(k, r)
+			if !ok {
+				t.Fatalf("expect sample of %s", stroftk(k))
+			}
+			toks, err := lexer.Lex(s)
+			if err != nil || len(toks) != 1 || toks[0].Kind() != k {
+				t.Fatalf("sample %q of %s lexed as %v %v", s, stroftk(k), toks, err)
+			}
+		}
+	}
+	if _, ok := lex.Sample(Space, r); ok {
+		t.Errorf("expect no sample of undefined kind")
+	}
+}
+
+func TestWith(t *testing.T) {
+	base := NewLexicon[tokKind]()
+	base.Regex(Ident, "[a-zA-Z]\\w*")
+	base.Regex(Space, "\\s+").Skip()
+
+	// 新规则优先, 关键字先于标识符匹配
+	ext := base.With(func(lex *Lexicon[tokKind]) {
+		lex.Keyword(NumId, "let")
+		lex.Regex(Number, "\\d+")
+	})
+	if actual := fmtToks(NewLexer(ext).MustLex("let x 1")); actual != "<numid>/let🍌<id>/x🍌<num>/1" {
+		t.Errorf("actual %s", actual)
+	}
+
+	// 原词法不变
+	if actual := fmtToks(NewLexer(base).MustLex("let x")); actual != "<id>/let🍌<id>/x" {
+		t.Errorf("actual %s", actual)
+	}
+	if _, err := NewLexer(base).Lex("1"); err == nil {
+		t.Errorf("expect error")
+	}
+
+	// 修改副本的规则不影响原词法
+	c := base.Clone()
+	c.rules[1].keep = true
+	if actual := fmtToks(NewLexer(base).MustLex("a b")); actual != "<id>/a🍌<id>/b" {
+		t.Errorf("actual %s", actual)
+	}
+}
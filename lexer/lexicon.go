@@ -12,6 +12,9 @@ type Rule[K Ord] struct {
 	keep  bool
 	K     K
 	match func(string) int // 匹配返回 EndRuneCount , 失败返回 NotMatched
+
+	lit     string // 字面量规则的文本, 见 Sample
+	pattern string // 正则规则的模式, 见 Sample
 }
 
 func (r *Rule[K]) Skip() *Rule[K] { r.keep = false; return r }
@@ -25,6 +28,39 @@ func NewLexicon[K Ord]() Lexicon[K] {
 	return Lexicon[K]{}
 }
 
+// Kinds 按规则声明顺序返回所有 TokenKind (去重)
+func (l *Lexicon[K]) Kinds() []K {
+	var ks []K
+	seen := make(map[K]bool)
+	for _, r := range l.rules {
+		if !seen[r.K] {
+			seen[r.K] = true
+			ks = append(ks, r.K)
+		}
+	}
+	return ks
+}
+
+// Clone 复制词法, 修改副本(包括 Skip)不影响原词法
+func (l *Lexicon[K]) Clone() Lexicon[K] {
+	rules := make([]*Rule[K], len(l.rules))
+	for i, r := range l.rules {
+		x := *r
+		rules[i] = &x
+	}
+	return Lexicon[K]{rules: rules}
+}
+
+// With 复制词法并添加 f 声明的规则, 用于在共享的词法上扩展方言;
+// 首次匹配, 所以新规则排在原有规则之前, e.g. 新增的 `**` 优先于 `*`, 关键字优先于标识符
+func (l *Lexicon[K]) With(f func(lexicon *Lexicon[K])) Lexicon[K] {
+	ext := NewLexicon[K]()
+	f(&ext)
+	c := l.Clone()
+	c.rules = append(ext.rules, c.rules...)
+	return c
+}
+
 func (l *Lexicon[K]) Rule(r Rule[K]) *Rule[K] {
 	l.rules = append(l.rules, &r)
 	return &r
@@ -48,7 +84,7 @@ func (l *Lexicon[TokenKind]) Oper(k TokenKind, oper string) *Rule[TokenKind] {
 }
 
 func str[K Ord](k K, str string) Rule[K] {
-	return Rule[K]{true, k, func(s string) int {
+	return Rule[K]{keep: true, K: k, lit: str, match: func(s string) int {
 		if strings.HasPrefix(s, str) {
 			return runeCount(str)
 		} else {
@@ -60,7 +96,7 @@ func str[K Ord](k K, str string) Rule[K] {
 var keywordPostfix = regexp.MustCompile(`^[a-zA-Z\d\p{L}_]+`)
 
 func keyword[TokenKind comparable](k TokenKind, kw string) Rule[TokenKind] {
-	return Rule[TokenKind]{true, k, func(s string) int {
+	return Rule[TokenKind]{keep: true, K: k, lit: kw, match: func(s string) int {
 		// golang regexp 不支持 lookahead
 		completedWord := strings.HasPrefix(s, kw) &&
 			!keywordPostfix.MatchString(s[len(kw):])
@@ -74,7 +110,7 @@ func keyword[TokenKind comparable](k TokenKind, kw string) Rule[TokenKind] {
 
 func regex[K Ord](k K, pattern string) Rule[K] {
 	startWith := regexp.MustCompile("^(?:" + pattern + ")")
-	return Rule[K]{true, k, func(s string) int {
+	return Rule[K]{keep: true, K: k, pattern: pattern, match: func(s string) int {
 		found := startWith.FindString(s)
 		if found == "" {
 			return NotMatched
@@ -87,7 +123,7 @@ func regex[K Ord](k K, pattern string) Rule[K] {
 // primOper . ? 内置操作符的优先级高于自定义操作符, 且不是匹配最长, 需要特殊处理
 // e.g 比如自定义操作符 .^. 不能匹配成 [`.`, `^.`]
 func primOper[TokenKind comparable](k TokenKind, oper string) Rule[TokenKind] {
-	return Rule[TokenKind]{true, k, func(s string) int {
+	return Rule[TokenKind]{keep: true, K: k, lit: oper, match: func(s string) int {
 		if !strings.HasPrefix(s, oper) {
 			return NotMatched
 		}
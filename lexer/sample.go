@@ -0,0 +1,136 @@
+package lexer
+
+import (
+	"math/rand"
+	"regexp/syntax"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// Sample, 按词法规则生成随机文本, 用于 fuzz
+// ----------------------------------------------------------------
+
+// maxSampleRep 正则中 * + 及无上限重复的最大次数
+const maxSampleRep = 4
+
+// maxSampleTry 正则采样被其他规则抢先匹配时的重试次数
+const maxSampleTry = 32
+
+// Sample 按声明顺序找到 k 的规则, 生成一个可以被完整分词为 k 的文本
+// 字面量规则返回文本本身, 正则规则按模式随机采样;
+// 因为是首次匹配, 采样结果需要通过整个 lexicon 校验(e.g. 标识符采样到了关键词), 失败返回 false
+func (l *Lexicon[K]) Sample(k K, r *rand.Rand) (string, bool) {
+	for _, rl := range l.rules {
+		if rl.K != k {
+			continue
+		}
+		if rl.lit != "" {
+			if l.lexAs(rl.lit, k) {
+				return rl.lit, true
+			}
+			continue
+		}
+		if rl.pattern == "" {
+			continue
+		}
+		re, err := syntax.Parse(rl.pattern, syntax.Perl)
+		if err != nil {
+			continue
+		}
+		re = re.Simplify()
+		for i := 0; i < maxSampleTry; i++ {
+			var b strings.Builder
+			sampleRegex(&b, re, r)
+			if s := b.String(); l.lexAs(s, k) {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
+
+// lexAs s 是否被完整匹配为一个 k
+func (l *Lexicon[K]) lexAs(s string, k K) bool {
+	if s == "" {
+		return false
+	}
+	for _, rl := range l.rules {
+		if n := rl.match(s); n >= 0 {
+			return rl.K == k && n == runeCount(s)
+		}
+	}
+	return false
+}
+
+func sampleRegex(b *strings.Builder, re *syntax.Regexp, r *rand.Rand) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, c := range re.Rune {
+			b.WriteRune(c)
+		}
+	case syntax.OpCharClass:
+		b.WriteRune(sampleClass(re.Rune, r))
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		b.WriteRune(rune(' ' + 1 + r.Intn('~'-' ')))
+	case syntax.OpCapture:
+		sampleRegex(b, re.Sub[0], r)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			sampleRegex(b, sub, r)
+		}
+	case syntax.OpAlternate:
+		sampleRegex(b, re.Sub[r.Intn(len(re.Sub))], r)
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		min, max := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			min, max = 0, -1
+		case syntax.OpPlus:
+			min, max = 1, -1
+		case syntax.OpQuest:
+			min, max = 0, 1
+		}
+		if max < 0 {
+			max = min + maxSampleRep
+		}
+		for i, n := 0, min+r.Intn(max-min+1); i < n; i++ {
+			sampleRegex(b, re.Sub[0], r)
+		}
+	default:
+		// OpEmptyMatch, OpBeginLine, OpEndText ... 不产生字符
+	}
+}
+
+// sampleClass 优先选择可打印 ascii 字符, ranges 为 [lo, hi] 对
+func sampleClass(ranges []rune, r *rand.Rand) rune {
+	var printable []rune
+	cnt := 0
+	for i := 0; i < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if lo < ' '+1 {
+			lo = ' ' + 1
+		}
+		if hi > '~' {
+			hi = '~'
+		}
+		if lo <= hi {
+			printable = append(printable, lo, hi)
+			cnt += int(hi - lo + 1)
+		}
+	}
+	if cnt == 0 {
+		if len(ranges) == 0 {
+			return ' '
+		}
+		return ranges[0]
+	}
+	n := rune(r.Intn(cnt))
+	for i := 0; i < len(printable); i += 2 {
+		lo, hi := printable[i], printable[i+1]
+		if n <= hi-lo {
+			return lo + n
+		}
+		n -= hi - lo + 1
+	}
+	panic("unreached")
+}
@@ -5,40 +5,65 @@ package parsec
 // ----------------------------------------------------------------
 
 // Alt :: p[a] -> p[b] -> p[c] -> ... -> p[a|b|c...]
-// 返回所有可能结果, 当 ps 全部失败时失败
+// 返回所有可能结果, 当 ps 全部失败时失败; GreedyMode 下即 AltSc, 见 Mode
 // foldr (<|>) mzero ps
 func Alt[K TK, R any](ps ...Parser[K, R]) Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
+	return moded(altAll(ps...), AltSc(ps...))
+}
+
+func altAll[K TK, R any](ps ...Parser[K, R]) Parser[K, R] {
+	return withEnum(parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
 		var xs []Result[K, R]
 		var err *Error
 		var succ bool
 		for _, p := range ps {
-			out := p.Parse(toks)
+			out := ParseIn(st, p, toks)
 			err = betterError(err, out.Error)
 			if out.Success {
+				// 复制到新的切片, 不与分支的 Candidates 共享底层数组
 				xs = append(xs, out.Candidates...)
 				succ = true
 			}
 		}
 		return newOutput(xs, err, succ)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+		var err *Error
+		for _, p := range ps {
+			stop := false
+			err = betterError(err, EnumIn(st, p, toks, func(r Result[K, R]) bool {
+				stop = !yield(r)
+				return !stop
+			}))
+			if stop {
+				break
+			}
+		}
+		return err
 	})
 }
 
 func Alt2[K TK, R1, R2 any](
 	p1 Parser[K, R1],
 	p2 Parser[K, R2],
+) Parser[K, Either[R1, R2]] {
+	return moded(alt2All(p1, p2), AltSc2(p1, p2))
+}
+
+func alt2All[K TK, R1, R2 any](
+	p1 Parser[K, R1],
+	p2 Parser[K, R2],
 ) Parser[K, Either[R1, R2]] {
 	mkLeft := resultOf[K, R1, Either[R1, R2]](Left[R1, R2])
 	mkRight := resultOf[K, R2, Either[R1, R2]](Right[R1, R2])
-	return parser[K, Either[R1, R2]](func(toks []Token[K]) Output[K, Either[R1, R2]] {
+	return parser[K, Either[R1, R2]](func(st *State[K], toks []Token[K]) Output[K, Either[R1, R2]] {
 		var xs []Result[K, Either[R1, R2]]
 
-		out1 := p1.Parse(toks)
+		out1 := ParseIn(st, p1, toks)
 		if out1.Success {
 			xs = append(xs, sliceMap(out1.Candidates, mkLeft)...)
 		}
 
-		out2 := p2.Parse(toks)
+		out2 := ParseIn(st, p2, toks)
 		if out2.Success {
 			xs = append(xs, sliceMap(out2.Candidates, mkRight)...)
 		}
@@ -73,18 +98,31 @@ func Alt5[K TK, T1, T2, T3, T4, T5 any](p1 Parser[K, T1],
 }
 
 // AltSc :: p[a] -> p[b] -> p[c] -> ... -> p[a|b|c...]
-// 返回第一个结果, 当 ps 全部失败时失败
+// 返回第一个结果, 当 ps 全部失败时失败; 不受 Mode 影响
 func AltSc[K TK, R any](ps ...Parser[K, R]) Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
+	return withEnum(parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
 		var err *Error
 		for _, p := range ps {
-			out := p.Parse(toks)
+			out := ParseIn(st, p, toks)
 			err = betterError(err, out.Error)
 			if out.Success {
 				return successWithErr[K, R](out.Candidates, err)
 			}
 		}
 		return fail[K, R](err)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+		var err *Error
+		for _, p := range ps {
+			succ := false
+			err = betterError(err, EnumIn(st, p, toks, func(r Result[K, R]) bool {
+				succ = true
+				return yield(r)
+			}))
+			if succ {
+				break
+			}
+		}
+		return err
 	})
 }
 
@@ -94,16 +132,16 @@ func AltSc2[K TK, R1, R2 any](
 ) Parser[K, Either[R1, R2]] {
 	mkLeft := resultOf[K, R1, Either[R1, R2]](Left[R1, R2])
 	mkRight := resultOf[K, R2, Either[R1, R2]](Right[R1, R2])
-	return parser[K, Either[R1, R2]](func(toks []Token[K]) Output[K, Either[R1, R2]] {
+	return parser[K, Either[R1, R2]](func(st *State[K], toks []Token[K]) Output[K, Either[R1, R2]] {
 		var err *Error
 
-		out1 := p1.Parse(toks)
+		out1 := ParseIn(st, p1, toks)
 		err = betterError(err, out1.Error)
 		if out1.Success {
 			return successWithErr(sliceMap(out1.Candidates, mkLeft), err)
 		}
 
-		out2 := p2.Parse(toks)
+		out2 := ParseIn(st, p2, toks)
 		err = betterError(err, out2.Error)
 		if out2.Success {
 			return successWithErr(sliceMap(out2.Candidates, mkRight), err)
@@ -151,3 +189,91 @@ func Opt[K TK, R any](p Parser[K, R]) Parser[K, R /*Option[R]*/] {
 func OptSc[K TK, R any](p Parser[K, R]) Parser[K, R /*Option[R]*/] {
 	return AltSc(p, Nil[K, R]())
 }
+
+// OptionOr :: a -> p[a] -> p[a]
+// 即 Parsec 的 option x p, p 失败时返回 x; 同 Opt 返回 p 的结果与 x
+func OptionOr[K TK, R any](x R, p Parser[K, R]) Parser[K, R] {
+	return Alt(p, Succ[K, R](x))
+}
+
+// OptionOrSc :: a -> p[a] -> p[a]
+// 只有 p 失败才返回 x
+func OptionOrSc[K TK, R any](x R, p Parser[K, R]) Parser[K, R] {
+	return AltSc(p, Succ[K, R](x))
+}
+
+// OptionMaybe :: p[a] -> p[Option[a]]
+// 即 Parsec 的 optionMaybe p, 区分 p 成功返回零值与 p 失败
+func OptionMaybe[K TK, R any](p Parser[K, R]) Parser[K, Option[R]] {
+	return OptionOr(None[R](), Apply(p, Some[R]))
+}
+
+// OptionMaybeSc :: p[a] -> p[Option[a]]
+// 只有 p 失败才返回 None
+func OptionMaybeSc[K TK, R any](p Parser[K, R]) Parser[K, Option[R]] {
+	return OptionOrSc(None[R](), Apply(p, Some[R]))
+}
+
+// AltOf2 ~ AltOf5 同 Alt2 ~ Alt5, 返回扁平的 OneOfN 而不是嵌套的 Either
+func AltOf2[K TK, T1, T2 any](
+	p1 Parser[K, T1],
+	p2 Parser[K, T2],
+) Parser[K, OneOf2[T1, T2]] {
+	return Apply(Alt2(p1, p2), FromEither2[T1, T2])
+}
+func AltOf3[K TK, T1, T2, T3 any](
+	p1 Parser[K, T1],
+	p2 Parser[K, T2],
+	p3 Parser[K, T3],
+) Parser[K, OneOf3[T1, T2, T3]] {
+	return Apply(Alt3(p1, p2, p3), FromEither3[T1, T2, T3])
+}
+func AltOf4[K TK, T1, T2, T3, T4 any](
+	p1 Parser[K, T1],
+	p2 Parser[K, T2],
+	p3 Parser[K, T3],
+	p4 Parser[K, T4],
+) Parser[K, OneOf4[T1, T2, T3, T4]] {
+	return Apply(Alt4(p1, p2, p3, p4), FromEither4[T1, T2, T3, T4])
+}
+func AltOf5[K TK, T1, T2, T3, T4, T5 any](
+	p1 Parser[K, T1],
+	p2 Parser[K, T2],
+	p3 Parser[K, T3],
+	p4 Parser[K, T4],
+	p5 Parser[K, T5],
+) Parser[K, OneOf5[T1, T2, T3, T4, T5]] {
+	return Apply(Alt5(p1, p2, p3, p4, p5), FromEither5[T1, T2, T3, T4, T5])
+}
+
+// AltScOf2 ~ AltScOf5 同 AltSc2 ~ AltSc5, 返回扁平的 OneOfN
+func AltScOf2[K TK, T1, T2 any](
+	p1 Parser[K, T1],
+	p2 Parser[K, T2],
+) Parser[K, OneOf2[T1, T2]] {
+	return Apply(AltSc2(p1, p2), FromEither2[T1, T2])
+}
+func AltScOf3[K TK, T1, T2, T3 any](
+	p1 Parser[K, T1],
+	p2 Parser[K, T2],
+	p3 Parser[K, T3],
+) Parser[K, OneOf3[T1, T2, T3]] {
+	return Apply(AltSc3(p1, p2, p3), FromEither3[T1, T2, T3])
+}
+func AltScOf4[K TK, T1, T2, T3, T4 any](
+	p1 Parser[K, T1],
+	p2 Parser[K, T2],
+	p3 Parser[K, T3],
+	p4 Parser[K, T4],
+) Parser[K, OneOf4[T1, T2, T3, T4]] {
+	return Apply(AltSc4(p1, p2, p3, p4), FromEither4[T1, T2, T3, T4])
+}
+func AltScOf5[K TK, T1, T2, T3, T4, T5 any](
+	p1 Parser[K, T1],
+	p2 Parser[K, T2],
+	p3 Parser[K, T3],
+	p4 Parser[K, T4],
+	p5 Parser[K, T5],
+) Parser[K, OneOf5[T1, T2, T3, T4, T5]] {
+	return Apply(AltSc5(p1, p2, p3, p4, p5), FromEither5[T1, T2, T3, T4, T5])
+}
@@ -1,29 +1,73 @@
 package parsec
 
+import "sort"
+
 // ----------------------------------------------------------------
 // Ambiguity Resolving, 歧义合并器
 // ----------------------------------------------------------------
 
 // Amb :: p[a] -> p[list[a]]
 // Consumes x and merge group result by consumed tokens.
+// 按消费的 token 数分组, 消费最多的组在前, 组内保持 p 的候选顺序
 func Amb[K TK, R any](p Parser[K, R]) Parser[K, []R] {
-	return parser[K, []R](func(toks []Token[K]) Output[K, []R] {
-		branches := p.Parse(toks)
+	return parser[K, []R](func(st *State[K], toks []Token[K]) Output[K, []R] {
+		branches := ParseIn(st, p, toks)
 		if !branches.Success {
 			return failOf[K, R, []R](branches)
 		}
 
-		group := make(map[Token[K]][]Result[K, R])
-		for _, r := range branches.Candidates {
-			k := beginTok(r.next)
-			group[k] = append(group[k], r)
-		}
-
-		xs := make([]Result[K, []R], 0, len(group))
-		for _, vals := range group {
+		groups := groupByRest(branches.Candidates)
+		xs := make([]Result[K, []R], len(groups))
+		for i, vals := range groups {
 			merged := sliceMap(vals, func(v Result[K, R]) R { return v.Val })
-			xs = append(xs, Result[K, []R]{merged, vals[0].next})
+			xs[i] = Result[K, []R]{Val: merged, next: vals[0].next, nodes: vals[0].nodes}
 		}
 		return successWithErr(xs, branches.Error)
 	})
 }
+
+// AmbWith :: p[a] -> (list[a] -> a) -> p[a]
+// 同 Amb, 同一分组的结果使用 merge 合并为一个结果, 只有一个结果的分组不调用 merge
+// e.g. 将歧义的 AST 合并为一个 Ambiguity 节点
+func AmbWith[K TK, R any](p Parser[K, R], merge func([]R) R) Parser[K, R] {
+	return Apply(Amb(p), func(xs []R) R {
+		if len(xs) == 1 {
+			return xs[0]
+		}
+		return merge(xs)
+	})
+}
+
+// Ambiguous 是否存在消费相同 token 的多个结果
+func Ambiguous[K TK, R any](out Output[K, R]) bool {
+	if !out.Success {
+		return false
+	}
+	seen := make(map[int]bool, len(out.Candidates))
+	for _, r := range out.Candidates {
+		if seen[len(r.next)] {
+			return true
+		}
+		seen[len(r.next)] = true
+	}
+	return false
+}
+
+// groupByRest 按剩余 token 数(即消费到的位置)分组, 剩余少(消费多)的在前
+func groupByRest[K TK, R any](xs []Result[K, R]) [][]Result[K, R] {
+	idx := make(map[int]int)
+	var groups [][]Result[K, R]
+	for _, r := range xs {
+		i, ok := idx[len(r.next)]
+		if !ok {
+			i = len(groups)
+			idx[len(r.next)] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], r)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i][0].next) < len(groups[j][0].next)
+	})
+	return groups
+}
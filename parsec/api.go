@@ -14,25 +14,92 @@ type Parser[K TK, R any] interface {
 	Parse([]Token[K]) Output[K, R]
 }
 
+// NewParser p 中调用子 Parser 的 Parse 会开始一次独立的解析, 不继承外层的 Mode 等状态, 需要继承时使用 NewStatefulParser
 func NewParser[K TK, R any](p func([]Token[K]) Output[K, R]) Parser[K, R] {
+	return parser[K, R](func(_ *State[K], toks []Token[K]) Output[K, R] { return p(toks) })
+}
+
+// NewStatefulParser 同 NewParser, p 通过 ParseIn(st, ...) 调用子 Parser, 在当前解析的状态中继续解析
+func NewStatefulParser[K TK, R any](p func(st *State[K], toks []Token[K]) Output[K, R]) Parser[K, R] {
 	return parser[K, R](p)
 }
 
+// State 一次解析的状态, 由最外层的 Parse 创建, 沿组合子向下传递,
+// 每次解析各自一份, 同一个 Parser 可以并发使用
+type State[K TK] struct {
+	mode Mode // 当前解析模式, 见 InMode
+
+	depth    int // 当前 Parser 的嵌套深度, 只在设置了 MaxDepth 时计数
+	maxDepth int // MaxDepth 设置的绝对上限, 0 表示不限制
+	limit    int // MaxDepth 设置的相对上限, 用于错误信息
+
+	build NodeBuilder[K] // 当前的 NodeBuilder, nil 时不构造节点, 见 BuildNodes
+
+	scopes *scope // 当前激活的语法扩展, 见 Scoped
+
+	rules []ruleCall[K] // 正在解析的具名规则, 由外向内, 用于错误的规则栈
+
+	partial *farthest // ParsePartial 期间记录的部分结果, 其他时候为 nil
+
+	beam    int        // WithBeam 设置的宽度, 0 表示不限制
+	pruning *BeamStats // WithBeam 期间的剪枝报告, 其他时候为 nil
+}
+
+// ParseIn 在 st 中解析 p, 用于 NewStatefulParser; st 为 nil 时即 p.Parse(toks)
+func ParseIn[K TK, R any](st *State[K], p Parser[K, R], toks []Token[K]) Output[K, R] {
+	s, ok := p.(stateful[K, R])
+	if !ok || st == nil {
+		return p.Parse(toks)
+	}
+	if st.maxDepth == 0 && st.beam == 0 {
+		return s.parseIn(st, toks)
+	}
+	return parseLimited(st, s, toks)
+}
+
+// parseLimited 设置了 MaxDepth 或 WithBeam 时的 ParseIn
+func parseLimited[K TK, R any](st *State[K], s stateful[K, R], toks []Token[K]) Output[K, R] {
+	if st.maxDepth > 0 {
+		if err := st.enter(toks); err != nil {
+			return fail[K, R](err)
+		}
+		defer st.leave()
+	}
+	out := s.parseIn(st, toks)
+	if out.Success {
+		out.Candidates = prune(st, out.Candidates, restOfResult[K, R])
+	}
+	return out
+}
+
+// stateful 可以在外层的解析状态中解析的 Parser
+type stateful[K TK, R any] interface {
+	parseIn(st *State[K], toks []Token[K]) Output[K, R]
+}
+
 // Parser Impl
-type parser[K TK, R any] func([]Token[K]) Output[K, R]
+type parser[K TK, R any] func(st *State[K], toks []Token[K]) Output[K, R]
 
 func (p parser[K, R]) Parse(toks []Token[K]) Output[K, R] {
-	return p(toks)
+	return p(new(State[K]), toks)
+}
+
+func (p parser[K, R]) parseIn(st *State[K], toks []Token[K]) Output[K, R] {
+	return p(st, toks)
 }
 
 // Output
 // If Success == true, it means that the candidates field is valid, even when it is empty.
 // If Success == false, error will be not null
 // The Error field stores the far-est error that has even been seen, even when tokens are successfully parsed.
+// The Partial field is only set by ParsePartial when parsing fails.
+// The Pruning field is only set by WithBeam when candidates are pruned.
 type Output[K TK, R any] struct {
 	Success    bool
 	Candidates []Result[K, R]
 	*Error
+	Partial *Partial[R]
+	Pruning *BeamStats
 }
 
 func (o Output[K, R]) String() string {
@@ -43,8 +110,9 @@ func (o Output[K, R]) String() string {
 }
 
 type Result[K TK, R any] struct {
-	Val  R
-	next []Token[K] // rest of tokens
+	Val   R
+	next  []Token[K] // rest of tokens
+	nodes *nodes     // CST 模式下构造的节点, 见 BuildNodes
 }
 
 func (r Result[K, R]) String() string {
@@ -53,7 +121,41 @@ func (r Result[K, R]) String() string {
 
 type Error struct {
 	Pos
-	Msg string
+	Msg     string
+	Code    string        // 错误码, 见 ErrCode
+	expects []expectation // 该位置期望的 token, 见 Expected
+	info    *errorInfo    // 不常用的信息, nil 表示没有; 大部分错误只在回溯中比较, 保持 Error 较小
+}
+
+// errorInfo 多个 Error 之间共享, 只读, 修改时复制, 见 withInfo
+type errorInfo struct {
+	cause   error      // 见 ErrWith, Unwrap
+	context *ruleFrame // 出错时的规则栈, 见 Context
+	notes   []Note     // 附注, 见 Notes
+	reach   Pos        // betterError 比较远近的位置, nil 时为 Pos, 见 ApplyE
+}
+
+var noInfo errorInfo
+
+func (e *Error) more() *errorInfo {
+	if e.info == nil {
+		return &noInfo
+	}
+	return e.info
+}
+
+// withInfo 复制 error, 修改 info 的副本
+func (e *Error) withInfo(f func(i *errorInfo)) *Error {
+	x := *e
+	i := *e.more()
+	f(&i)
+	x.info = &i
+	return &x
+}
+
+// Unwrap 返回 ErrWith 设置的 error, 以便使用 errors.Is / errors.As
+func (e *Error) Unwrap() error {
+	return e.more().cause
 }
 
 func (e *Error) Error() string {
@@ -1,20 +1,106 @@
 package parsec
 
+import "errors"
+
 // Apply :: p[a] -> (a -> b) -> p[b]
 // 即 Map, the data structural of v is topological equivalent to syntax structural of p
 func Apply[K TK, From, To any](
 	p Parser[K, From],
 	f func(v From) To,
 ) Parser[K, To] {
-	return parser[K, To](func(toks []Token[K]) Output[K, To] {
-		out := p.Parse(toks)
+	return withEnum(parser[K, To](func(st *State[K], toks []Token[K]) Output[K, To] {
+		out := ParseIn(st, p, toks)
 		if !out.Success {
 			return failOf[K, From, To](out)
 		}
 		xs := make([]Result[K, To], len(out.Candidates))
 		for i, x := range out.Candidates {
-			xs[i] = Result[K, To]{f(x.Val /*, tokenRange(toks, x.next)*/), x.next}
+			xs[i] = Result[K, To]{Val: f(x.Val /*, tokenRange(toks, x.next)*/), next: x.next, nodes: x.nodes}
 		}
 		return successWithErr(xs, out.Error)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, To]) bool) *Error {
+		return EnumIn(st, p, toks, func(x Result[K, From]) bool {
+			return yield(Result[K, To]{Val: f(x.Val), next: x.next, nodes: x.nodes})
+		})
 	})
 }
+
+// ApplyE :: p[a] -> (a -> (b, error)) -> p[b]
+// 即 TryMap, f 返回 error 时该候选结果失败, 错误位置为该结果消费的 token 范围, 错误信息为 err.Error(), 可以用 errors.Is / errors.As 取出 err;
+// 比较远近时按范围之后的位置计算, 同一位置的语法错误合并期望, 保留语义错误的信息, 所有候选结果都失败时 ApplyE 失败
+// e.g. 数值范围检查, 重复的 key
+func ApplyE[K TK, From, To any](
+	p Parser[K, From],
+	f func(v From) (To, error),
+) Parser[K, To] {
+	check := func(toks []Token[K], x Result[K, From]) (Result[K, To], *Error) {
+		v, err := f(x.Val)
+		if err != nil {
+			e := newError(spanOf(toks, x.next), err.Error())
+			e.info = &errorInfo{cause: err, reach: startPos(x.next)}
+			return Result[K, To]{}, e
+		}
+		return Result[K, To]{Val: v, next: x.next, nodes: x.nodes}, nil
+	}
+	return withEnum(parser[K, To](func(st *State[K], toks []Token[K]) Output[K, To] {
+		out := ParseIn(st, p, toks)
+		if !out.Success {
+			return failOf[K, From, To](out)
+		}
+		var xs []Result[K, To]
+		var err *Error
+		for _, x := range out.Candidates {
+			r, e := check(toks, x)
+			if e != nil {
+				err = betterError(err, e)
+			} else {
+				xs = append(xs, r)
+			}
+		}
+		err = betterError(err, out.Error)
+		return newOutput(xs, err, len(xs) != 0)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, To]) bool) *Error {
+		// 与 Parse 相同的合并顺序: 先候选结果的语义错误, 再 p 的错误
+		var err *Error
+		perr := EnumIn(st, p, toks, func(x Result[K, From]) bool {
+			r, e := check(toks, x)
+			if e != nil {
+				err = betterError(err, e)
+				return true
+			}
+			return yield(r)
+		})
+		return betterError(err, perr)
+	})
+}
+
+// Guard :: p[a] -> (a -> bool) -> msg -> p[a]
+// pred 返回 false 时该候选结果失败, 同 ApplyE
+func Guard[K TK, R any](p Parser[K, R], pred func(v R) bool, msg string) Parser[K, R] {
+	return ApplyE(p, func(v R) (R, error) {
+		if pred(v) {
+			return v, nil
+		}
+		return v, errors.New(msg)
+	})
+}
+
+// spanPos 连续多个 token 覆盖的位置
+type spanPos struct{ idx, end, col, ln int }
+
+func (s spanPos) Loc() (int, int, int, int) { return s.idx, s.end, s.col, s.ln }
+
+// spanOf toks 中 rest 之前(已消费)的 token 覆盖的位置
+func spanOf[K TK](toks, rest []Token[K]) Pos {
+	consumed := toks[:len(toks)-len(rest)]
+	if len(consumed) == 0 {
+		return startPos(toks)
+	}
+	first, last := consumed[0], consumed[len(consumed)-1]
+	idx, _, col, ln := first.Loc()
+	_, end, _, _ := last.Loc()
+	if idx < 0 || end < 0 {
+		return first
+	}
+	return spanPos{idx, end, col, ln}
+}
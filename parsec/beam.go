@@ -0,0 +1,106 @@
+package parsec
+
+import "sort"
+
+// ----------------------------------------------------------------
+// Beam Search, 限制候选结果数量
+// ----------------------------------------------------------------
+
+// BeamStats 剪枝报告, 见 WithBeam
+type BeamStats struct {
+	Prunings int // 剪枝次数
+	Pruned   int // 丢弃的候选结果数
+}
+
+// WithBeam :: p[a] -> int -> p[a]
+// p 的整棵子树(包括引用的规则)中每个组合子(及 Seq, Rep 的每一层)的候选结果不超过 k 个,
+// 保留消费 token 最多的结果, 保持原有顺序; k <= 0 时不限制, 只统计子树中 Beam 的剪枝; 可以嵌套, 内层优先;
+// 子树中(包括 Beam)的剪枝报告记录在返回的 Output.Pruning 中, 没有剪枝时为 nil, 内层的报告同时计入外层;
+// 同 InMode, 放在文法的根上即设置整次解析, e.g. out := WithBeam(sentence, 16).Parse(toks); out.Pruning
+func WithBeam[K TK, R any](p Parser[K, R], k int) Parser[K, R] {
+	if k < 0 {
+		k = 0
+	}
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		var stats BeamStats
+		oldBeam, oldStats := st.beam, st.pruning
+		st.beam, st.pruning = k, &stats
+		defer func() { st.beam, st.pruning = oldBeam, oldStats }()
+		out := ParseIn(st, p, toks)
+		if stats != (BeamStats{}) {
+			out.Pruning = &stats
+			if oldStats != nil {
+				oldStats.Prunings += stats.Prunings
+				oldStats.Pruned += stats.Pruned
+			}
+		}
+		return out
+	})
+}
+
+// Beam :: p[a] -> int -> score -> p[a]
+// 保留 p 得分最高的 k 个候选结果, 保持原有顺序, 同分时保留靠前的结果
+// score 为 nil 时按消费的 token 数打分, 可以用来让歧义严重的文法在有限的内存中给出尽力而为的结果;
+// 只在 Beam 所在的位置剪枝, 放在递归的规则上即限制每一层的候选数量, e.g. list.Pattern = Beam(Alt(Seq(x, list), ...), k, nil);
+// 剪枝报告见 WithBeam, e.g. WithBeam(list.Parser(), 0)
+func Beam[K TK, R any](p Parser[K, R], k int, score func(v R, consumed int) float64) Parser[K, R] {
+	if score == nil {
+		score = func(_ R, consumed int) float64 { return float64(consumed) }
+	}
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		out := ParseIn(st, p, toks)
+		if !out.Success || len(out.Candidates) <= k {
+			return out
+		}
+		xs := topK(out.Candidates, k, func(r Result[K, R]) float64 {
+			return score(r.Val, len(toks)-len(r.next))
+		})
+		st.pruned(len(out.Candidates) - len(xs))
+		return successWithErr(xs, out.Error)
+	})
+}
+
+// prune WithBeam 期间限制候选结果数量, 按剩余 token 数保留消费最多的
+func prune[K TK, T any](st *State[K], xs []T, rest func(T) int) []T {
+	if st.beam <= 0 || len(xs) <= st.beam {
+		return xs
+	}
+	ys := topK(xs, st.beam, func(x T) float64 { return -float64(rest(x)) })
+	st.pruned(len(xs) - len(ys))
+	return ys
+}
+
+// pruned WithBeam 期间记录一次剪枝
+func (st *State[K]) pruned(n int) {
+	if st.pruning != nil {
+		st.pruning.Prunings++
+		st.pruning.Pruned += n
+	}
+}
+
+func restOfResult[K TK, R any](r Result[K, R]) int { return len(r.next) }
+func restOfAcc[K TK, R any](x acc[K, R]) int       { return len(x.next) }
+
+// topK 保留得分最高的 k 个, 保持原有顺序
+func topK[T any](xs []T, k int, score func(T) float64) []T {
+	if k < 0 {
+		k = 0
+	}
+	if len(xs) <= k {
+		return xs
+	}
+	idx := make([]int, len(xs))
+	scores := make([]float64, len(xs))
+	for i, x := range xs {
+		idx[i] = i
+		scores[i] = score(x)
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return scores[idx[i]] > scores[idx[j]] })
+	idx = idx[:k]
+	sort.Ints(idx)
+	ys := make([]T, k)
+	for i, j := range idx {
+		ys[i] = xs[j]
+	}
+	return ys
+}
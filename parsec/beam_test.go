@@ -0,0 +1,139 @@
+package parsec
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestBeam(t *testing.T) {
+	num := Apply(Tok(bNum), func(t Token[benchKind]) int {
+		n, _ := strconv.Atoi(t.Lexeme())
+		return n
+	})
+	sum := func(xs []int) int {
+		n := 0
+		for _, x := range xs {
+			n += x
+		}
+		return n
+	}
+	toks := benchLex("1 2 3 4")
+
+	for _, tt := range []struct {
+		name   string
+		p      Parser[benchKind, []int]
+		expect string
+	}{
+		// 默认按消费的 token 数打分
+		{"consumed", Beam(Rep(num), 2, nil), "[[1 2 3 4] [1 2 3]]"},
+		// 自定义打分: 和为奇数的优先
+		{"score", Beam(Rep(num), 2, func(xs []int, _ int) float64 {
+			return float64(sum(xs) % 2)
+		}), "[[1 2] [1]]"},
+		{"unlimited", Beam(Rep(num), 10, nil), "[[1 2 3 4] [1 2 3] [1 2] [1] []]"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.p.Parse(toks)
+			if !out.Success {
+				t.Fatal(out.Error)
+			}
+			actual := fmt.Sprint(sliceMap(out.Candidates, func(r Result[benchKind, []int]) []int { return r.Val }))
+			if actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func TestBeamStats(t *testing.T) {
+	// 每个 num 有两种解释, n 个 token 共 2^(n+1)-1 个候选结果
+	num := Apply(Tok(bNum), func(t Token[benchKind]) string { return t.Lexeme() })
+	item := Alt(num, Apply(num, func(s string) string { return "#" + s }))
+	toks := benchLex(benchInput("1", " ", 10))
+
+	if n := len(Rep(item).Parse(toks).Candidates); n != 1<<11-1 {
+		t.Fatalf("expect %d candidates actual %d", 1<<11-1, n)
+	}
+
+	// 放在递归的规则上, 每一层都不超过 8 个候选结果
+	list := NewRule[benchKind, []string]()
+	list.Pattern = Beam(Alt(
+		Apply(Seq2(item, list.Parser()), func(c Cons[string, []string]) []string { return append([]string{c.Car}, c.Cdr...) }),
+		Succ[benchKind, []string](nil),
+	), 8, nil)
+
+	// 不限制宽度, 只统计 Beam 的剪枝
+	out := WithBeam(list.Parser(), 0).Parse(toks)
+	if len(out.Candidates) > 8 {
+		t.Errorf("expect at most 8 candidates actual %d", len(out.Candidates))
+	}
+	stats := out.Pruning
+	if stats == nil || stats.Prunings == 0 || stats.Pruned == 0 {
+		t.Fatalf("expect pruning report actual %v", stats)
+	}
+	// 仍然能得到完整的解析结果
+	if out := ExpectEOF(out); !out.Success || len(out.Candidates[0].Val) != 10 {
+		t.Errorf("expect best-effort result actual %v", out.Error)
+	}
+
+	// 每次解析各自报告, 可以并发
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if actual := WithBeam(list.Parser(), 0).Parse(toks).Pruning; actual == nil || *actual != *stats {
+				t.Errorf("expect %+v actual %v", *stats, actual)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 没有剪枝时不报告, 不在 WithBeam 中时不统计
+	if out := WithBeam(Beam(Rep(item), 1<<11, nil), 0).Parse(toks); len(out.Candidates) != 1<<11-1 || out.Pruning != nil {
+		t.Errorf("unexpected %d %v", len(out.Candidates), out.Pruning)
+	}
+	if out := list.Parse(toks); out.Pruning != nil {
+		t.Errorf("unexpected %v", out.Pruning)
+	}
+	if _, err := ExpectSingleResult(ExpectEOF(LongestMatch[benchKind, []string](Beam(Rep(item), 1, nil)).Parse(toks))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithBeam(t *testing.T) {
+	num := Apply(Tok(bNum), func(t Token[benchKind]) string { return t.Lexeme() })
+	item := Alt(num, Apply(num, func(s string) string { return "#" + s }))
+	toks := benchLex(benchInput("1", " ", 10))
+
+	// 每个组合子, Rep 的每一层都不超过 k 个候选结果, 保留消费最多的
+	out := WithBeam(Rep(item), 4).Parse(toks)
+	if len(out.Candidates) != 4 {
+		t.Errorf("expect 4 candidates actual %d", len(out.Candidates))
+	}
+	if out := ExpectEOF(out); !out.Success || len(out.Candidates[0].Val) != 10 {
+		t.Errorf("expect best-effort result actual %v", out.Error)
+	}
+	if out.Pruning == nil || out.Pruning.Prunings == 0 || out.Pruning.Pruned == 0 {
+		t.Errorf("expect pruning report actual %v", out.Pruning)
+	}
+
+	// 内层优先, 内层的报告计入外层
+	var inner *BeamStats
+	rest := WithBeam(Rep(item), 2)
+	p := WithBeam(Seq(item, Apply(NewStatefulParser(func(st *State[benchKind], toks []Token[benchKind]) Output[benchKind, []string] {
+		out := ParseIn(st, rest, toks)
+		inner = out.Pruning
+		return out
+	}), func(xs []string) string { return fmt.Sprint(len(xs)) })), 0)
+	out = p.Parse(toks)
+	// item 的两种解释, 各自接 Rep 保留的 2 个结果
+	if len(out.Candidates) != 4 || out.Pruning == nil || inner == nil || out.Pruning.Prunings < inner.Prunings {
+		t.Errorf("unexpected %v %v %v", out.Candidates, out.Pruning, inner)
+	}
+	if out := WithBeam(Rep(item), 1<<12).Parse(toks); out.Pruning != nil || len(out.Candidates) != 1<<11-1 {
+		t.Errorf("unexpected %d %v", len(out.Candidates), out.Pruning)
+	}
+}
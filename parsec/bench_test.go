@@ -0,0 +1,195 @@
+package parsec
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/goghcrow/go-parsec/lexer"
+)
+
+// go test -run ^$ -bench . -benchmem
+
+type benchKind int
+
+const (
+	bNum benchKind = iota + 1
+	bStr
+	bOp
+	bSpace
+)
+
+func (k benchKind) String() string {
+	return map[benchKind]string{bNum: "<num>", bStr: "<str>", bOp: "<op>", bSpace: "<space>"}[k]
+}
+
+var benchLexer = lexer.BuildLexer(func(lex *lexer.Lexicon[benchKind]) {
+	lex.Regex(bSpace, `\s+`).Skip()
+	lex.Regex(bNum, `\d+`)
+	lex.Regex(bStr, `"[^"]*"`)
+	lex.Regex(bOp, `[-+*/()\[\]{},:^]`)
+})
+
+func benchLex(s string) []Token[benchKind] {
+	toks := benchLexer.MustLex(s)
+	xs := make([]Token[benchKind], len(toks))
+	for i, t := range toks {
+		xs[i] = t
+	}
+	return xs
+}
+
+func op(s string) Parser[benchKind, Token[benchKind]] { return Str[benchKind](s) }
+
+// 四则运算
+func benchCalc() Parser[benchKind, int] {
+	exp := NewRule[benchKind, int]()
+	num := Apply(Tok(bNum), func(t Token[benchKind]) int {
+		n, _ := strconv.Atoi(t.Lexeme())
+		return n
+	})
+	term := AltSc(num, KMid(op("("), exp.Parser(), op(")")))
+	binary := func(p Parser[benchKind, int], ops ...string) Parser[benchKind, int] {
+		opps := make([]Parser[benchKind, Token[benchKind]], len(ops))
+		for i, o := range ops {
+			opps[i] = op(o)
+		}
+		return LRecSc(p, Seq2(AltSc(opps...), p), func(l int, r Cons[Token[benchKind], int]) int {
+			switch r.Car.Lexeme() {
+			case "+":
+				return l + r.Cdr
+			case "-":
+				return l - r.Cdr
+			case "*":
+				return l * r.Cdr
+			default:
+				if r.Cdr == 0 {
+					return 0
+				}
+				return l / r.Cdr
+			}
+		})
+	}
+	exp.Pattern = binary(binary(term, "*", "/"), "+", "-")
+	return exp
+}
+
+// JSON-like: value = num | str | "[" [value {"," value}] "]" | "{" [str ":" value {"," ...}] "}"
+func benchJSON() Parser[benchKind, int] {
+	value := NewRule[benchKind, int]()
+	count := func(xs []int) int {
+		n := 1
+		for _, x := range xs {
+			n += x
+		}
+		return n
+	}
+	one := func(Token[benchKind]) int { return 1 }
+	pair := KRight(Seq(Tok(bStr), op(":")), value.Parser())
+	array := Apply(KMid(op("["), SepBySc(value.Parser(), op(",")), op("]")), count)
+	object := Apply(KMid(op("{"), SepBySc(pair, op(",")), op("}")), count)
+	value.Pattern = AltSc(Apply(Tok(bNum), one), Apply(Tok(bStr), one), array, object)
+	return value
+}
+
+// 同 benchJSON, 规则都是具名规则, 错误记录规则栈
+func benchNamedJSON() Parser[benchKind, int] {
+	value := NewRule[benchKind, int]()
+	array := NewRule[benchKind, int]()
+	object := NewRule[benchKind, int]()
+	count := func(xs []int) int {
+		n := 1
+		for _, x := range xs {
+			n += x
+		}
+		return n
+	}
+	one := func(Token[benchKind]) int { return 1 }
+	pair := KRight(Seq(Tok(bStr), op(":")), value.Parser())
+	array.SetPattern("array", Apply(KMid(op("["), SepBySc(value.Parser(), op(",")), op("]")), count))
+	object.SetPattern("object", Apply(KMid(op("{"), SepBySc(pair, op(",")), op("}")), count))
+	value.SetPattern("value", AltSc(Apply(Tok(bNum), one), Apply(Tok(bStr), one), array.Parser(), object.Parser()))
+	return value
+}
+
+// 深度嵌套: x = "(" x ")" | num
+func benchDeep() Parser[benchKind, int] {
+	x := NewRule[benchKind, int]()
+	x.Pattern = AltSc(
+		Apply(Tok(bNum), func(Token[benchKind]) int { return 0 }),
+		Apply(KMid(op("("), x.Parser(), op(")")), func(n int) int { return n + 1 }),
+	)
+	return x
+}
+
+func benchInput(unit string, sep string, n int) string {
+	xs := make([]string, n)
+	for i := range xs {
+		xs[i] = unit
+	}
+	return strings.Join(xs, sep)
+}
+
+func benchmark[R any](b *testing.B, p Parser[benchKind, R], input string) {
+	toks := benchLex(input)
+	if out := ExpectEOF(p.Parse(toks)); !out.Success {
+		b.Fatal(out.Error)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Parse(toks)
+	}
+}
+
+func BenchmarkCalc(b *testing.B) {
+	benchmark(b, benchCalc(), benchInput("1 + 2 * (3 - 4) / 5", " - ", 100))
+}
+
+func BenchmarkJSON(b *testing.B) {
+	obj := `{"a": 1, "b": [1, 2, 3, "x"], "c": {"d": [4, 5]}}`
+	benchmark(b, benchJSON(), "["+benchInput(obj, ", ", 100)+"]")
+}
+
+func BenchmarkNamedJSON(b *testing.B) {
+	obj := `{"a": 1, "b": [1, 2, 3, "x"], "c": {"d": [4, 5]}}`
+	benchmark(b, benchNamedJSON(), "["+benchInput(obj, ", ", 100)+"]")
+}
+
+func BenchmarkDeep(b *testing.B) {
+	benchmark(b, benchDeep(), strings.Repeat("(", 500)+"1"+strings.Repeat(")", 500))
+}
+
+func BenchmarkSeq(b *testing.B) {
+	ps := make([]Parser[benchKind, Token[benchKind]], 200)
+	for i := range ps {
+		ps[i] = Tok(bNum)
+	}
+	benchmark(b, Seq(ps...), benchInput("1", " ", 200))
+}
+
+func BenchmarkRepSc(b *testing.B) {
+	benchmark(b, RepSc(Tok(bNum)), benchInput("1", " ", 1000))
+}
+
+func BenchmarkRep(b *testing.B) {
+	benchmark(b, Rep(Tok(bNum)), benchInput("1", " ", 200))
+}
+
+// 只需要最长的结果
+func BenchmarkRepFirst(b *testing.B) {
+	toks := benchLex(benchInput("1", " ", 1000))
+	p := Rep(Tok(bNum))
+	b.Run("Parse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ExpectEOF(p.Parse(toks))
+		}
+	})
+	b.Run("ParseFirst", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ParseFirst(p, toks)
+		}
+	})
+}
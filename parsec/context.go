@@ -0,0 +1,116 @@
+package parsec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// Error Context, 错误的规则栈与附注
+// ----------------------------------------------------------------
+
+// Frame 出错时活跃的命名规则(SetPattern 设置名称的 SyntaxRule)
+type Frame struct {
+	Rule string
+	Pos  Pos // 规则开始的位置, 输入结束时为 EOFPos
+}
+
+// Context 出错时的规则栈, 由外向内
+func (e *Error) Context() []Frame {
+	var xs []Frame
+	for f := e.more().context; f != nil; f = f.outer {
+		xs = append(xs, Frame{Rule: f.name, Pos: f.pos})
+	}
+	for i, j := 0, len(xs)-1; i < j; i, j = i+1, j-1 {
+		xs[i], xs[j] = xs[j], xs[i]
+	}
+	return xs
+}
+
+// Notes 错误的附注, 指向与错误相关的另一个位置, e.g. 未闭合的括号, Source.Render 会一并输出
+func (e *Error) Notes() []Note {
+	return e.more().notes
+}
+
+// Detail 带有期望, 附注与规则栈的错误信息, Error() 保持原有格式
+// e.g. 1:9: expected `,` or `)` to close `(` opened at 1:5, while parsing call > args
+func (e *Error) Detail() string {
+	var b strings.Builder
+	b.WriteString(shortLoc(e.Pos) + ": ")
+	if len(e.expects) == 0 {
+		b.WriteString(e.Msg)
+	} else {
+		var xs []string
+		for _, x := range e.expects {
+			if s := x.String(); !contains(xs, s) {
+				xs = append(xs, s)
+			}
+		}
+		b.WriteString("expected " + strings.Join(xs, " or "))
+	}
+	for i, n := range e.more().notes {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(" " + n.Msg)
+	}
+	if names := e.more().context.names(); len(names) != 0 {
+		b.WriteString(", while parsing " + strings.Join(names, " > "))
+	}
+	return b.String()
+}
+
+// WithNote :: p[a] -> pos -> msg -> p[a]
+// p 失败时为错误添加附注
+func WithNote[K TK, R any](p Parser[K, R], pos Pos, msg string) Parser[K, R] {
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		out := ParseIn(st, p, toks)
+		if !out.Success {
+			out.Error = out.Error.note(Note{Pos: pos, Msg: msg})
+		}
+		return out
+	})
+}
+
+// note 复制 error 并添加附注
+func (e *Error) note(n Note) *Error {
+	if e == nil || containsNote(e.more().notes, n) {
+		return e
+	}
+	return e.withInfo(func(i *errorInfo) { i.notes = concat(i.notes, n) })
+}
+
+func containsNote(xs []Note, n Note) bool {
+	for _, x := range xs {
+		if x == n {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(xs []string, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// startPos 规则开始的位置
+func startPos[K TK](toks []Token[K]) Pos {
+	if len(toks) == 0 {
+		return EOFPos
+	}
+	return toks[0]
+}
+
+// shortLoc 行:列, e.g. 1:5
+func shortLoc(pos Pos) string {
+	if vp, ok := pos.(VirtualPos); ok {
+		return string(vp)
+	}
+	_, _, col, ln := pos.Loc()
+	return fmt.Sprintf("%d:%d", ln+1, col+1)
+}
@@ -0,0 +1,58 @@
+package parsec
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestErrorContext(t *testing.T) {
+	// call = num "(" args ")", args = expr {"," expr}, expr = call | num
+	num := Apply(Tok(bNum), func(t Token[benchKind]) string { return t.Lexeme() })
+	call := NewRule[benchKind, string]()
+	args := NewRule[benchKind, []string]()
+	expr := NewRule[benchKind, string]()
+	call.SetPattern("call", Apply(Seq2(num, Delimited(op("("), args.Parser(), op(")"))),
+		func(v Cons[string, []string]) string { return v.Car + "(" + strings.Join(v.Cdr, ", ") + ")" }))
+	args.SetPattern("args", SepBy1Sc(expr.Parser(), op(",")))
+	expr.SetPattern("expr", AltSc(call.Parser(), num))
+
+	for _, tt := range []struct {
+		input   string
+		detail  string
+		context string
+	}{
+		{"1 (2, 3 (4)", "end of input: expected `,` or `)` to close `(` opened at 1:3, while parsing call > args",
+			"[{call 1:1} {args 1:4}]"},
+		// 同一位置的错误保留第一个错误的规则栈, 即 4 尝试作为 call 解析
+		{"1 (2, 3 (4 ]", "1:12: expected `(` or `,` or `)` to close `(` opened at 1:9, while parsing call > args > expr > call > args > expr > call",
+			"[{call 1:1} {args 1:4} {expr 1:7} {call 1:7} {args 1:10} {expr 1:10} {call 1:10}]"},
+		{"1 (2, )", "1:7: expected <num>, while parsing call > args > expr > call",
+			"[{call 1:1} {args 1:4} {expr 1:7} {call 1:7}]"},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			toks := benchLex(tt.input)
+			out := ExpectEOF(call.Parse(toks))
+			if out.Success {
+				t.Fatalf("expect error")
+			}
+			if actual := out.Detail(); actual != tt.detail {
+				t.Errorf("expect %s actual %s", tt.detail, actual)
+			}
+			var frames []string
+			for _, f := range out.Context() {
+				frames = append(frames, fmt.Sprintf("{%s %s}", f.Rule, shortLoc(f.Pos)))
+			}
+			if actual := "[" + strings.Join(frames, " ") + "]"; actual != tt.context {
+				t.Errorf("expect %s actual %s", tt.context, actual)
+			}
+		})
+	}
+
+	// Source.Render 输出附注
+	src := NewSource("call.txt", "1 (2, 3 (4 ]")
+	out := ExpectEOF(call.Parse(benchLex("1 (2, 3 (4 ]")))
+	if s := src.Render(out.Error, false); !strings.Contains(s, "to close `(` opened at 1:9") {
+		t.Errorf("expect note\n%s", s)
+	}
+}
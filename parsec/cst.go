@@ -0,0 +1,114 @@
+package parsec
+
+// ----------------------------------------------------------------
+// Concrete Syntax Tree
+// ----------------------------------------------------------------
+
+// NodeBuilder 具名规则(见 SyntaxRule.SetPattern, Grammar)成功时构造节点,
+// toks 为规则消费的 token, rest 为之后剩余的 token, children 为子树中具名规则构造的节点(按顺序);
+// 节点类型见 cst 包
+type NodeBuilder[K TK] func(rule string, toks, rest []Token[K], children []any) any
+
+// BuildNodes :: p[a] -> NodeBuilder -> p[a]
+// p 的整棵子树(包括引用的规则)在 CST 模式下解析, 不需要 Apply 即可得到语法树, 结果中的节点见 Nodes;
+// 节点随结果传递, 失败的分支中构造的节点会随分支丢弃
+func BuildNodes[K TK, R any](p Parser[K, R], build NodeBuilder[K]) Parser[K, R] {
+	return withEnum(parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		old := st.build
+		st.build = build
+		defer func() { st.build = old }()
+		return ParseIn(st, p, toks)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+		old := st.build
+		st.build = build
+		defer func() { st.build = old }()
+		return EnumIn(st, p, toks, func(r Result[K, R]) bool {
+			// yield 会继续解析 p 之后的部分, 需要恢复外层的设置
+			st.build = old
+			defer func() { st.build = build }()
+			return yield(r)
+		})
+	})
+}
+
+// MapNodes :: p[a] -> (node -> node) -> p[a]
+// CST 模式下对 p 的结果中的顶层节点应用 f, e.g. 为节点标注字段名, 见 cst.Field; 非 CST 模式下即 p
+func MapNodes[K TK, R any](p Parser[K, R], f func(any) any) Parser[K, R] {
+	mapNodes := func(r Result[K, R]) Result[K, R] {
+		var ns *nodes
+		for _, n := range r.nodes.slice() {
+			ns = ns.push(f(n))
+		}
+		r.nodes = ns
+		return r
+	}
+	return withEnum(parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		out := ParseIn(st, p, toks)
+		if out.Success && st.build != nil {
+			out.Candidates = sliceMap(out.Candidates, mapNodes)
+		}
+		return out
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+		return EnumIn(st, p, toks, func(r Result[K, R]) bool {
+			return yield(mapNodes(r))
+		})
+	})
+}
+
+// Nodes 返回 BuildNodes 期间该结果构造的顶层节点, 即结果消费的 token 中最外层的具名规则
+func Nodes[K TK, R any](r Result[K, R]) []any {
+	return r.nodes.slice()
+}
+
+// nodes 结果携带的节点, 持久化链表, 同 plist, 非 CST 模式下始终为 nil
+type nodes struct {
+	node any
+	prev *nodes
+	n    int
+}
+
+func (l *nodes) push(v any) *nodes {
+	n := 1
+	if l != nil {
+		n = l.n + 1
+	}
+	return &nodes{node: v, prev: l, n: n}
+}
+
+// concat 在 l 之后追加 r 的节点, 共享 l
+func (l *nodes) concat(r *nodes) *nodes {
+	if r == nil {
+		return l
+	}
+	if l == nil {
+		return r
+	}
+	return l.concat(r.prev).push(r.node)
+}
+
+func (l *nodes) slice() []any {
+	if l == nil {
+		return nil
+	}
+	xs := make([]any, l.n)
+	for p := l; p != nil; p = p.prev {
+		xs[p.n-1] = p.node
+	}
+	return xs
+}
+
+// buildNode CST 模式下将结果消费的 token 与子节点替换为具名规则 name 的节点
+func buildNode[K TK, R any](st *State[K], name string, toks []Token[K], r Result[K, R]) Result[K, R] {
+	if st.build == nil || name == "" {
+		return r
+	}
+	n := st.build(name, toks[:len(toks)-len(r.next)], r.next, r.nodes.slice())
+	r.nodes = (*nodes)(nil).push(n)
+	return r
+}
+
+// after 结果之前的部分(e.g. Seq 之前的元素)携带的节点
+func (r Result[K, R]) after(prev *nodes) Result[K, R] {
+	r.nodes = prev.concat(r.nodes)
+	return r
+}
@@ -0,0 +1,221 @@
+// Package cst 通用的具体语法树(CST)
+//
+// 以 CST 模式解析时, 具名规则(见 SyntaxRule.SetPattern, Grammar)成功时自动构造 Node, 不需要编写 Apply,
+// 未命名的规则与组合子不构造节点, 其中的 token 与子节点归属于外层的具名规则;
+// 可以在已有的文法上直接编写格式化, lint 等工具, 类似 tree-sitter
+//
+//	g := parsec.NewGrammar[K]()
+//	...
+//	root, err := cst.Parse(start, toks)
+//	fmt.Println(root)             // S-expression
+//	bs, err := json.Marshal(root) // JSON
+//	root.Walk(func(n *cst.Node[K]) bool { ... })
+//	cst.Matches(cst.MustCompile(`(call name: (ident) @fn)`), root) // 查询, 见 Query
+package cst
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+// Span 节点覆盖的 token 下标范围 [Start, End), 下标相对于解析的整个 token 序列
+type Span struct {
+	Start, End int
+}
+
+func (s Span) Len() int { return s.End - s.Start }
+
+type Node[K parsec.TK] struct {
+	Rule     string            // 规则名, 合成的根节点为空
+	Children []*Node[K]        // 具名子规则的节点, 按位置排列
+	Tokens   []parsec.Token[K] // 节点覆盖的所有 token, 包括子节点的
+	Span     Span
+	Field    string // 在父节点中的字段名, 见 Field
+}
+
+// Item 节点的直接成员, 子节点或者不属于任何子节点的 token, 二者只有一个非空
+type Item[K parsec.TK] struct {
+	Node  *Node[K]
+	Token parsec.Token[K]
+}
+
+// Build :: p[a] -> p[a]
+// 以 CST 模式解析 p, 结果中的节点见 Nodes; total 为整个 token 序列的长度, 用来计算 Span
+func Build[K parsec.TK, R any](p parsec.Parser[K, R], total int) parsec.Parser[K, R] {
+	return parsec.BuildNodes(p, func(rule string, toks, rest []parsec.Token[K], children []any) any {
+		end := total - len(rest)
+		n := &Node[K]{
+			Rule:     rule,
+			Children: make([]*Node[K], len(children)),
+			Tokens:   toks,
+			Span:     Span{end - len(toks), end},
+		}
+		for i, c := range children {
+			n.Children[i] = c.(*Node[K])
+		}
+		return n
+	})
+}
+
+// Field :: p[a] -> p[a]
+// 将 p 中顶层具名规则的节点标注为父节点的字段 name, 查询中可以使用 name: 匹配, 见 Query
+// e.g. Seq2(Field("name", ident), Field("args", args))
+func Field[K parsec.TK, R any](name string, p parsec.Parser[K, R]) parsec.Parser[K, R] {
+	return parsec.MapNodes(p, func(x any) any {
+		// 节点可能被多个候选结果共享, 标注副本
+		n := *x.(*Node[K])
+		n.Field = name
+		return &n
+	})
+}
+
+// Nodes 返回 Build 期间结果 r 中顶层具名规则的节点
+func Nodes[K parsec.TK, R any](r parsec.Result[K, R]) []*Node[K] {
+	xs := parsec.Nodes(r)
+	ns := make([]*Node[K], len(xs))
+	for i, x := range xs {
+		ns[i] = x.(*Node[K])
+	}
+	return ns
+}
+
+// Parse 以 CST 模式解析 toks, 必须消费所有 token, 并且只有一个结果;
+// p 为具名规则时返回该规则的节点, 否则返回 Rule 为空的合成根节点, 子节点为顶层具名规则的节点
+func Parse[K parsec.TK, R any](p parsec.Parser[K, R], toks []parsec.Token[K]) (*Node[K], error) {
+	out := parsec.ExpectEOF(Build(p, len(toks)).Parse(toks))
+	if _, err := parsec.ExpectSingleResult(out); err != nil {
+		return nil, err
+	}
+	return root(out.Candidates[0], toks), nil
+}
+
+// ParseAll 同 Parse, 返回歧义文法的所有语法树
+func ParseAll[K parsec.TK, R any](p parsec.Parser[K, R], toks []parsec.Token[K]) ([]*Node[K], error) {
+	out := parsec.ExpectEOF(Build(p, len(toks)).Parse(toks))
+	if !out.Success {
+		return nil, out.Error
+	}
+	xs := make([]*Node[K], len(out.Candidates))
+	for i, r := range out.Candidates {
+		xs[i] = root(r, toks)
+	}
+	return xs, nil
+}
+
+func root[K parsec.TK, R any](r parsec.Result[K, R], toks []parsec.Token[K]) *Node[K] {
+	ns := Nodes(r)
+	if len(ns) == 1 && ns[0].Span.Len() == len(toks) {
+		return ns[0]
+	}
+	return &Node[K]{Children: ns, Tokens: toks, Span: Span{0, len(toks)}}
+}
+
+// Items 按位置交替返回子节点与子节点之间的 token, e.g. 格式化时需要输出的操作符, 括号
+func (n *Node[K]) Items() []Item[K] {
+	var xs []Item[K]
+	i := n.Span.Start
+	for _, c := range n.Children {
+		for ; i < c.Span.Start; i++ {
+			xs = append(xs, Item[K]{Token: n.Tokens[i-n.Span.Start]})
+		}
+		xs = append(xs, Item[K]{Node: c})
+		if c.Span.End > i {
+			i = c.Span.End
+		}
+	}
+	for ; i < n.Span.End; i++ {
+		xs = append(xs, Item[K]{Token: n.Tokens[i-n.Span.Start]})
+	}
+	return xs
+}
+
+// Text 节点覆盖的 token 的 lexeme, 以空格分隔
+func (n *Node[K]) Text() string {
+	xs := make([]string, len(n.Tokens))
+	for i, t := range n.Tokens {
+		xs[i] = t.Lexeme()
+	}
+	return strings.Join(xs, " ")
+}
+
+// Walk 先序遍历 n 的子树, f 返回 false 时不再遍历该节点的子节点, 同 ast.Inspect
+func (n *Node[K]) Walk(f func(*Node[K]) bool) {
+	if !f(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(f)
+	}
+}
+
+// FindAll 先序返回子树中(包括 n)所有规则名为 rule 的节点
+func (n *Node[K]) FindAll(rule string) []*Node[K] {
+	var xs []*Node[K]
+	n.Walk(func(c *Node[K]) bool {
+		if c.Rule == rule {
+			xs = append(xs, c)
+		}
+		return true
+	})
+	return xs
+}
+
+// SExpr S-expression, 子节点之间的 token 输出为带引号的 lexeme, 合成的根节点没有规则名, 字段输出为 name: 前缀
+// e.g. (expr (term "1") "+" (term "(" (expr (term "2")) ")")), (call name: (ident "f") "(" ")")
+func (n *Node[K]) SExpr() string {
+	var b strings.Builder
+	n.sexpr(&b)
+	return b.String()
+}
+
+func (n *Node[K]) sexpr(b *strings.Builder) {
+	b.WriteString("(")
+	b.WriteString(n.Rule)
+	for i, it := range n.Items() {
+		if i > 0 || n.Rule != "" {
+			b.WriteString(" ")
+		}
+		if it.Node != nil {
+			if it.Node.Field != "" {
+				b.WriteString(it.Node.Field)
+				b.WriteString(": ")
+			}
+			it.Node.sexpr(b)
+		} else {
+			b.WriteString(strconv.Quote(it.Token.Lexeme()))
+		}
+	}
+	b.WriteString(")")
+}
+
+func (n *Node[K]) String() string { return n.SExpr() }
+
+type jsonNode struct {
+	Rule  string `json:"rule"`
+	Field string `json:"field,omitempty"`
+	Span  [2]int `json:"span"`
+	Items []any  `json:"items"`
+}
+
+type jsonToken struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+// MarshalJSON items 同 Items, 子节点为对象, token 为 {"kind", "text"}
+// e.g. {"rule":"term","span":[0,1],"items":[{"kind":"number","text":"1"}]}
+func (n *Node[K]) MarshalJSON() ([]byte, error) {
+	items := n.Items()
+	xs := make([]any, len(items))
+	for i, it := range items {
+		if it.Node != nil {
+			xs[i] = it.Node
+		} else {
+			xs[i] = jsonToken{it.Token.Kind().String(), it.Token.Lexeme()}
+		}
+	}
+	return json.Marshal(jsonNode{n.Rule, n.Field, [2]int{n.Span.Start, n.Span.End}, xs})
+}
@@ -0,0 +1,249 @@
+package cst
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goghcrow/go-parsec/lexer"
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+type kind int
+
+const (
+	kNum kind = iota + 1
+	kIdent
+	kAdd
+	kLParen
+	kRParen
+	kComma
+	kSpace
+)
+
+func (k kind) String() string {
+	return map[kind]string{kNum: "number", kIdent: "ident", kAdd: "+", kLParen: "(", kRParen: ")", kComma: ",", kSpace: "<space>"}[k]
+}
+
+var testLexer = lexer.BuildLexer(func(lex *lexer.Lexicon[kind]) {
+	lex.Regex(kSpace, `\s+`).Skip()
+	lex.Regex(kNum, `\d+`)
+	lex.Regex(kIdent, lexer.RegIdent)
+	lex.Str(kAdd, "+")
+	lex.Str(kLParen, "(")
+	lex.Str(kRParen, ")")
+	lex.Str(kComma, ",")
+})
+
+func lex(s string) []parsec.Token[kind] {
+	toks := testLexer.MustLex(s)
+	xs := make([]parsec.Token[kind], len(toks))
+	for i, t := range toks {
+		xs[i] = t
+	}
+	return xs
+}
+
+// discard CST 模式下不需要语义值
+func discard[R any](p parsec.Parser[kind, R]) parsec.Parser[kind, struct{}] {
+	return parsec.Apply(p, func(R) struct{} { return struct{}{} })
+}
+
+// expr = term {'+' term}
+// term = NUMBER | call | '(' expr ')'
+// call = IDENT '(' [expr] ')'
+func grammar() *parsec.SyntaxRule[kind, struct{}] {
+	g := parsec.NewGrammar[kind]()
+	expr := parsec.Define[kind, struct{}](g, "expr")
+	term := parsec.Define[kind, struct{}](g, "term")
+	call := parsec.Define[kind, struct{}](g, "call")
+	expr.Pattern = discard(parsec.Seq2(term.Parser(), parsec.RepSc(parsec.Seq2(parsec.Tok(kAdd), term.Parser()))))
+	term.Pattern = parsec.Alt(
+		discard(parsec.Tok(kNum)),
+		call.Parser(),
+		parsec.KMid(parsec.Tok(kLParen), expr.Parser(), parsec.Tok(kRParen)),
+	)
+	call.Pattern = discard(parsec.Seq4(parsec.Tok(kIdent), parsec.Tok(kLParen), parsec.Opt(expr.Parser()), parsec.Tok(kRParen)))
+	g.MustBuild()
+	return expr
+}
+
+func TestSExpr(t *testing.T) {
+	expr := grammar()
+	for _, tt := range []struct {
+		input  string
+		expect string
+	}{
+		{"1", `(expr (term "1"))`},
+		{"1 + 2", `(expr (term "1") "+" (term "2"))`},
+		{"(1 + 2) + 3", `(expr (term "(" (expr (term "1") "+" (term "2")) ")") "+" (term "3"))`},
+		{"f()", `(expr (term (call "f" "(" ")")))`},
+		{"f(1+g(2))", `(expr (term (call "f" "(" (expr (term "1") "+" (term (call "g" "(" (expr (term "2")) ")"))) ")")))`},
+		{"1 +", "Nothing to consume expect `number` in end of input"},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := Parse(expr.Parser(), lex(tt.input))
+			var actual string
+			if err != nil {
+				actual = err.Error()
+			} else {
+				actual = n.SExpr()
+			}
+			if actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	n, err := Parse(grammar().Parser(), lex("1 + f()"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"rule":"expr","span":[0,5],"items":[` +
+		`{"rule":"term","span":[0,1],"items":[{"kind":"number","text":"1"}]},` +
+		`{"kind":"+","text":"+"},` +
+		`{"rule":"term","span":[2,5],"items":[{"rule":"call","span":[2,5],"items":[` +
+		`{"kind":"ident","text":"f"},{"kind":"(","text":"("},{"kind":")","text":")"}]}]}]}`
+	if string(bs) != expect {
+		t.Errorf("expect %s actual %s", expect, bs)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	n, err := Parse(grammar().Parser(), lex("1 + f(2 + 3)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var xs []string
+	n.Walk(func(n *Node[kind]) bool {
+		xs = append(xs, n.Rule+":"+n.Text())
+		return n.Rule != "call"
+	})
+	expect := "expr:1 + f ( 2 + 3 ) term:1 term:f ( 2 + 3 ) call:f ( 2 + 3 )"
+	if actual := strings.Join(xs, " "); actual != expect {
+		t.Errorf("expect %s actual %s", expect, actual)
+	}
+	if terms := n.FindAll("term"); len(terms) != 4 || terms[3].Span != (Span{6, 7}) {
+		t.Errorf("expect 4 terms actual %v", terms)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	n, err := Parse(grammar().Parser(), lex("1 + f(2)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCursor(n)
+	if c.GotoParent() || c.GotoNextSibling() || c.Parent() != nil {
+		t.Fatalf("expect cursor stay at root")
+	}
+	if !c.GotoFirstChild() || c.Node().Text() != "1" || c.Parent() != n {
+		t.Fatalf("expect first term actual %v", c.Node())
+	}
+	if c.GotoPrevSibling() || !c.GotoNextSibling() || c.Node().Text() != "f ( 2 )" || c.GotoNextSibling() {
+		t.Fatalf("expect second term actual %v", c.Node())
+	}
+	if !c.GotoFirstChild() || c.Node().Rule != "call" || c.Depth() != 2 {
+		t.Fatalf("expect call actual %v", c.Node())
+	}
+	if !c.GotoParent() || !c.GotoParent() || c.Node() != n || c.Depth() != 0 {
+		t.Fatalf("expect back to root actual %v", c.Node())
+	}
+
+	var xs []string
+	for c := NewCursor(n); ; {
+		xs = append(xs, c.Node().Rule)
+		if !c.Next() {
+			if c.Node().Text() != "2" {
+				t.Errorf("expect stay at last node actual %v", c.Node())
+			}
+			break
+		}
+	}
+	if actual := strings.Join(xs, " "); actual != "expr term term call expr term" {
+		t.Errorf("expect preorder actual %s", actual)
+	}
+}
+
+// 失败分支中构造的节点随分支丢弃, 歧义的每个结果各自一棵树
+func TestBranches(t *testing.T) {
+	g := parsec.NewGrammar[kind]()
+	num := parsec.Define[kind, parsec.Token[kind]](g, "num")
+	ref := parsec.Define[kind, parsec.Token[kind]](g, "ref")
+	s := parsec.Define[kind, []parsec.Token[kind]](g, "s")
+	num.Pattern = parsec.Tok(kNum)
+	ref.Pattern = parsec.Tok(kNum)
+	s.Pattern = parsec.Alt(
+		parsec.Seq(num.Parser(), parsec.Tok(kAdd)),
+		parsec.Seq(ref.Parser(), parsec.Tok(kLParen)),
+		parsec.Seq(num.Parser(), parsec.Tok(kLParen)),
+	)
+	g.MustBuild()
+
+	for _, p := range []parsec.Parser[kind, []parsec.Token[kind]]{s, parsec.Greedy(s.Parser())} {
+		xs, err := ParseAll(p, lex("1 ("))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for _, x := range xs {
+			actual = append(actual, x.SExpr())
+		}
+		expect := `(s (ref "1") "(") (s (num "1") "(")`
+		if _, ok := p.(*parsec.SyntaxRule[kind, []parsec.Token[kind]]); !ok {
+			expect = `(s (ref "1") "(")`
+		}
+		if strings.Join(actual, " ") != expect {
+			t.Errorf("expect %s actual %s", expect, actual)
+		}
+	}
+
+	// 未命名的根, 按需枚举
+	p := parsec.Seq(s.Parser(), s.Parser())
+	toks := lex("1 + 2 (")
+	var actual []string
+	parsec.Enum(Build(p, len(toks)), toks, func(r parsec.Result[kind, [][]parsec.Token[kind]]) bool {
+		actual = append(actual, root(r, toks).SExpr())
+		return true
+	})
+	if expect := `((s (num "1") "+") (s (ref "2") "(")) ((s (num "1") "+") (s (num "2") "("))`; strings.Join(actual, " ") != expect {
+		t.Errorf("expect %s actual %s", expect, actual)
+	}
+}
+
+// 是否构造节点属于每次解析, CST 模式与普通模式可以并发使用同一套文法
+func TestConcurrentBuild(t *testing.T) {
+	expr := grammar()
+	toks := lex("1 + f(2)")
+	expect := `(expr (term "1") "+" (term (call "f" "(" (expr (term "2")) ")")))`
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if i%2 == 0 {
+					if n, err := Parse(expr.Parser(), toks); err != nil || n.SExpr() != expect {
+						t.Errorf("expect %s actual %v %v", expect, n, err)
+						return
+					}
+				} else {
+					out := expr.Parse(toks)
+					if !out.Success || len(parsec.Nodes(out.Candidates[0])) != 0 {
+						t.Errorf("expect no nodes actual %v", out)
+						return
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
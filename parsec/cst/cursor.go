@@ -0,0 +1,97 @@
+package cst
+
+import "github.com/goghcrow/go-parsec/parsec"
+
+// Cursor 在语法树上移动的游标, 同 tree-sitter 的 TreeCursor, 不能移动到起始节点之外
+type Cursor[K parsec.TK] struct {
+	path []frame[K] // 从起始节点到当前节点的路径
+}
+
+type frame[K parsec.TK] struct {
+	node *Node[K]
+	idx  int // 在父节点 Children 中的下标
+}
+
+func NewCursor[K parsec.TK](n *Node[K]) *Cursor[K] {
+	return &Cursor[K]{path: []frame[K]{{node: n}}}
+}
+
+// Node 当前节点
+func (c *Cursor[K]) Node() *Node[K] { return c.top().node }
+
+// Depth 当前节点相对于起始节点的深度, 起始节点为 0
+func (c *Cursor[K]) Depth() int { return len(c.path) - 1 }
+
+// Parent 当前节点的父节点, 当前为起始节点时返回 nil
+func (c *Cursor[K]) Parent() *Node[K] {
+	if len(c.path) < 2 {
+		return nil
+	}
+	return c.path[len(c.path)-2].node
+}
+
+func (c *Cursor[K]) GotoFirstChild() bool {
+	n := c.Node()
+	if len(n.Children) == 0 {
+		return false
+	}
+	c.path = append(c.path, frame[K]{n.Children[0], 0})
+	return true
+}
+
+func (c *Cursor[K]) GotoLastChild() bool {
+	n := c.Node()
+	if len(n.Children) == 0 {
+		return false
+	}
+	c.path = append(c.path, frame[K]{n.Children[len(n.Children)-1], len(n.Children) - 1})
+	return true
+}
+
+func (c *Cursor[K]) GotoNextSibling() bool { return c.gotoSibling(1) }
+
+func (c *Cursor[K]) GotoPrevSibling() bool { return c.gotoSibling(-1) }
+
+func (c *Cursor[K]) GotoParent() bool {
+	if len(c.path) < 2 {
+		return false
+	}
+	c.path = c.path[:len(c.path)-1]
+	return true
+}
+
+// Next 先序遍历的下一个节点, 没有时返回 false 并停留在当前节点
+// e.g. for c := NewCursor(root); ; { ...; if !c.Next() { break } }
+func (c *Cursor[K]) Next() bool {
+	if c.GotoFirstChild() {
+		return true
+	}
+	for depth := len(c.path); ; {
+		if c.GotoNextSibling() {
+			return true
+		}
+		if !c.GotoParent() {
+			// 回到起始节点, 恢复原来的位置
+			for len(c.path) < depth {
+				c.GotoLastChild()
+			}
+			return false
+		}
+	}
+}
+
+func (c *Cursor[K]) top() *frame[K] { return &c.path[len(c.path)-1] }
+
+func (c *Cursor[K]) gotoSibling(d int) bool {
+	parent := c.Parent()
+	if parent == nil {
+		return false
+	}
+	f := c.top()
+	i := f.idx + d
+	if i < 0 || i >= len(parent.Children) {
+		return false
+	}
+	*f = frame[K]{parent.Children[i], i}
+	return true
+}
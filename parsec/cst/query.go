@@ -0,0 +1,447 @@
+package cst
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+// Query 编译后的树查询, tree-sitter query 的子集, 用于 lint 规则与代码搜索
+//
+//	(rule child...)     规则名为 rule 的节点, 子模式按顺序匹配节点的 Items, 之间可以有未提及的兄弟
+//	(_ child...)        任意节点
+//	_                   任意节点或 token
+//	"lexeme"            lexeme 相同的 token
+//	field: pattern      字段名为 field 的子节点, 见 Field
+//	pattern* + ?        连续重复, 贪婪, 节点模式的重复之间可以有 token
+//	pattern @name       捕获, 同一个名字可以捕获多个节点
+//	(#eq? @a @b)        谓词, 作为节点模式的子项, 整个模式匹配之后检查捕获的 Text, 不满足时回溯尝试其他匹配,
+//	                    支持 #eq?, #not-eq?, #match?, #not-match?, 第二个参数为捕获或者字符串
+//	; comment
+//
+// e.g. (call name: (ident) @fn args: (_)* (#eq? @fn "print"))
+type Query struct {
+	patterns []*pattern
+}
+
+type Match[K parsec.TK] struct {
+	Pattern  int                  // 匹配的模式在查询中的下标
+	Item     Item[K]              // 顶层模式匹配的节点或 token
+	Captures map[string][]Item[K] // 按位置排列
+}
+
+type patternKind int
+
+const (
+	patNode  patternKind = iota // (rule child...)
+	patAny                      // _
+	patToken                    // "lexeme"
+)
+
+type pattern struct {
+	kind     patternKind
+	rule     string // patNode, _ 为任意节点
+	lexeme   string // patToken
+	field    string
+	min, max int // 重复次数, max < 0 不限
+	captures []string
+	children []*pattern
+	preds    []*predicate
+}
+
+type predicate struct {
+	name string
+	args []predArg
+	re   *regexp.Regexp // #match?
+}
+
+type predArg struct {
+	capture string // 非空时为捕获, 否则为 str
+	str     string
+}
+
+// Compile 编译查询, 语法错误返回 *parsec.Error
+func Compile(src string) (*Query, error) {
+	toks, err := lexQuery(src)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := parsec.ExpectSingleResult(queryParser.Parse(toks))
+	if err != nil {
+		return nil, err
+	}
+	return &Query{ps}, nil
+}
+
+func MustCompile(src string) *Query {
+	q, err := Compile(src)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// Matches 先序遍历 n 的子树(包括 token), 返回所有匹配; 同一位置按模式的顺序, 每个模式至多匹配一次
+func Matches[K parsec.TK](q *Query, n *Node[K]) []Match[K] {
+	var ms []Match[K]
+	var visit func(it Item[K])
+	visit = func(it Item[K]) {
+		for i, p := range q.patterns {
+			m := &matcher[K]{}
+			// 谓词在结构匹配成功时检查, 不满足时回溯
+			if m.item(p, it, func() bool { return m.check(p) }) {
+				ms = append(ms, Match[K]{Pattern: i, Item: it, Captures: m.captures()})
+			}
+		}
+		if it.Node != nil {
+			for _, c := range it.Node.Items() {
+				visit(c)
+			}
+		}
+	}
+	visit(Item[K]{Node: n})
+	return ms
+}
+
+// Text 节点的 Text 或者 token 的 lexeme
+func (it Item[K]) Text() string {
+	if it.Node != nil {
+		return it.Node.Text()
+	}
+	return it.Token.Lexeme()
+}
+
+// ----------------------------------------------------------------
+// Matcher
+// ----------------------------------------------------------------
+
+type capture[K parsec.TK] struct {
+	name string
+	item Item[K]
+}
+
+// matcher 回溯匹配, 以 continuation 串联后续的子模式, 失败时截断 caps 撤销捕获
+type matcher[K parsec.TK] struct {
+	caps []capture[K]
+}
+
+// item 匹配 p 与 it, 成功后继续匹配 k, k 失败时回溯尝试其他匹配方式
+func (m *matcher[K]) item(p *pattern, it Item[K], k func() bool) bool {
+	if p.field != "" && (it.Node == nil || it.Node.Field != p.field) {
+		return false
+	}
+	switch p.kind {
+	case patToken:
+		if it.Token == nil || it.Token.Lexeme() != p.lexeme {
+			return false
+		}
+	case patNode:
+		if it.Node == nil || p.rule != "_" && it.Node.Rule != p.rule {
+			return false
+		}
+	}
+	mark := len(m.caps)
+	for _, name := range p.captures {
+		m.caps = append(m.caps, capture[K]{name, it})
+	}
+	if p.kind == patNode && m.seq(p.children, it.Node.Items(), 0, k) || p.kind != patNode && k() {
+		return true
+	}
+	m.caps = m.caps[:mark]
+	return false
+}
+
+// seq 从 items[i] 开始按顺序匹配 ps, 然后匹配 k; 每个子模式之前可以跳过未提及的兄弟
+func (m *matcher[K]) seq(ps []*pattern, items []Item[K], i int, k func() bool) bool {
+	if len(ps) == 0 {
+		return k()
+	}
+	p, rest := ps[0], ps[1:]
+	for j := i; j < len(items); j++ {
+		if m.rep(p, rest, items, j, 0, k) {
+			return true
+		}
+	}
+	return p.min == 0 && m.seq(rest, items, i, k)
+}
+
+// rep 从 items[j] 开始连续匹配 p, 已经匹配了 n 次; 节点模式的重复之间可以有 token, e.g. 分隔符
+func (m *matcher[K]) rep(p *pattern, rest []*pattern, items []Item[K], j, n int, k func() bool) bool {
+	i := j
+	for n > 0 && p.kind == patNode && i < len(items) && items[i].Token != nil {
+		i++
+	}
+	if (p.max < 0 || n < p.max) && i < len(items) {
+		if m.item(p, items[i], func() bool { return m.rep(p, rest, items, i+1, n+1, k) }) {
+			return true
+		}
+	}
+	return n > 0 && n >= p.min && m.seq(rest, items, j, k)
+}
+
+// check 检查模式中所有的谓词
+func (m *matcher[K]) check(p *pattern) bool {
+	for _, pred := range p.preds {
+		if !m.eval(pred) {
+			return false
+		}
+	}
+	for _, c := range p.children {
+		if !m.check(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *matcher[K]) eval(pred *predicate) bool {
+	lhs, rhs := m.text(pred.args[0]), m.text(pred.args[1])
+	switch pred.name {
+	case "#eq?":
+		return lhs == rhs
+	case "#not-eq?":
+		return lhs != rhs
+	case "#match?":
+		return pred.re.MatchString(lhs)
+	case "#not-match?":
+		return !pred.re.MatchString(lhs)
+	default:
+		panic("unreached")
+	}
+}
+
+// text 捕获的 Text, 多个时以空格分隔
+func (m *matcher[K]) text(a predArg) string {
+	if a.capture == "" {
+		return a.str
+	}
+	var xs []string
+	for _, c := range m.caps {
+		if c.name == a.capture {
+			xs = append(xs, c.item.Text())
+		}
+	}
+	return strings.Join(xs, " ")
+}
+
+func (m *matcher[K]) captures() map[string][]Item[K] {
+	caps := make(map[string][]Item[K], len(m.caps))
+	for _, c := range m.caps {
+		caps[c.name] = append(caps[c.name], c.item)
+	}
+	return caps
+}
+
+// ----------------------------------------------------------------
+// Parser
+// ----------------------------------------------------------------
+
+type qkind int
+
+const (
+	qLParen qkind = iota + 1
+	qRParen
+	qColon
+	qName
+	qStr
+	qCapture
+	qQuant
+	qPred
+	qSpace
+	qComment
+)
+
+func (k qkind) String() string {
+	return map[qkind]string{
+		qLParen:  "(",
+		qRParen:  ")",
+		qColon:   ":",
+		qName:    "name",
+		qStr:     "string",
+		qCapture: "capture",
+		qQuant:   "quantifier",
+		qPred:    "predicate",
+		qSpace:   "<space>",
+		qComment: "<comment>",
+	}[k]
+}
+
+var qlexicon = []struct {
+	kind qkind
+	re   *regexp.Regexp
+}{
+	{qSpace, regexp.MustCompile(`^\s+`)},
+	{qComment, regexp.MustCompile(`^;[^\n]*`)},
+	{qLParen, regexp.MustCompile(`^\(`)},
+	{qRParen, regexp.MustCompile(`^\)`)},
+	{qColon, regexp.MustCompile(`^:`)},
+	{qStr, regexp.MustCompile(`^"(?:[^"\\\n]|\\.)*"`)},
+	{qCapture, regexp.MustCompile(`^@[\p{L}\w.-]+`)},
+	{qPred, regexp.MustCompile(`^#[\w-]+[?!]?`)},
+	{qQuant, regexp.MustCompile(`^[*+?]`)},
+	{qName, regexp.MustCompile(`^[\p{L}_][\p{L}\w.-]*`)},
+}
+
+type qtoken struct {
+	kind              qkind
+	lexeme            string
+	idx, end, col, ln int
+}
+
+func (t *qtoken) Kind() qkind               { return t.kind }
+func (t *qtoken) Lexeme() string            { return t.lexeme }
+func (t *qtoken) String() string            { return t.lexeme }
+func (t *qtoken) Loc() (int, int, int, int) { return t.idx, t.end, t.col, t.ln }
+
+func lexQuery(s string) ([]parsec.Token[qkind], error) {
+	var toks []parsec.Token[qkind]
+	idx, col, ln := 0, 0, 0
+next:
+	for idx < len(s) {
+		for _, r := range qlexicon {
+			m := r.re.FindString(s[idx:])
+			if m == "" {
+				continue
+			}
+			if r.kind != qSpace && r.kind != qComment {
+				toks = append(toks, &qtoken{r.kind, m, idx, idx + len(m), col, ln})
+			}
+			for _, c := range m {
+				if c == '\n' {
+					ln, col = ln+1, 0
+				} else {
+					col++
+				}
+			}
+			idx += len(m)
+			continue next
+		}
+		c, size := utf8.DecodeRuneInString(s[idx:])
+		return nil, &parsec.Error{Pos: &qtoken{idx: idx, end: idx + size, col: col, ln: ln}, Msg: fmt.Sprintf("unexpected character %q", c)}
+	}
+	return toks, nil
+}
+
+var queryParser = buildQueryParser()
+
+// query   = {top} EOF
+// top     = atom {CAPTURE}
+// atom    = '(' NAME {child | pred} ')' | '_' | STRING
+// child   = [NAME ':'] atom [QUANT] {CAPTURE}
+// pred    = '(' PRED {CAPTURE | STRING} ')'
+func buildQueryParser() parsec.Parser[qkind, []*pattern] {
+	type tok = parsec.Token[qkind]
+	str := parsec.ApplyE(parsec.Tok(qStr), func(t tok) (string, error) { return strconv.Unquote(t.Lexeme()) })
+	captures := parsec.RepSc(parsec.Apply(parsec.Tok(qCapture), func(t tok) string { return t.Lexeme()[1:] }))
+
+	arg := parsec.AltSc(
+		parsec.Apply(parsec.Tok(qCapture), func(t tok) predArg { return predArg{capture: t.Lexeme()[1:]} }),
+		parsec.Apply(str, func(s string) predArg { return predArg{str: s} }),
+	)
+	pred := parsec.ApplyE(parsec.KMid(parsec.Tok(qLParen), parsec.Seq2(parsec.Tok(qPred), parsec.RepSc(arg)), parsec.Tok(qRParen)), newPredicate)
+
+	atom := parsec.NewRule[qkind, *pattern]()
+	child := parsec.Apply(
+		parsec.Seq4(parsec.OptionMaybeSc(parsec.KLeft(parsec.Tok(qName), parsec.Tok(qColon))), atom.Parser(), parsec.OptionMaybeSc(parsec.Tok(qQuant)), captures),
+		func(v parsec.Cons[parsec.Option[tok], parsec.Cons[*pattern, parsec.Cons[parsec.Option[tok], []string]]]) *pattern {
+			p := *v.Cdr.Car
+			if v.Car.IsSome() {
+				p.field = v.Car.V.Lexeme()
+			}
+			if v.Cdr.Cdr.Car.IsSome() {
+				p.min, p.max = quantifier(v.Cdr.Cdr.Car.V.Lexeme())
+			}
+			p.captures = v.Cdr.Cdr.Cdr
+			return &p
+		},
+	)
+	node := parsec.Apply(
+		parsec.KMid(parsec.Tok(qLParen), parsec.Seq2(parsec.Tok(qName), parsec.RepSc(parsec.AltScOf2(child, pred))), parsec.Tok(qRParen)),
+		func(v parsec.Cons[tok, []parsec.OneOf2[*pattern, *predicate]]) *pattern {
+			p := &pattern{kind: patNode, rule: v.Car.Lexeme(), min: 1, max: 1}
+			for _, x := range v.Cdr {
+				x.Match(
+					func(c *pattern) { p.children = append(p.children, c) },
+					func(pred *predicate) { p.preds = append(p.preds, pred) },
+				)
+			}
+			return p
+		},
+	)
+	wildcard := parsec.Apply(
+		parsec.Satisfy(func(t tok) bool { return t.Kind() == qName && t.Lexeme() == "_" }, "_"),
+		func(tok) *pattern { return &pattern{kind: patAny, min: 1, max: 1} },
+	)
+	lexeme := parsec.Apply(str, func(s string) *pattern { return &pattern{kind: patToken, lexeme: s, min: 1, max: 1} })
+	atom.SetPattern("pattern", parsec.AltSc(node, wildcard, lexeme))
+
+	top := parsec.ApplyE(parsec.Seq2(atom.Parser(), captures), func(v parsec.Cons[*pattern, []string]) (*pattern, error) {
+		p := *v.Car
+		p.captures = v.Cdr
+		return &p, checkCaptures(&p)
+	})
+	return parsec.KLeft(parsec.RepSc(top), parsec.EOF[qkind]())
+}
+
+func quantifier(q string) (min, max int) {
+	switch q {
+	case "*":
+		return 0, -1
+	case "+":
+		return 1, -1
+	default:
+		return 0, 1
+	}
+}
+
+func newPredicate(v parsec.Cons[parsec.Token[qkind], []predArg]) (*predicate, error) {
+	pred := &predicate{name: v.Car.Lexeme(), args: v.Cdr}
+	switch pred.name {
+	case "#eq?", "#not-eq?", "#match?", "#not-match?":
+	default:
+		return nil, fmt.Errorf("unknown predicate %s", pred.name)
+	}
+	if len(pred.args) != 2 || pred.args[0].capture == "" {
+		return nil, fmt.Errorf("predicate %s expects a capture and a capture or string", pred.name)
+	}
+	if pred.name == "#match?" || pred.name == "#not-match?" {
+		if pred.args[1].capture != "" {
+			return nil, fmt.Errorf("predicate %s expects a regexp string", pred.name)
+		}
+		re, err := regexp.Compile(pred.args[1].str)
+		if err != nil {
+			return nil, err
+		}
+		pred.re = re
+	}
+	return pred, nil
+}
+
+// checkCaptures 谓词引用的捕获必须定义在同一个顶层模式中
+func checkCaptures(top *pattern) error {
+	defined := map[string]bool{}
+	var preds []*predicate
+	var collect func(p *pattern)
+	collect = func(p *pattern) {
+		for _, name := range p.captures {
+			defined[name] = true
+		}
+		preds = append(preds, p.preds...)
+		for _, c := range p.children {
+			collect(c)
+		}
+	}
+	collect(top)
+	for _, pred := range preds {
+		for _, a := range pred.args {
+			if a.capture != "" && !defined[a.capture] {
+				return fmt.Errorf("undefined capture @%s in %s", a.capture, pred.name)
+			}
+		}
+	}
+	return nil
+}
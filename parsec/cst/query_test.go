@@ -0,0 +1,139 @@
+package cst
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+// expr  = term {'+' term}
+// term  = num | call | ident | '(' expr ')'
+// call  = name:ident '(' [args:expr {',' args:expr}] ')'
+func fieldGrammar() *parsec.SyntaxRule[kind, struct{}] {
+	g := parsec.NewGrammar[kind]()
+	expr := parsec.Define[kind, struct{}](g, "expr")
+	term := parsec.Define[kind, struct{}](g, "term")
+	call := parsec.Define[kind, struct{}](g, "call")
+	ident := parsec.Define[kind, struct{}](g, "ident")
+	num := parsec.Define[kind, struct{}](g, "num")
+	expr.Pattern = discard(parsec.Seq2(term.Parser(), parsec.RepSc(parsec.Seq2(parsec.Tok(kAdd), term.Parser()))))
+	term.Pattern = parsec.AltSc(
+		num.Parser(),
+		call.Parser(),
+		ident.Parser(),
+		parsec.KMid(parsec.Tok(kLParen), expr.Parser(), parsec.Tok(kRParen)),
+	)
+	args := parsec.ListSc(Field("args", expr.Parser()), parsec.Tok(kComma))
+	call.Pattern = discard(parsec.Seq4(Field("name", ident.Parser()), parsec.Tok(kLParen), parsec.OptSc(args), parsec.Tok(kRParen)))
+	ident.Pattern = discard(parsec.Tok(kIdent))
+	num.Pattern = discard(parsec.Tok(kNum))
+	g.MustBuild()
+	return expr
+}
+
+func TestField(t *testing.T) {
+	n, err := Parse(fieldGrammar().Parser(), lex("f(1, x)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `(expr (term (call name: (ident "f") "(" args: (expr (term (num "1"))) "," args: (expr (term (ident "x"))) ")")))`
+	if n.SExpr() != expect {
+		t.Errorf("expect %s actual %s", expect, n)
+	}
+	// 未标注字段时不影响构造的节点
+	if n, _ := Parse(grammar().Parser(), lex("f(1)")); n.FindAll("call")[0].Children[0].Field != "" {
+		t.Errorf("expect no field actual %v", n)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	const src = "f(1, g(x + 2), 3) + h()"
+	for _, tt := range []struct {
+		input  string
+		query  string
+		expect string
+	}{
+		{src, `(call name: (ident) @fn)`, "fn=f; fn=g; fn=h"},
+		{src, `(call name: (ident) @fn args: (_)* @arg)`, "arg=1|g ( x + 2 )|3 fn=f; arg=x + 2 fn=g; fn=h"},
+		{src, `(call name: (ident) @fn args: (_)+ @arg)`, "arg=1|g ( x + 2 )|3 fn=f; arg=x + 2 fn=g"},
+		{src, `(call name: (ident) @fn args: (_)? @arg)`, "arg=1 fn=f; arg=x + 2 fn=g; fn=h"},
+		{src, `(call (ident) @fn args: (_) @a args: (_) @b)`, "a=1 b=g ( x + 2 ) fn=f"},
+		{src, `(call (ident) @fn (#eq? @fn "g"))`, "fn=g"},
+		{src, `(call (ident) @fn (#not-eq? @fn "g"))`, "fn=f; fn=h"},
+		{src, `(call (ident) @fn (#match? @fn "^[fh]$"))`, "fn=f; fn=h"},
+		{src, `(call (ident) @fn (#not-match? @fn "f"))`, "fn=g; fn=h"},
+		{src, `(expr (term) @lhs "+" (term) @rhs (#eq? @lhs @rhs))`, ""},
+		{src, `(expr (term) "+" (term) @rhs)`, "rhs=h ( ); rhs=2"},
+		{src, `(ident) @id`, "id=f; id=g; id=x; id=h"},
+		{src, `"+" @op`, "op=+; op=+"},
+		{src, `(call "(" ")" @close "(")`, ""},
+		{src, `(term (_ "(" ")") @c)`, "c=f ( 1 , g ( x + 2 ) , 3 ); c=g ( x + 2 ); c=h ( )"},
+		{src, `(call name: (num))`, ""},
+		{src, `(num) @n ; comment
+		  (call name: (_ _ @tok)) @c`, "c=f ( 1 , g ( x + 2 ) , 3 ) tok=f; n=1; c=g ( x + 2 ) tok=g; n=2; n=3; c=h ( ) tok=h"},
+		// 谓词不满足时回溯, 尝试其他子节点
+		{"f(1, x)", `(call args: (expr) @a (#eq? @a "x"))`, "a=x"},
+		{src, `(call args: (_)? @a args: (_) @b (#eq? @b "3"))`, "a=1 b=3"},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			n, err := Parse(fieldGrammar().Parser(), lex(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var xs []string
+			for _, m := range Matches(MustCompile(tt.query), n) {
+				xs = append(xs, showMatch(m))
+			}
+			if actual := strings.Join(xs, "; "); actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func showMatch(m Match[kind]) string {
+	var names []string
+	for name := range m.Captures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var xs []string
+	for _, name := range names {
+		var texts []string
+		for _, it := range m.Captures[name] {
+			texts = append(texts, it.Text())
+		}
+		xs = append(xs, name+"="+strings.Join(texts, "|"))
+	}
+	return strings.Join(xs, " ")
+}
+
+func TestQueryError(t *testing.T) {
+	for _, tt := range []struct {
+		query  string
+		expect string
+	}{
+		{`(call`, "Nothing to consume expect `name` in end of input"},
+		{`(call name:)`, "Unable to consume token `)` expect `(` in pos 12-13 line 1 col 12"},
+		{`(call $)`, `unexpected character '$' in pos 7-8 line 1 col 7`},
+		{`(call €)`, `unexpected character '€' in pos 7-10 line 1 col 7`},
+		{`(call (#foo? @a "x"))`, "unknown predicate #foo? in pos 7-21 line 1 col 7"},
+		{`(call (#eq? @a))`, "predicate #eq? expects a capture and a capture or string in pos 7-16 line 1 col 7"},
+		{`(call (#match? @a "["))`, "error parsing regexp: missing closing ]: `[` in pos 7-23 line 1 col 7"},
+		{`(call (ident) @a (#eq? @b "x"))`, "undefined capture @b in #eq? in pos 1-32 line 1 col 1"},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Compile(tt.query)
+			if err == nil || err.Error() != tt.expect {
+				t.Errorf("expect %s actual %v", tt.expect, err)
+			}
+			var e *parsec.Error
+			if !errors.As(err, &e) {
+				t.Errorf("expect *parsec.Error actual %T", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package parsec
+
+import "fmt"
+
+// ----------------------------------------------------------------
+// Nesting Depth, 嵌套深度限制
+// ----------------------------------------------------------------
+
+// MaxDepth :: p[a] -> int -> p[a]
+// p 的子树中 Parser 的嵌套深度不超过 n, 超过时在该位置失败;
+// 每个组合子(包括规则, Lazy)计一层, 递归下降的嵌套(e.g. 深层括号, 不论是否经过具名规则)占用 goroutine 栈,
+// 栈溢出无法 recover, 解析不可信的输入时需要设置, n 可以按 "每层括号经过的组合子数 × 允许的括号层数" 估算;
+// 按需枚举(Enum)时 yield 之后的解析同样占用栈, 也计入深度; 没有 MaxDepth 时不计数
+func MaxDepth[K TK, R any](p Parser[K, R], n int) Parser[K, R] {
+	set := func(st *State[K]) func() {
+		oldMax, oldLimit := st.maxDepth, st.limit
+		st.maxDepth, st.limit = st.depth+n, n
+		return func() { st.maxDepth, st.limit = oldMax, oldLimit }
+	}
+	return withEnum(parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		defer set(st)()
+		return ParseIn(st, p, toks)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+		oldMax, oldLimit := st.maxDepth, st.limit
+		defer set(st)()
+		newMax, newLimit := st.maxDepth, st.limit
+		return EnumIn(st, p, toks, func(r Result[K, R]) bool {
+			st.maxDepth, st.limit = oldMax, oldLimit
+			defer func() { st.maxDepth, st.limit = newMax, newLimit }()
+			return yield(r)
+		})
+	})
+}
+
+// enter 进入一层 Parser, 超过 MaxDepth 时返回错误, 成功时需要调用 leave
+func (st *State[K]) enter(toks []Token[K]) *Error {
+	if st.depth >= st.maxDepth {
+		return newError(startPos(toks), fmt.Sprintf("Nesting too deep, exceeds max depth %d.", st.limit))
+	}
+	st.depth++
+	return nil
+}
+
+func (st *State[K]) leave() { st.depth-- }
@@ -0,0 +1,99 @@
+package parsec
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMaxDepth(t *testing.T) {
+	nested := func(n int) []Token[benchKind] {
+		return benchLex(strings.Repeat("(", n) + "1" + strings.Repeat(")", n))
+	}
+	deep := benchDeep()
+
+	for _, tt := range []struct {
+		name   string
+		p      Parser[benchKind, int]
+		depth  int
+		expect string
+	}{
+		{"unlimited", deep, 2000, "2000"},
+		// 每层括号经过 x, AltSc, Apply, KMid 的 Apply 与 Seq3, 共 6 层, 最内层的数字 4 层
+		{"within", MaxDepth(deep, 604), 100, "100"},
+		{"exceed", MaxDepth(deep, 600), 100, "Nesting too deep, exceeds max depth 600. in pos 101-102 line 1 col 101"},
+		{"exceed", MaxDepth(deep, 600), 2000, "Nesting too deep, exceeds max depth 600. in pos 101-102 line 1 col 101"},
+		// 相对于 MaxDepth 所在的深度
+		{"nested", MaxDepth(KMid(op("("), MaxDepth(deep, 60), op(")")), 10), 11,
+			"Nesting too deep, exceeds max depth 60. in pos 12-13 line 1 col 12"},
+		// 不经过具名规则的嵌套同样计数
+		{"anonymous", MaxDepth(func() Parser[benchKind, int] {
+			var x Parser[benchKind, int]
+			x = AltSc(
+				Apply(Tok(bNum), func(Token[benchKind]) int { return 0 }),
+				Apply(KMid(op("("), Lazy(func() Parser[benchKind, int] { return x }), op(")")), func(n int) int { return n + 1 }),
+			)
+			return x
+		}(), 18), 4, "Nesting too deep, exceeds max depth 18. in pos 4-5 line 1 col 4"},
+	} {
+		t.Run(fmt.Sprintf("%s/%d", tt.name, tt.depth), func(t *testing.T) {
+			v, err := ExpectSingleResult(ExpectEOF(tt.p.Parse(nested(tt.depth))))
+			actual := fmt.Sprint(v)
+			if err != nil {
+				actual = err.Error()
+			}
+			if actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+		})
+	}
+
+	v, err := ParseSingle(MaxDepth(deep, 600), nested(99))
+	if err != nil || v != 99 {
+		t.Errorf("unexpected %v %v", v, err)
+	}
+}
+
+func TestChainIterative(t *testing.T) {
+	num := Apply(Tok(bNum), func(t Token[benchKind]) string { return t.Lexeme() })
+	pow := Apply(op("^"), func(Token[benchKind]) func(string, string) string {
+		return func(l, r string) string { return "(" + l + "^" + r + ")" }
+	})
+	sub := Apply(op("-"), func(Token[benchKind]) func(string, string) string {
+		return func(l, r string) string { return "(" + l + "-" + r + ")" }
+	})
+
+	for _, tt := range []struct {
+		name   string
+		p      Parser[benchKind, string]
+		input  string
+		expect string
+	}{
+		{"chainr1", Chainr1(num, pow), "1 ^ 2 ^ 3", "(1^(2^3))"},
+		{"chainr1Sc", Chainr1Sc(num, pow), "1 ^ 2 ^ 3 ^ 4", "(1^(2^(3^4)))"},
+		{"chainl1", Chainl1(num, sub), "1 - 2 - 3", "((1-2)-3)"},
+		{"chainl1Sc", Chainl1Sc(num, sub), "1 - 2 - 3 - 4", "(((1-2)-3)-4)"},
+		{"chainr", Chainr(num, pow, "x"), "", "x"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := ExpectSingleResult(ExpectEOF(tt.p.Parse(benchLex(tt.input))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, v)
+			}
+		})
+	}
+
+	// 长链
+	n := 1000
+	count := Apply(op("^"), func(Token[benchKind]) func(int, int) int {
+		return func(l, r int) int { return l + r }
+	})
+	one := Apply(Tok(bNum), func(Token[benchKind]) int { return 1 })
+	v, err := ExpectSingleResult(ExpectEOF(Chainr1Sc(one, count).Parse(benchLex(benchInput("1", " ^ ", n)))))
+	if err != nil || v != n {
+		t.Errorf("unexpected %v %v", v, err)
+	}
+}
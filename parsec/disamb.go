@@ -0,0 +1,213 @@
+package parsec
+
+// ----------------------------------------------------------------
+// Disambiguation Filters, 声明式消歧
+// ----------------------------------------------------------------
+
+// 参考 SDF 的消歧过滤器, 用自然的歧义文法(e.g. E = E op E)描述语法, 再用过滤器得到唯一的结果
+// e.g.
+//	fs := []Filter[*Expr]{
+//		Priority(tree, []string{"*", "/"}, []string{"+", "-"}),
+//		LeftAssoc(tree, "*", "/"),
+//		LeftAssoc(tree, "+", "-"),
+//	}
+//	var grow func(l *Expr) Parser[K, *Expr] // E = E op E, 每步折叠一个新节点并过滤
+//	grow = func(l *Expr) Parser[K, *Expr] {
+//		return Alt(Succ[K](l), Bind(Disambiguate(Apply(Seq2(op, EXPR), bin(l)), fs...), grow))
+//	}
+//	EXPR.SetPattern("expr", Disambiguate(Bind(atom, grow), fs...))
+
+// Filter 过滤一组歧义的结果, 组内的结果消费相同的 token;
+// 返回保留的结果在 xs 中的下标, Disambiguate 据此保留每个结果自己的节点(见 BuildNodes)
+type Filter[R any] func(xs []R) []int
+
+// Tree 描述值的树结构, 返回产生式名(e.g. 运算符)与子节点, 叶子节点返回空的 children
+// 括号需要是独立的节点(产生式名不在过滤器中), 否则 (1+2)*3 会被当作违反优先级
+type Tree[R any] func(v R) (prod string, children []R)
+
+// Disambiguate :: p[a] -> filters -> p[a]
+// 按消费的 token 数对 p 的候选结果分组, 每组依次应用 fs, 被过滤为空的组被丢弃;
+// 结果按消费的 token 数从多到少排列(同 Amb); 过滤器只检查结果的根节点与直接子节点,
+// 所以每个节点都需要经过 Disambiguate: 放在递归的规则上, 每层都会过滤, 可以尽早剪枝;
+// LRec 在一次调用中折叠出的左侧节点不经过过滤器, 需要用 Bind 逐步折叠, 每步过滤新节点
+func Disambiguate[K TK, R any](p Parser[K, R], fs ...Filter[R]) Parser[K, R] {
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		out := ParseIn(st, p, toks)
+		if !out.Success {
+			return out
+		}
+		var xs []Result[K, R]
+		var err *Error
+		for _, group := range groupByRest(out.Candidates) {
+			next := group[0].next
+			vals := sliceMap(group, func(r Result[K, R]) R { return r.Val })
+			keep := resolve(vals, fs)
+			if len(keep) == 0 {
+				// 错误位置为被拒绝的结果的结尾, 以便 ExpectEOF 报告
+				var pos Pos = EOFPos
+				if len(next) != 0 {
+					pos = next[0]
+				}
+				err = betterError(err, newError(pos, "All results are rejected by disambiguation filters."))
+			}
+			for _, i := range keep {
+				xs = append(xs, group[i])
+			}
+		}
+		err = betterError(err, out.Error)
+		return newOutput(xs, err, len(xs) != 0)
+	})
+}
+
+// Resolve 对一组歧义的结果(e.g. Amb 的分组, AmbWith 的 merge 参数)依次应用过滤器
+func Resolve[R any](xs []R, fs ...Filter[R]) []R {
+	return sliceMap(resolve(xs, fs), func(i int) R { return xs[i] })
+}
+
+// resolve 返回经过 fs 之后保留的结果在 xs 中的下标
+func resolve[R any](xs []R, fs []Filter[R]) []int {
+	idx := all(xs)
+	for _, f := range fs {
+		if len(idx) == 0 {
+			break
+		}
+		idx = sliceMap(f(sliceMap(idx, func(i int) R { return xs[i] })), func(j int) int { return idx[j] })
+	}
+	return idx
+}
+
+// LongestMatch :: p[a] -> p[a]
+// 只保留消费 token 最多的候选结果
+func LongestMatch[K TK, R any](p Parser[K, R]) Parser[K, R] {
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		return longest(ParseIn(st, p, toks))
+	})
+}
+
+func longest[K TK, R any](out Output[K, R]) Output[K, R] {
+	if !out.Success || len(out.Candidates) < 2 {
+		return out
+	}
+	min := len(out.Candidates[0].next)
+	for _, r := range out.Candidates {
+		if len(r.next) < min {
+			min = len(r.next)
+		}
+	}
+	var xs []Result[K, R]
+	for _, r := range out.Candidates {
+		if len(r.next) == min {
+			xs = append(xs, r)
+		}
+	}
+	return successWithErr(xs, out.Error)
+}
+
+// Reject 删除满足 reject 的结果, 即使组内没有剩余的结果
+func Reject[R any](reject func(R) bool) Filter[R] {
+	return func(xs []R) []int {
+		var keep []int
+		for i, x := range xs {
+			if !reject(x) {
+				keep = append(keep, i)
+			}
+		}
+		return keep
+	}
+}
+
+// Prefer 存在歧义时, 如果有满足 prefer 的结果, 只保留这些结果
+func Prefer[R any](prefer func(R) bool) Filter[R] {
+	return func(xs []R) []int {
+		if keep := Reject(not(prefer))(xs); len(keep) != 0 {
+			return keep
+		}
+		return all(xs)
+	}
+}
+
+// Avoid 存在歧义时, 如果有不满足 avoid 的结果, 只保留这些结果
+func Avoid[R any](avoid func(R) bool) Filter[R] {
+	return func(xs []R) []int {
+		if keep := Reject(avoid)(xs); len(keep) != 0 {
+			return keep
+		}
+		return all(xs)
+	}
+}
+
+// Priority 产生式优先级, levels 从高到低, 每层可以包含多个产生式
+// 低优先级的节点不能作为高优先级节点的直接子节点, e.g. * > +, 拒绝 (1+2)*3 的无括号形式
+func Priority[R any](tree Tree[R], levels ...[]string) Filter[R] {
+	level := make(map[string]int)
+	for i, prods := range levels {
+		for _, prod := range prods {
+			level[prod] = i
+		}
+	}
+	return Reject(violate(tree, func(parent string, children []R, i int, child string) bool {
+		p, ok1 := level[parent]
+		c, ok2 := level[child]
+		return ok1 && ok2 && c > p
+	}))
+}
+
+// LeftAssoc 左结合, 同组的节点不能作为最右子节点, e.g. 拒绝 1-(2-3) 的无括号形式
+func LeftAssoc[R any](tree Tree[R], prods ...string) Filter[R] {
+	group := setOf(prods)
+	return Reject(violate(tree, func(parent string, children []R, i int, child string) bool {
+		return group[parent] && group[child] && i == len(children)-1 && len(children) > 1
+	}))
+}
+
+// RightAssoc 右结合, 同组的节点不能作为最左子节点, e.g. 拒绝 (2^3)^4 的无括号形式
+func RightAssoc[R any](tree Tree[R], prods ...string) Filter[R] {
+	group := setOf(prods)
+	return Reject(violate(tree, func(parent string, children []R, i int, child string) bool {
+		return group[parent] && group[child] && i == 0 && len(children) > 1
+	}))
+}
+
+// NonAssoc 不可结合, 同组的节点不能互为子节点, e.g. 拒绝 a < b < c
+func NonAssoc[R any](tree Tree[R], prods ...string) Filter[R] {
+	group := setOf(prods)
+	return Reject(violate(tree, func(parent string, children []R, i int, child string) bool {
+		return group[parent] && group[child] && len(children) > 1
+	}))
+}
+
+// violate 检查根节点与直接子节点是否违反 conflict;
+// 子树在其所在的层已经过滤(Disambiguate 放在递归的规则上), 不需要重复遍历
+func violate[R any](tree Tree[R], conflict func(parent string, children []R, i int, child string) bool) func(R) bool {
+	return func(v R) bool {
+		parent, children := tree(v)
+		for i, c := range children {
+			child, _ := tree(c)
+			if conflict(parent, children, i, child) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// all 保留 xs 的全部结果
+func all[R any](xs []R) []int {
+	idx := make([]int, len(xs))
+	for i := range idx {
+		idx[i] = i
+	}
+	return idx
+}
+
+func not[R any](f func(R) bool) func(R) bool {
+	return func(v R) bool { return !f(v) }
+}
+
+func setOf(xs []string) map[string]bool {
+	m := make(map[string]bool, len(xs))
+	for _, x := range xs {
+		m[x] = true
+	}
+	return m
+}
@@ -0,0 +1,194 @@
+package parsec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goghcrow/go-parsec/lexer"
+)
+
+type expr struct {
+	op   string
+	args []*expr
+	val  string
+}
+
+func (e *expr) String() string {
+	switch {
+	case e.op == "":
+		return e.val
+	case e.op == "()":
+		return e.args[0].String()
+	case len(e.args) == 1:
+		return fmt.Sprintf("(%s%s)", e.op, e.args[0])
+	default:
+		return fmt.Sprintf("(%s %s %s)", e.args[0], e.op, e.args[1])
+	}
+}
+
+func exprTree(e *expr) (string, []*expr) { return e.op, e.args }
+
+func TestDisambiguate(t *testing.T) {
+	// E = E op E | - E | ( E ) | num, 自然的歧义文法
+	E := NewRule[benchKind, *expr]()
+	num := Apply(Tok(bNum), func(t Token[benchKind]) *expr { return &expr{val: t.Lexeme()} })
+	atom := AltSc(
+		num,
+		Apply(KMid(op("("), E.Parser(), op(")")), func(e *expr) *expr { return &expr{op: "()", args: []*expr{e}} }),
+		Apply(KRight(op("-"), E.Parser()), func(e *expr) *expr { return &expr{op: "neg", args: []*expr{e}} }),
+	)
+	binop := Alt(op("+"), op("-"), op("*"), op("/"), op("^"), op(":"))
+	bin := func(l *expr, r Cons[Token[benchKind], *expr]) *expr {
+		return &expr{op: r.Car.Lexeme(), args: []*expr{l, r.Cdr}}
+	}
+	filters := []Filter[*expr]{
+		Priority(exprTree, []string{"neg"}, []string{"^"}, []string{"*", "/"}, []string{"+", "-"}, []string{":"}),
+		LeftAssoc(exprTree, "+", "-"),
+		LeftAssoc(exprTree, "*", "/"),
+		RightAssoc(exprTree, "^"),
+		NonAssoc(exprTree, ":"),
+	}
+	// 过滤器只检查直接子节点, LRec 折叠出的左侧节点不经过过滤器, 所以逐步折叠, 每个新节点都过滤
+	var grow func(l *expr) Parser[benchKind, *expr]
+	grow = func(l *expr) Parser[benchKind, *expr] {
+		step := Disambiguate(Apply(Seq2(binop, E.Parser()), func(r Cons[Token[benchKind], *expr]) *expr {
+			return bin(l, r)
+		}), filters...)
+		return Alt(Succ[benchKind](l), Bind(step, grow))
+	}
+	E.Pattern = Disambiguate(Bind(atom, grow), filters...)
+
+	if !Ambiguous(ExpectEOF(LRec(atom, Seq2(binop, E.Parser()), bin).Parse(benchLex("1 + 2 * 3")))) {
+		t.Errorf("expect ambiguous grammar")
+	}
+
+	for _, tt := range []struct {
+		input  string
+		expect string
+	}{
+		{"1", "1"},
+		{"1 + 2 * 3", "(1 + (2 * 3))"},
+		{"1 * 2 + 3", "((1 * 2) + 3)"},
+		{"1 - 2 - 3", "((1 - 2) - 3)"},
+		{"1 - 2 + 3 - 4", "(((1 - 2) + 3) - 4)"},
+		{"2 ^ 3 ^ 4", "(2 ^ (3 ^ 4))"},
+		{"(1 + 2) * 3", "((1 + 2) * 3)"},
+		{"1 + 2 * 3 ^ 4 / 5 - 6", "((1 + ((2 * (3 ^ 4)) / 5)) - 6)"},
+		{"- 1 * 2", "((neg1) * 2)"},
+		{"1 : 2", "(1 : 2)"},
+		{"1 : 2 : 3", "All results are rejected by disambiguation filters."},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			toks := benchLex(tt.input)
+			v, err := ExpectSingleResult(ExpectEOF(E.Parse(toks)))
+			actual := fmt.Sprintf("%v", v)
+			if err != nil {
+				actual = err.(*Error).Msg
+			}
+			if actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func TestPreferAvoid(t *testing.T) {
+	// 悬挂 else: S = if num then S [else S] | num
+	type stmt struct {
+		cond      string
+		then, els *stmt
+	}
+	var show func(s *stmt) string
+	show = func(s *stmt) string {
+		if s.then == nil {
+			return s.cond
+		}
+		if s.els == nil {
+			return fmt.Sprintf("if %s {%s}", s.cond, show(s.then))
+		}
+		return fmt.Sprintf("if %s {%s} else {%s}", s.cond, show(s.then), show(s.els))
+	}
+	S := NewRule[benchKind, *stmt]()
+	ifThen := Apply(Seq4(Str[benchKind]("if"), Tok(bNum), Str[benchKind]("then"), S.Parser()),
+		func(v Cons[Token[benchKind], Cons[Token[benchKind], Cons[Token[benchKind], *stmt]]]) *stmt {
+			return &stmt{cond: v.Cdr.Car.Lexeme(), then: v.Cdr.Cdr.Cdr}
+		})
+	ifElse := Apply(Seq3(ifThen, Str[benchKind]("else"), S.Parser()),
+		func(v Cons[*stmt, Cons[Token[benchKind], *stmt]]) *stmt {
+			return &stmt{cond: v.Car.cond, then: v.Car.then, els: v.Cdr.Cdr}
+		})
+	leaf := Apply(Tok(bNum), func(t Token[benchKind]) *stmt { return &stmt{cond: t.Lexeme()} })
+	pattern := Alt(ifElse, ifThen, leaf)
+
+	toks := keywordLex("if 1 then if 2 then 3 else 4")
+	for _, tt := range []struct {
+		name   string
+		f      Filter[*stmt]
+		expect string
+	}{
+		// else 与最近的 if 匹配: 避免 then 分支是没有 else 的 if
+		{"avoid", Avoid(func(s *stmt) bool { return s.els != nil && s.then.then != nil && s.then.els == nil }),
+			"if 1 {if 2 {3} else {4}}"},
+		{"prefer", Prefer(func(s *stmt) bool { return s.els != nil }),
+			"if 1 {if 2 {3}} else {4}"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			S.Pattern = Disambiguate(pattern, tt.f)
+			out := ExpectEOF(S.Parse(toks))
+			if !Ambiguous(ExpectEOF(pattern.Parse(toks))) {
+				t.Errorf("expect ambiguous grammar")
+			}
+			v, err := ExpectSingleResult(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := show(v); actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+		})
+	}
+
+	// Resolve 用于 Amb 的分组
+	xs := Resolve([]int{1, 2, 3, 4}, Reject(func(i int) bool { return i%2 == 0 }), Prefer(func(i int) bool { return i > 2 }))
+	if fmt.Sprint(xs) != "[3]" {
+		t.Errorf("unexpected %v", xs)
+	}
+
+	out := LongestMatch(Rep(Tok(bNum))).Parse(benchLex("1 2 3"))
+	if len(out.Candidates) != 1 || len(out.Candidates[0].Val) != 3 {
+		t.Errorf("unexpected %v", out)
+	}
+}
+
+func TestDisambiguateNodes(t *testing.T) {
+	// 两个具名规则消费相同的 token, 过滤器拒绝第一个, 保留的结果应携带自己的节点
+	a, b := NewRule[benchKind, string](), NewRule[benchKind, string]()
+	a.SetPattern("a", Apply(Tok(bNum), func(Token[benchKind]) string { return "a" }))
+	b.SetPattern("b", Apply(Tok(bNum), func(Token[benchKind]) string { return "b" }))
+	p := Disambiguate(Alt(a.Parser(), b.Parser()), Reject(func(v string) bool { return v == "a" }))
+	build := func(rule string, toks, rest []Token[benchKind], children []any) any { return rule }
+
+	out := BuildNodes(p, build).Parse(benchLex("1"))
+	if !out.Success || len(out.Candidates) != 1 {
+		t.Fatalf("unexpected %v", out)
+	}
+	r := out.Candidates[0]
+	if r.Val != "b" || fmt.Sprint(Nodes(r)) != "[b]" {
+		t.Errorf("expect b [b] actual %s %v", r.Val, Nodes(r))
+	}
+}
+
+// keywordLex if/then/else 作为 bStr
+func keywordLex(s string) []Token[benchKind] {
+	lex := lexer.BuildLexer(func(lex *lexer.Lexicon[benchKind]) {
+		lex.Regex(bSpace, `\s+`).Skip()
+		lex.Regex(bNum, `\d+`)
+		lex.Regex(bStr, `[a-z]+`)
+	})
+	toks := lex.MustLex(s)
+	xs := make([]Token[benchKind], len(toks))
+	for i, t := range toks {
+		xs[i] = t
+	}
+	return xs
+}
@@ -0,0 +1,116 @@
+package parsec
+
+// ----------------------------------------------------------------
+// Lazy Enumeration, 按需枚举候选结果
+// ----------------------------------------------------------------
+
+// Enumerator 可以按需枚举候选结果的 Parser
+// Alt, Seq, Rep, Combine, Apply, SyntaxRule 等组合子都实现了 Enumerator,
+// 深度优先地产生候选结果, yield 返回 false 时立即停止, 不再解析剩余的分支;
+// 候选结果的集合与 Parse 相同, 顺序在 Parse 按层展开的地方(歧义的 Rep)可能不同
+type Enumerator[K TK, R any] interface {
+	Parser[K, R]
+	// Enum 返回枚举过程中遇到的最远错误, 提前停止时只包含已经解析过的部分
+	Enum(toks []Token[K], yield func(Result[K, R]) bool) *Error
+}
+
+// Enum 按需枚举 p 的候选结果, p 没有实现 Enumerator 时退化为 Parse 后逐个 yield
+func Enum[K TK, R any](p Parser[K, R], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+	return EnumIn(nil, p, toks, yield)
+}
+
+// EnumIn 同 Enum, 在 st 中枚举, 用于 NewStatefulParser; st 为 nil 时开始一次新的解析
+func EnumIn[K TK, R any](st *State[K], p Parser[K, R], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+	if st == nil {
+		st = new(State[K])
+	}
+	if e, ok := p.(statefulEnum[K, R]); ok {
+		if st.maxDepth == 0 {
+			return e.enumIn(st, toks, yield)
+		}
+		if err := st.enter(toks); err != nil {
+			return err
+		}
+		defer st.leave()
+		return e.enumIn(st, toks, yield)
+	}
+	if e, ok := p.(Enumerator[K, R]); ok {
+		return e.Enum(toks, yield)
+	}
+	out := ParseIn(st, p, toks)
+	if out.Success {
+		for _, candidate := range out.Candidates {
+			if !yield(candidate) {
+				break
+			}
+		}
+	}
+	return out.Error
+}
+
+// ParseFirst 返回第一个消费全部 token 的候选结果, 找到后立即停止
+// 即按需求值的 ExpectEOF(p.Parse(toks)) 的第一个结果, 配合 AltSc, RepSc 等贪婪策略时耗时与消费的 token 成正比
+func ParseFirst[K TK, R any](p Parser[K, R], toks []Token[K]) Output[K, R] {
+	var xs []Result[K, R]
+	var err *Error
+	err = betterError(err, Enum(p, toks, func(r Result[K, R]) bool {
+		if len(r.next) == 0 {
+			xs = append(xs, r)
+			return false
+		}
+		err = betterError(err, notReachEOF(r.next))
+		return true
+	}))
+	return newOutput(xs, err, len(xs) != 0)
+}
+
+// ParseSingle 即按需求值的 ExpectSingleResult(ExpectEOF(p.Parse(toks))), 找到第二个结果时立即停止
+func ParseSingle[K TK, R any](p Parser[K, R], toks []Token[K]) (R, error) {
+	var xs []Result[K, R]
+	var err *Error
+	err = betterError(err, Enum(p, toks, func(r Result[K, R]) bool {
+		if len(r.next) == 0 {
+			xs = append(xs, r)
+			return len(xs) < 2
+		}
+		err = betterError(err, notReachEOF(r.next))
+		return true
+	}))
+	switch len(xs) {
+	case 0:
+		if err == nil {
+			err = newError(EOFPos, "No result is returned.")
+		}
+		return *new(R), err
+	case 1:
+		return xs[0].Val, nil
+	default:
+		return *new(R), newError(UnknownPos, "Multiple results are returned.")
+	}
+}
+
+// enumParser 同时提供穷举的 Parse 与按需的 Enum
+type enumParser[K TK, R any] struct {
+	parser[K, R]
+	enum func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error
+}
+
+func (p enumParser[K, R]) Enum(toks []Token[K], yield func(Result[K, R]) bool) *Error {
+	return p.enum(new(State[K]), toks, yield)
+}
+
+func (p enumParser[K, R]) enumIn(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+	return p.enum(st, toks, yield)
+}
+
+// statefulEnum 可以在外层的解析状态中枚举的 Parser
+type statefulEnum[K TK, R any] interface {
+	enumIn(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error
+}
+
+func withEnum[K TK, R any](
+	p parser[K, R],
+	enum func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error,
+) Parser[K, R] {
+	return enumParser[K, R]{p, enum}
+}
@@ -0,0 +1,100 @@
+package parsec
+
+import (
+	"strings"
+	"testing"
+)
+
+func enumAll[R any](p Parser[tokKind, R], toks []token) (bool, string, string) {
+	var xs []Result[tokKind, R]
+	err := Enum(p, toks, func(r Result[tokKind, R]) bool {
+		xs = append(xs, r)
+		return true
+	})
+	return outOf(newOutput(xs, err, len(xs) != 0))
+}
+
+// Enum 穷举的结果与 Parse 一致
+func TestEnum(t *testing.T) {
+	num := Tok(Number)
+	rule := NewRule[tokKind, []token]()
+	rule.SetPattern("rule", Seq(num, Alt(num, Tok(Ident)), num))
+	for _, tt := range []struct {
+		name  string
+		input string
+		p     func(toks []token) (bool, string, string)
+		enum  func(toks []token) (bool, string, string)
+	}{
+		{"alt", "1", wrap(Alt(num, num, Tok(Ident))), func(toks []token) (bool, string, string) {
+			return enumAll(Alt(num, num, Tok(Ident)), toks)
+		}},
+		{"altSc", "a", wrap(AltSc(num, Tok(Ident), Tok(Ident))), func(toks []token) (bool, string, string) {
+			return enumAll(AltSc(num, Tok(Ident), Tok(Ident)), toks)
+		}},
+		{"seq", "1 2 3", wrap(Seq(Opt(num), Opt(num), num)), func(toks []token) (bool, string, string) {
+			return enumAll(Seq(Opt(num), Opt(num), num), toks)
+		}},
+		{"seq2", "1 2", wrap(Seq2(Opt(num), Opt(num))), func(toks []token) (bool, string, string) {
+			return enumAll(Seq2(Opt(num), Opt(num)), toks)
+		}},
+		{"rep", "1 2 3", wrap(Rep(num)), func(toks []token) (bool, string, string) {
+			return enumAll(Rep(num), toks)
+		}},
+		{"repR", "1 2 3", wrap(RepR(num)), func(toks []token) (bool, string, string) {
+			return enumAll(RepR(num), toks)
+		}},
+		{"combine", "1 2", wrap(Combine(Opt(num), func(token) Parser[tokKind, token] { return Opt(num) })),
+			func(toks []token) (bool, string, string) {
+				return enumAll(Combine(Opt(num), func(token) Parser[tokKind, token] { return Opt(num) }), toks)
+			}},
+		{"rule", "1 a 2", wrap[[]token](rule), func(toks []token) (bool, string, string) {
+			return enumAll[[]token](rule, toks)
+		}},
+		{"fail", "1 a a", wrap[[]token](rule), func(toks []token) (bool, string, string) {
+			return enumAll[[]token](rule, toks)
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			toks := mustLex(tt.input)
+			succ1, res1, err1 := tt.p(toks)
+			succ2, res2, err2 := tt.enum(toks)
+			if succ1 != succ2 || res1 != res2 || err1 != err2 {
+				t.Errorf("\nparse: %v %s %s\nenum:  %v %s %s", succ1, res1, err1, succ2, res2, err2)
+			}
+		})
+	}
+}
+
+func TestParseFirst(t *testing.T) {
+	calls := 0
+	num := Apply(Tok(Number), func(v token) token { calls++; return v })
+	toks := mustLex(strings.Repeat("1 ", 2000))
+
+	// 只取最长的结果, 不展开其他路径
+	out := ParseFirst(Rep(num), toks)
+	if !out.Success || len(out.Candidates) != 1 || len(out.Candidates[0].Val) != 2000 {
+		t.Fatalf("unexpected %v", out.Error)
+	}
+	if calls != 2000 {
+		t.Errorf("expect 2000 calls actual %d", calls)
+	}
+
+	calls = 0
+	v, err := ParseSingle(Alt(Seq(num, num), Seq(num, num, num)), mustLex("1 2"))
+	if err != nil || len(v) != 2 || calls != 4 {
+		t.Errorf("unexpected %v %v %d", v, err, calls)
+	}
+
+	_, err = ParseSingle(Alt(num, num, Tok(Ident)), mustLex("1"))
+	if err == nil || err.Error() != "Multiple results are returned. in unknown" {
+		t.Errorf("unexpected %v", err)
+	}
+	_, err = ParseSingle(Seq(num, num), mustLex("1 2 3"))
+	_, expect := ExpectSingleResult(ExpectEOF(Seq(num, num).Parse(mustLex("1 2 3"))))
+	if err == nil || err.Error() != expect.Error() {
+		t.Errorf("expect %v actual %v", expect, err)
+	}
+	if out := ParseFirst(Seq(num, num), mustLex("1")); out.Success || out.Pos != EOFPos {
+		t.Errorf("unexpected %v", out)
+	}
+}
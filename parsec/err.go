@@ -8,23 +8,51 @@ package parsec
 // p 如果失败, 替换错误信息, 提供更准确错误信息
 // e.g. Err(Alt(Tok(Int), Tok(Float)), "expect number")
 func Err[K TK, R any](p Parser[K, R], msg string) Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
-		branches := p.Parse(toks)
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		branches := ParseIn(st, p, toks)
 		if branches.Success {
 			return branches
 		}
 		if msg == "" {
 			msg = branches.Msg
 		}
-		return fail[K, R](newError(branches.Pos, msg))
+		return fail[K, R](relabel(branches.Error, msg))
+	})
+}
+
+// ErrWith :: p[a] -> error -> p[a]
+// 同 Err, 错误信息为 err.Error(), 并记录 err, 可以用 errors.Is / errors.As 取出
+// e.g. ErrWith(Tok(Int), ErrExpectInt), errors.Is(out.Error, ErrExpectInt)
+func ErrWith[K TK, R any](p Parser[K, R], err error) Parser[K, R] {
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		branches := ParseIn(st, p, toks)
+		if branches.Success {
+			return branches
+		}
+		e := relabel(branches.Error, err.Error()).withInfo(func(i *errorInfo) { i.cause = err })
+		return fail[K, R](e)
+	})
+}
+
+// ErrCode :: p[a] -> code -> p[a]
+// p 如果失败, 设置错误码, 不替换错误信息, 调用方可以按 Error.Code 映射状态码而不必匹配字符串
+func ErrCode[K TK, R any](p Parser[K, R], code string) Parser[K, R] {
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		branches := ParseIn(st, p, toks)
+		if branches.Success {
+			return branches
+		}
+		e := *branches.Error
+		e.Code = code
+		return fail[K, R](&e)
 	})
 }
 
 // ErrD :: p[a] -> err -> -> a -> p[a]
 // p 如果失败, 返回默认值并替换错误信息, 返回成功, 不消耗 toks, 用来进行错误回复
 func ErrD[K TK, R any](p Parser[K, R], msg string, defaultValue R) Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
-		branches := p.Parse(toks)
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		branches := ParseIn(st, p, toks)
 		if branches.Success {
 			return branches
 		}
@@ -33,7 +61,14 @@ func ErrD[K TK, R any](p Parser[K, R], msg string, defaultValue R) Parser[K, R]
 		}
 		return successWithErr(
 			[]Result[K, R]{{Val: defaultValue, next: toks}},
-			newError(branches.Pos, msg),
+			relabel(branches.Error, msg),
 		)
 	})
 }
+
+// relabel 替换错误信息, 保留期望的 token
+func relabel(e *Error, msg string) *Error {
+	x := *e
+	x.Msg = msg
+	return &x
+}
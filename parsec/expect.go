@@ -0,0 +1,218 @@
+package parsec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// Expectation, 用于编辑器补全
+// ----------------------------------------------------------------
+
+// Expectation 光标处可以合法出现的 token
+type Expectation[K TK] struct {
+	Kind    K        // Tok(kind) 期望的 TokenKind
+	Literal string   // Str(lit) 期望的文本, 为空时按 Kind 匹配
+	Label   string   // Satisfy(pred, label) 的描述, 非空时期望任意满足 pred 的 token, Kind 与 Literal 无意义
+	Rules   []string // 所在的规则名, 由外向内
+}
+
+func (e Expectation[K]) String() string {
+	s := fmt.Sprintf("%v", e.Kind)
+	if e.Literal != "" {
+		s = "`" + e.Literal + "`"
+	}
+	if e.Label != "" {
+		s = e.Label
+	}
+	if len(e.Rules) == 0 {
+		return s
+	}
+	return s + " in " + strings.Join(e.Rules, " > ")
+}
+
+// Expected 解析 toks[:cursor], 返回光标处所有可以继续的 token
+// 复用 betterError 的最远错误: 所有停在输入末尾(EOFPos)的 Tok / Str / Satisfy 失败即为可以接续的 token,
+// 按 (Kind, Literal, Label) 去重, 保持首次出现的顺序; 前缀存在语法错误时返回 nil
+func Expected[K TK, R any](p Parser[K, R], toks []Token[K], cursor int) []Expectation[K] {
+	if cursor < 0 {
+		cursor = 0
+	}
+	if cursor > len(toks) {
+		cursor = len(toks)
+	}
+	out := p.Parse(toks[:cursor])
+	if out.Error == nil || out.Pos != EOFPos {
+		return nil
+	}
+
+	type key struct {
+		kind       K
+		lit, label string
+	}
+	seen := map[key]bool{}
+	var xs []Expectation[K]
+	for _, e := range out.expects {
+		x := Expectation[K]{Rules: e.rules.names()}
+		switch {
+		case e.isLit:
+			x.Literal = e.text
+		case e.isLabel:
+			x.Label = e.text
+		default:
+			x.Kind = e.kind.(K)
+		}
+		k := key{x.Kind, x.Literal, x.Label}
+		if !seen[k] {
+			seen[k] = true
+			xs = append(xs, x)
+		}
+	}
+	return xs
+}
+
+// expectation 记录在 Error 中, Tok / Str / Satisfy 失败时产生, betterError 合并同一位置的记录
+type expectation struct {
+	kind    any    // Tok
+	text    string // Str 的字面量或者 Satisfy 的描述
+	isLit   bool   // Str
+	isLabel bool   // Satisfy
+	rules   *ruleFrame
+}
+
+func (x expectation) String() string {
+	switch {
+	case x.isLit:
+		return "`" + x.text + "`"
+	case x.isLabel:
+		return x.text
+	default:
+		return fmt.Sprintf("%v", x.kind)
+	}
+}
+
+// ruleFrame 规则链, 由内向外链接, 同一次规则调用中的错误共享
+type ruleFrame struct {
+	name  string
+	pos   Pos // 规则开始的位置
+	outer *ruleFrame
+}
+
+// names 由外向内的规则名
+func (f *ruleFrame) names() (xs []string) {
+	for ; f != nil; f = f.outer {
+		xs = append(xs, f.name)
+	}
+	for i, j := 0, len(xs)-1; i < j; i, j = i+1, j-1 {
+		xs[i], xs[j] = xs[j], xs[i]
+	}
+	return
+}
+
+// expect 设置期望, xs 在各个 Error 之间共享, 只读
+func (e *Error) expect(xs []expectation) *Error {
+	e.expects = xs
+	return e
+}
+
+// ruleCall 正在解析的具名规则, 见 State.rules
+type ruleCall[K TK] struct {
+	name  string
+	toks  []Token[K]
+	frame *ruleFrame // 第一次记录错误时创建
+}
+
+// pushRule 进入具名规则
+func (st *State[K]) pushRule(name string, toks []Token[K]) {
+	st.rules = append(st.rules, ruleCall[K]{name: name, toks: toks})
+}
+
+func (st *State[K]) popRule() { st.rules = st.rules[:len(st.rules)-1] }
+
+// frame 第 i 层规则的规则链, 按需创建, 出栈前一直复用
+func (st *State[K]) frame(i int) *ruleFrame {
+	if i < 0 {
+		return nil
+	}
+	c := &st.rules[i]
+	if c.frame == nil {
+		c.frame = &ruleFrame{name: c.name, pos: startPos(c.toks), outer: st.frame(i - 1)}
+	}
+	return c.frame
+}
+
+// within 将 error 记录到当前的具名规则中, 包括规则栈与期望;
+// 只在错误第一次离开具名规则时复制一次, 之后外层的规则直接返回, 成功路径上不再分配
+func (st *State[K]) within(e *Error) *Error {
+	if e == nil {
+		return e
+	}
+	stamped := e.more().context != nil
+	for _, y := range e.expects {
+		if y.rules == nil {
+			stamped = false
+			break
+		}
+	}
+	if stamped {
+		return e
+	}
+	frame := st.frame(len(st.rules) - 1)
+	x := *e
+	if x.more().context == nil {
+		i := *x.more()
+		i.context = frame
+		x.info = &i
+	}
+	if len(e.expects) != 0 {
+		xs := make([]expectation, len(e.expects))
+		for i, y := range e.expects {
+			if y.rules == nil {
+				y.rules = frame
+			}
+			xs[i] = y
+		}
+		x.expects = xs
+	}
+	return &x
+}
+
+// mergeExpects 位置相同的错误, 保留 e1 的信息, 合并期望与附注
+func mergeExpects(e1, e2 *Error) *Error {
+	n1, n2 := e1.more().notes, e2.more().notes
+	if e1 == e2 || (len(e2.expects) == 0 && len(n2) == 0) {
+		return e1
+	}
+	var xs []expectation
+	for _, x := range e2.expects {
+		if !containsExpect(e1.expects, x) && !containsExpect(xs, x) {
+			xs = append(xs, x)
+		}
+	}
+	var ns []Note
+	for _, n := range n2 {
+		if !containsNote(n1, n) && !containsNote(ns, n) {
+			ns = append(ns, n)
+		}
+	}
+	if len(xs) == 0 && len(ns) == 0 {
+		return e1
+	}
+	x := *e1
+	x.expects = concat(e1.expects, xs...)
+	if len(ns) != 0 {
+		i := *e1.more()
+		i.notes = concat(n1, ns...)
+		x.info = &i
+	}
+	return &x
+}
+
+func containsExpect(xs []expectation, x expectation) bool {
+	for _, y := range xs {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package parsec
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExpected(t *testing.T) {
+	term := NewRule[tokKind, Token[tokKind]]()
+	exp := NewRule[tokKind, []Token[tokKind]]()
+	term.SetPattern("term", AltSc(Tok(Number), Tok(Ident)))
+	exp.SetPattern("exp", Apply(
+		Seq2(term.Parser(), RepSc(KRight(Str[tokKind]("+"), term.Parser()))),
+		func(v Cons[Token[tokKind], []Token[tokKind]]) []Token[tokKind] {
+			return concat([]Token[tokKind]{v.Car}, v.Cdr...)
+		},
+	))
+
+	for _, tt := range []struct {
+		input  string
+		cursor int
+		expect string
+	}{
+		{"", 0, "<num> in exp > term, <id> in exp > term"},
+		{"1", 1, "`+` in exp"},
+		{"1 +", 2, "<num> in exp > term, <id> in exp > term"},
+		{"1 + a", 1, "`+` in exp"},
+		{"1 + a", 100, "`+` in exp"},
+		{"1 1", 2, ""},
+		{"+", 1, ""},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			var xs []string
+			for _, e := range Expected[tokKind, []Token[tokKind]](exp, mustLex(tt.input), tt.cursor) {
+				xs = append(xs, e.String())
+			}
+			if actual := strings.Join(xs, ", "); actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+		})
+	}
+
+	// 替换错误信息保留期望
+	xs := Expected(Err(exp.Parser(), "expect expression"), mustLex("1 +"), 2)
+	if len(xs) != 2 || xs[0].Kind != Number || xs[1].Kind != Ident {
+		t.Errorf("unexpected %v", xs)
+	}
+
+	// Satisfy / NoneOf 记录 label
+	p := Seq2(Tok(Number), AltSc(NoneOf(Add, Number), Satisfy(func(t Token[tokKind]) bool { return t.Lexeme() == "+" }, "plus")))
+	xs = Expected[tokKind, Cons[Token[tokKind], Token[tokKind]]](p, mustLex("1"), 1)
+	if actual := fmt.Sprint(xs); actual != "[any token except +, <num> plus]" {
+		t.Errorf("unexpected %s", actual)
+	}
+	if err := p.Parse(mustLex("1 2")).Error; err.Detail() != "1:3: expected any token except +, <num> or plus" {
+		t.Errorf("unexpected %s", err.Detail())
+	}
+}
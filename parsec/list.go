@@ -0,0 +1,73 @@
+package parsec
+
+import "sync"
+
+// ----------------------------------------------------------------
+// Persistent List & Slice Pool
+// ----------------------------------------------------------------
+
+// plist 持久化链表, Seq / Rep 用来累积结果
+// 各分支共享前缀, 每步只追加一个节点, 避免 concat 复制整个切片(平方复杂度)
+type plist[R any] struct {
+	val  R
+	prev *plist[R]
+	n    int
+}
+
+func (l *plist[R]) push(v R) *plist[R] {
+	n := 1
+	if l != nil {
+		n = l.n + 1
+	}
+	return &plist[R]{val: v, prev: l, n: n}
+}
+
+// slice 转换为切片, 每次返回独立的底层数组, 调用者可以随意修改结果
+func (l *plist[R]) slice() []R {
+	if l == nil {
+		return []R{}
+	}
+	xs := make([]R, l.n)
+	for p := l; p != nil; p = p.prev {
+		xs[p.n-1] = p.val
+	}
+	return xs
+}
+
+// acc 累积中的路径
+type acc[K TK, R any] struct {
+	l     *plist[R]
+	next  []Token[K]
+	nodes *nodes
+}
+
+// slicePool 组合子实例私有的临时切片池, 递归调用与并发调用各自取用
+type slicePool[T any] struct {
+	sync.Pool
+}
+
+func (p *slicePool[T]) get() *[]T {
+	if v, ok := p.Get().(*[]T); ok {
+		return v
+	}
+	return new([]T)
+}
+
+func (p *slicePool[T]) put(xs *[]T) {
+	var zero T
+	s := *xs
+	for i := range s {
+		s[i] = zero // 不持有 token 与结果
+	}
+	*xs = s[:0]
+	p.Put(xs)
+}
+
+// results 将累积的路径转换为结果
+func results[K TK, R any](xs []acc[K, R]) []Result[K, []R] {
+	rs := make([]Result[K, []R], len(xs))
+	for i := range xs {
+		rs[i] = Result[K, []R]{Val: xs[i].l.slice(), next: xs[i].next, nodes: xs[i].nodes}
+	}
+	return rs
+}
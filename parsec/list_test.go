@@ -0,0 +1,42 @@
+package parsec
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 每个结果有独立的底层数组, 修改一个结果不影响其他结果
+func TestIndependentResults(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		p    Parser[tokKind, []Token[tokKind]]
+	}{
+		{"Rep", Rep(Tok(Number))},
+		{"RepR", RepR(Tok(Number))},
+		{"Seq", Alt(Seq(Tok(Number), Tok(Number)), Seq(Tok(Number), Tok(Number), Tok(Number)))},
+		{"Alt", Alt(Rep(Tok(Number)), RepR(Tok(Number)))},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.p.Parse(mustLex("1 2 3"))
+			if !out.Success || len(out.Candidates) < 2 {
+				t.Fatalf("unexpected %v", out)
+			}
+			expect := fmt.Sprintf("%v", out.Candidates[1:])
+			for i := range out.Candidates[0].Val {
+				out.Candidates[0].Val[i] = nil
+			}
+			_ = append(out.Candidates[0].Val[:0], nil)
+			if actual := fmt.Sprintf("%v", out.Candidates[1:]); actual != expect {
+				t.Errorf("expect %s actual %s", expect, actual)
+			}
+		})
+	}
+
+	// 前缀 append 不能覆盖其他结果
+	out := RepR(Tok(Number)).Parse(mustLex("1 2 3"))
+	xs := out.Candidates[1].Val
+	_ = append(xs, xs[0])
+	if actual := fmt.Sprintf("%v", out.Candidates); actual != "[[] [1] [1 2] [1 2 3]]" {
+		t.Errorf("unexpected %s", actual)
+	}
+}
@@ -0,0 +1,92 @@
+package parsec
+
+// ----------------------------------------------------------------
+// Parse Mode, 解析模式
+// ----------------------------------------------------------------
+
+// Mode 决定 Alt, Rep 等非 Sc 组合子(以及由它们构造的 Opt, Many, List, LRec, Chainl ...)的行为,
+// 同一套文法可以在探索(AllParsesMode)与生产(GreedyMode)之间切换, 不需要改写为 Sc 版本;
+// AltSc, RepSc 等 Sc 版本固定为贪婪策略, 不受 Mode 影响
+type Mode int
+
+const (
+	AllParsesMode Mode = iota // 返回所有候选结果, 默认
+	GreedyMode                // 返回第一个成功的分支, 重复尽可能多次, 即 PEG 语义
+	LongestMode               // 返回所有候选结果中消费 token 最多的, 保留歧义
+)
+
+func (m Mode) String() string {
+	switch m {
+	case GreedyMode:
+		return "Greedy"
+	case LongestMode:
+		return "Longest"
+	default:
+		return "AllParses"
+	}
+}
+
+// InMode :: p[a] -> Mode -> p[a]
+// p 的整棵子树(包括引用的规则)在 m 模式下解析, 可以嵌套, 内层优先;
+// 放在文法的根上即设置整次解析的模式, e.g. Greedy(expr).Parse(toks)
+func InMode[K TK, R any](p Parser[K, R], m Mode) Parser[K, R] {
+	return withEnum(parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		old := st.mode
+		st.mode = m
+		defer func() { st.mode = old }()
+		return ParseIn(st, p, toks)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+		old := st.mode
+		st.mode = m
+		defer func() { st.mode = old }()
+		return EnumIn(st, p, toks, func(r Result[K, R]) bool {
+			// yield 会继续解析 p 之后的部分, 需要恢复外层的模式
+			st.mode = old
+			defer func() { st.mode = m }()
+			return yield(r)
+		})
+	})
+}
+
+// AllParses :: p[a] -> p[a]
+// 即 InMode(p, AllParsesMode)
+func AllParses[K TK, R any](p Parser[K, R]) Parser[K, R] { return InMode(p, AllParsesMode) }
+
+// Greedy :: p[a] -> p[a]
+// 即 InMode(p, GreedyMode)
+func Greedy[K TK, R any](p Parser[K, R]) Parser[K, R] { return InMode(p, GreedyMode) }
+
+// Longest :: p[a] -> p[a]
+// 即 InMode(p, LongestMode), 与 LongestMatch 不同, 子树中的每个选择都只保留消费最多的结果
+func Longest[K TK, R any](p Parser[K, R]) Parser[K, R] { return InMode(p, LongestMode) }
+
+// moded 按当前模式选择 all 或 greedy
+func moded[K TK, R any](all, greedy Parser[K, R]) Parser[K, R] {
+	return withEnum(parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		switch st.mode {
+		case GreedyMode:
+			return ParseIn(st, greedy, toks)
+		case LongestMode:
+			return longest(ParseIn(st, all, toks))
+		default:
+			return ParseIn(st, all, toks)
+		}
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+		switch st.mode {
+		case GreedyMode:
+			return EnumIn(st, greedy, toks, yield)
+		case LongestMode:
+			out := longest(ParseIn(st, all, toks))
+			if out.Success {
+				for _, candidate := range out.Candidates {
+					if !yield(candidate) {
+						break
+					}
+				}
+			}
+			return out.Error
+		default:
+			return EnumIn(st, all, toks, yield)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package parsec
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMode(t *testing.T) {
+	lexeme := func(t Token[benchKind]) string { return t.Lexeme() }
+	join := func(xs []string) string { return "[" + strings.Join(xs, " ") + "]" }
+	num := Apply(Tok(bNum), lexeme)
+	pair := Apply(Seq(num, num), join)
+	rep := Apply(Rep(num), join)
+
+	for _, tt := range []struct {
+		name   string
+		p      Parser[benchKind, string]
+		input  string
+		expect [3]string // AllParses, Greedy, Longest
+	}{
+		{"alt", Alt(num, pair), "1 2",
+			[3]string{"[1 [1 2]]", "[1]", "[[1 2]]"}},
+		{"rep", rep, "1 2 3",
+			[3]string{"[[1 2 3] [1 2] [1] []]", "[[1 2 3]]", "[[1 2 3]]"}},
+		{"opt", Opt(num), "1",
+			[3]string{"[1 ]", "[1]", "[1]"}},
+		// Longest 保留消费相同的歧义
+		{"ambiguous", Alt(pair, Apply(Seq(num, num), func(xs []string) string { return "#" })), "1 2",
+			[3]string{"[[1 2] #]", "[[1 2]]", "[[1 2] #]"}},
+		// 规则的 Pattern 同样受影响
+		{"rule", func() Parser[benchKind, string] {
+			r := NewRule[benchKind, string]()
+			r.Pattern = Alt(num, pair)
+			return r
+		}(), "1 2",
+			[3]string{"[1 [1 2]]", "[1]", "[[1 2]]"}},
+	} {
+		for i, p := range []Parser[benchKind, string]{AllParses(tt.p), Greedy(tt.p), Longest(tt.p)} {
+			m := []Mode{AllParsesMode, GreedyMode, LongestMode}[i]
+			t.Run(tt.name+"/"+m.String(), func(t *testing.T) {
+				toks := benchLex(tt.input)
+				out := p.Parse(toks)
+				if !out.Success {
+					t.Fatal(out.Error)
+				}
+				if actual := fmt.Sprint(out.Candidates); actual != tt.expect[i] {
+					t.Errorf("expect %s actual %s", tt.expect[i], actual)
+				}
+				var xs []Result[benchKind, string]
+				Enum(p, toks, func(r Result[benchKind, string]) bool {
+					xs = append(xs, r)
+					return true
+				})
+				if len(xs) != len(out.Candidates) {
+					t.Errorf("expect %d candidates actual %d", len(out.Candidates), len(xs))
+				}
+			})
+		}
+	}
+}
+
+// 模式属于每次解析, 共享子文法的不同模式可以并发解析
+func TestConcurrentMode(t *testing.T) {
+	num := Apply(Tok(bNum), func(t Token[benchKind]) string { return t.Lexeme() })
+	rep := Rep(num)
+	toks := benchLex("1 2 3")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				p, expect := rep, 4
+				if i%2 == 0 {
+					p, expect = Greedy(rep), 1
+				}
+				if n := len(p.Parse(toks).Candidates); n != expect {
+					t.Errorf("expect %d candidates actual %d", expect, n)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestNestedMode(t *testing.T) {
+	join := func(xs []string) string { return "[" + strings.Join(xs, " ") + "]" }
+	num := Apply(Tok(bNum), func(t Token[benchKind]) string { return t.Lexeme() })
+	toks := benchLex("1 2")
+
+	// 内层 AllParses 之后的 Rep 恢复为外层的 Greedy
+	p := Greedy(Apply(Seq(Apply(AllParses(Rep(num)), join), Apply(Rep(num), join)), join))
+	expect := "[[[1 2] []] [[1] [2]] [[] [1 2]]]"
+	if actual := fmt.Sprint(p.Parse(toks).Candidates); actual != expect {
+		t.Errorf("expect %s actual %s", expect, actual)
+	}
+	var xs []string
+	Enum(p, toks, func(r Result[benchKind, string]) bool {
+		xs = append(xs, r.Val)
+		return true
+	})
+	if actual := join(xs); actual != expect {
+		t.Errorf("expect %s actual %s", expect, actual)
+	}
+
+	// 默认模式不变, 可以切换同一套文法
+	if n := len(Rep(num).Parse(toks).Candidates); n != 3 {
+		t.Errorf("expect 3 candidates actual %d", n)
+	}
+}
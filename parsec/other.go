@@ -33,8 +33,8 @@ func FlatMap[K TK, R1, R2 any](p Parser[K, R1], k func(R1) Parser[K, R2]) Parser
 
 // Lazy :: (() -> p[a]) -> p[a]
 func Lazy[K TK, R any](thunk func() Parser[K, R]) Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
-		return thunk().Parse(toks)
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		return ParseIn(st, thunk(), toks)
 	})
 }
 
@@ -45,6 +45,14 @@ func Between[K TK, A, B, C any](open Parser[K, A], p Parser[K, B], close Parser[
 	return KMid(open, p, close)
 }
 
+// Delimited 同 Between, open 为单个 token, close 失败时错误附注 open 的位置
+// e.g. Delimited(Str("("), args, Str(")")), Detail: "expected `)` to close `(` opened at 1:5"
+func Delimited[K TK, B, C any](open Parser[K, Token[K]], p Parser[K, B], close Parser[K, C]) Parser[K, B] {
+	return Combine2(open, func(t Token[K]) Parser[K, B] {
+		return KLeft(p, WithNote(close, t, "to close `"+t.Lexeme()+"` opened at "+shortLoc(t)))
+	})
+}
+
 func Count[K TK, R any](p Parser[K, R], cnt int) Parser[K, []R] { return RepN(p, cnt) }
 
 func Many[K TK, R any](p Parser[K, R]) Parser[K, []R]   { return Rep(p) }
@@ -132,11 +140,53 @@ func SepBy1Sc[K TK, S, R any](p Parser[K, R], sep Parser[K, S]) Parser[K, []R] {
 	return ListSc(p, sep)
 }
 
+// SepEndBy p 被 sep 分隔的 >=0 个 p, 可以以 sep 结尾
+// sepEndBy1 p sep <|> return []
+func SepEndBy[K TK, S, R any](p Parser[K, R], sep Parser[K, S]) Parser[K, []R] {
+	return Opt(SepEndBy1(p, sep))
+}
+
+// SepEndBy1 p 被 sep 分隔的 >=1 个 p, 可以以 sep 结尾
+func SepEndBy1[K TK, S, R any](p Parser[K, R], sep Parser[K, S]) Parser[K, []R] {
+	return KLeft(List(p, sep), Opt(sep))
+}
+
+// SepEndBySc p 被 sep 分隔的 >=0 个 p, 可以以 sep 结尾, 返回最长序列并消费结尾的 sep
+func SepEndBySc[K TK, S, R any](p Parser[K, R], sep Parser[K, S]) Parser[K, []R] {
+	return OptSc(SepEndBy1Sc(p, sep))
+}
+
+// SepEndBy1Sc p 被 sep 分隔的 >=1 个 p, 可以以 sep 结尾, 返回最长序列并消费结尾的 sep
+func SepEndBy1Sc[K TK, S, R any](p Parser[K, R], sep Parser[K, S]) Parser[K, []R] {
+	return KLeft(ListSc(p, sep), OptSc(sep))
+}
+
+// EndBy >=0 个以 sep 结尾的 p
+// many (do{ x <- p; sep; return x })
+func EndBy[K TK, S, R any](p Parser[K, R], sep Parser[K, S]) Parser[K, []R] {
+	return Many(KLeft(p, sep))
+}
+
+// EndBy1 >=1 个以 sep 结尾的 p
+func EndBy1[K TK, S, R any](p Parser[K, R], sep Parser[K, S]) Parser[K, []R] {
+	return Many1(KLeft(p, sep))
+}
+
+// EndBySc >=0 个以 sep 结尾的 p, 返回最长序列
+func EndBySc[K TK, S, R any](p Parser[K, R], sep Parser[K, S]) Parser[K, []R] {
+	return ManySc(KLeft(p, sep))
+}
+
+// EndBy1Sc >=1 个以 sep 结尾的 p, 返回最长序列
+func EndBy1Sc[K TK, S, R any](p Parser[K, R], sep Parser[K, S]) Parser[K, []R] {
+	return Many1Sc(KLeft(p, sep))
+}
+
 // LookAhead
-// peek p 的值, 如果失败会消费 token, 如果不期望消费可以 LookAhead(Try(p))
+// peek p 的值, 如果失败会消费 token, 如果不期望消费可以 LookAhead(Try(p)); 不消费 token 的 PEG 谓词见 peg.And
 func LookAhead[K TK, R any](p Parser[K, R]) Parser[K, []R] {
-	return parser[K, []R](func(toks []Token[K]) Output[K, []R] {
-		out := p.Parse(toks)
+	return parser[K, []R](func(st *State[K], toks []Token[K]) Output[K, []R] {
+		out := ParseIn(st, p, toks)
 		if !out.Success {
 			return failOf[K, R, []R](out)
 		}
@@ -155,9 +205,10 @@ func LookAhead[K TK, R any](p Parser[K, R]) Parser[K, []R] {
 // 可以写成 let := Left(Str("let"), NotFollowedBy(Regex(`[\d\w]+`)))
 // try (do{ c <- try p; unexpected (show c) } <|> return () )
 // e.g. KLeft(Tok(Number), NotFollowedBy(Tok(Add)))
+// 成功时返回零值, 返回 struct{} 的 PEG 谓词见 peg.Not
 func NotFollowedBy[K TK, R any](p Parser[K, R]) Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
-		out := p.Parse(toks)
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		out := ParseIn(st, p, toks)
 		if !out.Success {
 			return success([]Result[K, R]{{next: toks}})
 		}
@@ -184,21 +235,12 @@ func Chainl[K TK, R any](
 // Chainl1 构造左结合双目运算符解析, 可以用来处理左递归文法
 // parse >=1 次被 op 分隔的 p, 返回左结合调用 f 得到的值
 // do { x <- p; rest x } where rest x = do{ f <- op ; y <- p ; rest (f x y) } <|> return x
+// 即 LRec(p, Seq2(op, p), ...), 迭代实现, 长链不会耗尽栈
 func Chainl1[K TK, R any](
 	p Parser[K, R],
 	op Parser[K, func(R, R) R],
 ) Parser[K, R] {
-	var chain1Rest func(lval R) Parser[K, R]
-	chain1Rest = func(lval R) Parser[K, R] {
-		opv := Combine2(op, func(f func(R, R) R) Parser[K, R] {
-			// 左结合: 优先匹配 p(即 term), 然后递归的匹配 term op
-			return Combine2(p, func(rval R) Parser[K, R] {
-				return chain1Rest(f(lval, rval))
-			})
-		})
-		return Alt(opv, Succ[K, R](lval))
-	}
-	return Combine2(p, chain1Rest)
+	return Apply(Seq2(p, Rep(Seq2(op, p))), chainl[R])
 }
 
 // Chainr 构造右结合双目运算符解析
@@ -215,19 +257,12 @@ func Chainr[K TK, R any](
 // Chainr1 构造右结合双目运算符解析
 // parse >=1 次被 op 分隔的 p, 返回右结合调用 f 得到的值
 // do{ x <- p; rest x } where rest x = do{ f <- op ; y <- scan ; return (f x y)  } <|> return x
+// 迭代实现, 先解析 p {op p}, 再从右向左折叠, 长链不会耗尽栈
 func Chainr1[K TK, R any](
 	p Parser[K, R],
 	op Parser[K, func(R, R) R],
 ) Parser[K, R] {
-	return Combine2(p, func(lval R) Parser[K, R] {
-		seq := Combine2(op, func(f func(R, R) R) Parser[K, R] {
-			// 右结合就是自然地递归下降
-			return Combine2(Chainr1(p, op), func(rval R) Parser[K, R] {
-				return Succ[K, R](f(lval, rval))
-			})
-		})
-		return Alt(seq, Succ[K, R](lval))
-	})
+	return Apply(Seq2(p, Rep(Seq2(op, p))), chainr[R])
 }
 
 // ChainlSc 构造左结合双目运算符解析, 可以用来处理左递归文法
@@ -244,21 +279,12 @@ func ChainlSc[K TK, R any](
 // Chainl1Sc 构造左结合双目运算符解析, 可以用来处理左递归文法
 // parse >=1 次被 op 分隔的 p, 返回左结合调用 f 得到的值
 // do { x <- p; rest x } where rest x = do{ f <- op ; y <- p ; rest (f x y) } <|> return x
+// 即 LRecSc(p, Seq2(op, p), ...), 迭代实现, 长链不会耗尽栈
 func Chainl1Sc[K TK, R any](
 	p Parser[K, R],
 	op Parser[K, func(R, R) R],
 ) Parser[K, R] {
-	var chain1Rest func(lval R) Parser[K, R]
-	chain1Rest = func(lval R) Parser[K, R] {
-		opv := Combine2(op, func(f func(R, R) R) Parser[K, R] {
-			// 左结合: 优先匹配 p(即 term), 然后递归的匹配 term op
-			return Combine2(p, func(rval R) Parser[K, R] {
-				return chain1Rest(f(lval, rval))
-			})
-		})
-		return AltSc(opv, Succ[K, R](lval))
-	}
-	return Combine2(p, chain1Rest)
+	return Apply(Seq2(p, RepSc(Seq2(op, p))), chainl[R])
 }
 
 // ChainrSc 构造右结合双目运算符解析
@@ -275,17 +301,33 @@ func ChainrSc[K TK, R any](
 // Chainr1Sc 构造右结合双目运算符解析
 // parse >=1 次被 op 分隔的 p, 返回右结合调用 f 得到的值
 // do{ x <- p; rest x } where rest x = do{ f <- op ; y <- scan ; return (f x y)  } <|> return x
+// 迭代实现, 先解析 p {op p}, 再从右向左折叠, 长链不会耗尽栈
 func Chainr1Sc[K TK, R any](
 	p Parser[K, R],
 	op Parser[K, func(R, R) R],
 ) Parser[K, R] {
-	return Combine2(p, func(lval R) Parser[K, R] {
-		seq := Combine2(op, func(f func(R, R) R) Parser[K, R] {
-			// 右结合就是自然地递归下降
-			return Combine2(Chainr1Sc(p, op), func(rval R) Parser[K, R] {
-				return Succ[K, R](f(lval, rval))
-			})
-		})
-		return AltSc(seq, Succ[K, R](lval))
+	return Apply(Seq2(p, RepSc(Seq2(op, p))), chainr[R])
+}
+
+// chainl x0 f1 x1 f2 x2 ... => f2(f1(x0, x1), x2)
+func chainl[R any](v Cons[R, []Cons[func(R, R) R, R]]) R {
+	return foldLeft(v.Cdr, v.Car, func(l R, r Cons[func(R, R) R, R]) R {
+		return r.Car(l, r.Cdr)
 	})
 }
+
+// chainr x0 f1 x1 f2 x2 ... => f1(x0, f2(x1, x2))
+func chainr[R any](v Cons[R, []Cons[func(R, R) R, R]]) R {
+	if len(v.Cdr) == 0 {
+		return v.Car
+	}
+	acc := v.Cdr[len(v.Cdr)-1].Cdr
+	for i := len(v.Cdr) - 1; i >= 0; i-- {
+		l := v.Car
+		if i > 0 {
+			l = v.Cdr[i-1].Cdr
+		}
+		acc = v.Cdr[i].Car(l, acc)
+	}
+	return acc
+}
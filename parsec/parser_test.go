@@ -1,6 +1,7 @@
 package parsec
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -821,6 +822,222 @@ func TestParser(t *testing.T) {
 			result:  "{v=123, next=}",
 			error:   "",
 		},
+		{
+			name:    "Parser: ManyTill",
+			input:   "1 + 2 + 3",
+			p:       wrap(ManyTill(Any[tokKind](), Tok(Add))),
+			success: true,
+			result:  "{v=[1], next=<num>/2🍌+/+🍌<num>/3}🍊{v=[1 + 2], next=<num>/3}",
+			error:   "Nothing to consume expect `+` in end of input",
+		},
+		{
+			name:    "Parser: ManyTillSc",
+			input:   "1 2 + 3",
+			p:       wrap(ManyTillSc(Any[tokKind](), Tok(Add))),
+			success: true,
+			result:  "{v=[1 2], next=<num>/3}",
+			error:   "Unable to consume token `2` expect `+` in pos 3-4 line 1 col 3",
+		},
+		{
+			name:    "Parser: ManyTillSc",
+			input:   "+ 1",
+			p:       wrap(ManyTillSc(Tok(Number), Tok(Add))),
+			success: true,
+			result:  "{v=[], next=<num>/1}",
+			error:   "",
+		},
+		{
+			name:    "Parser: ManyTillSc",
+			input:   "1 2",
+			p:       wrap(ManyTillSc(Tok(Number), Tok(Add))),
+			success: false,
+			result:  "",
+			error:   "Nothing to consume expect `+` in end of input",
+		},
+		{
+			name:    "Parser: SepEndBy",
+			input:   "1 + 2 +",
+			p:       wrap(SepEndBy(Tok(Number), Tok(Add))),
+			success: true,
+			result:  "{v=[1 2], next=}🍊{v=[1 2], next=+/+}🍊{v=[1], next=<num>/2🍌+/+}🍊{v=[1], next=+/+🍌<num>/2🍌+/+}🍊{v=[], next=<num>/1🍌+/+🍌<num>/2🍌+/+}",
+			error:   "Nothing to consume expect `<num>` in end of input",
+		},
+		{
+			name:    "Parser: SepEndBySc",
+			input:   "1 + 2 +",
+			p:       wrap(SepEndBySc(Tok(Number), Tok(Add))),
+			success: true,
+			result:  "{v=[1 2], next=}",
+			error:   "Nothing to consume expect `<num>` in end of input",
+		},
+		{
+			name:    "Parser: SepEndBySc",
+			input:   "1 + 2",
+			p:       wrap(SepEndBySc(Tok(Number), Tok(Add))),
+			success: true,
+			result:  "{v=[1 2], next=}",
+			error:   "Nothing to consume expect `+` in end of input",
+		},
+		{
+			name:    "Parser: SepEndBy1Sc",
+			input:   "",
+			p:       wrap(SepEndBy1Sc(Tok(Number), Tok(Add))),
+			success: false,
+			result:  "",
+			error:   "Nothing to consume expect `<num>` in end of input",
+		},
+		{
+			name:    "Parser: EndBy",
+			input:   "1 + 2 + 3",
+			p:       wrap(EndBy(Tok(Number), Tok(Add))),
+			success: true,
+			result:  "{v=[1 2], next=<num>/3}🍊{v=[1], next=<num>/2🍌+/+🍌<num>/3}🍊{v=[], next=<num>/1🍌+/+🍌<num>/2🍌+/+🍌<num>/3}",
+			error:   "Nothing to consume expect `+` in end of input",
+		},
+		{
+			name:    "Parser: EndBySc",
+			input:   "1 + 2 + 3",
+			p:       wrap(EndBySc(Tok(Number), Tok(Add))),
+			success: true,
+			result:  "{v=[1 2], next=<num>/3}",
+			error:   "Nothing to consume expect `+` in end of input",
+		},
+		{
+			name:    "Parser: EndBy1Sc",
+			input:   "1",
+			p:       wrap(EndBy1Sc(Tok(Number), Tok(Add))),
+			success: false,
+			result:  "",
+			error:   "Nothing to consume expect `+` in end of input",
+		},
+		{
+			name:    "Parser: RepBetween",
+			input:   "1 2 3",
+			p:       wrap(RepBetween(Tok(Number), 1, 2)),
+			success: true,
+			result:  "{v=[1 2], next=<num>/3}🍊{v=[1], next=<num>/2🍌<num>/3}",
+			error:   "",
+		},
+		{
+			name:    "Parser: RepBetweenSc",
+			input:   "1 2 3",
+			p:       wrap(RepBetweenSc(Tok(Number), 1, 2)),
+			success: true,
+			result:  "{v=[1 2], next=<num>/3}",
+			error:   "",
+		},
+		{
+			name:    "Parser: RepBetweenSc",
+			input:   "1 2 3",
+			p:       wrap(RepBetweenSc(Tok(Number), 2, -1)),
+			success: true,
+			result:  "{v=[1 2 3], next=}",
+			error:   "Nothing to consume expect `<num>` in end of input",
+		},
+		{
+			name:    "Parser: RepBetweenSc",
+			input:   "1",
+			p:       wrap(RepBetweenSc(Tok(Number), 2, -1)),
+			success: false,
+			result:  "",
+			error:   "Nothing to consume expect `<num>` in end of input",
+		},
+		{
+			name:    "Parser: RepBetween",
+			input:   "1",
+			p:       wrap(RepBetween(Tok(Number), 0, 0)),
+			success: true,
+			result:  "{v=[], next=<num>/1}",
+			error:   "",
+		},
+		{
+			name:    "Parser: OneOf",
+			input:   "abc",
+			p:       wrap(OneOf(Number, Ident)),
+			success: true,
+			result:  "{v=abc, next=}",
+			error:   "",
+		},
+		{
+			name:    "Parser: OneOf",
+			input:   "+",
+			p:       wrap(OneOf(Number, Ident)),
+			success: false,
+			result:  "",
+			error:   "Unable to consume token `+` expect `<num>` or `<id>` in pos 1-2 line 1 col 1",
+		},
+		{
+			name:    "Parser: NoneOf",
+			input:   "1",
+			p:       wrap(NoneOf(Add, Ident)),
+			success: true,
+			result:  "{v=1, next=}",
+			error:   "",
+		},
+		{
+			name:    "Parser: NoneOf",
+			input:   "+",
+			p:       wrap(NoneOf(Add, Ident)),
+			success: false,
+			result:  "",
+			error:   "Unable to consume token `+` expect `any token except +, <id>` in pos 1-2 line 1 col 1",
+		},
+		{
+			name:    "Parser: Satisfy",
+			input:   "2",
+			p:       wrap(Satisfy(func(t token) bool { return t.Lexeme() == "1" }, "one")),
+			success: false,
+			result:  "",
+			error:   "Unable to consume token `2` expect `one` in pos 1-2 line 1 col 1",
+		},
+		{
+			name:    "Parser: OptionOr",
+			input:   "abc",
+			p:       wrap(OptionOr("0", Apply(Tok(Number), token.Lexeme))),
+			success: true,
+			result:  "{v=0, next=<id>/abc}",
+			error:   "Unable to consume token `abc` expect `<num>` in pos 1-4 line 1 col 1",
+		},
+		{
+			name:    "Parser: OptionOrSc",
+			input:   "abc",
+			p:       wrap(OptionOrSc("0", Apply(Tok(Number), token.Lexeme))),
+			success: true,
+			result:  "{v=0, next=<id>/abc}",
+			error:   "Unable to consume token `abc` expect `<num>` in pos 1-4 line 1 col 1",
+		},
+		{
+			name:    "Parser: OptionMaybe",
+			input:   "1",
+			p:       wrap(OptionMaybe(Apply(Tok(Number), token.Lexeme))),
+			success: true,
+			result:  "{v={true 1}, next=}🍊{v={false }, next=<num>/1}",
+			error:   "",
+		},
+		{
+			name:    "Parser: OptionMaybeSc",
+			input:   "abc",
+			p:       wrap(OptionMaybeSc(Apply(Tok(Number), token.Lexeme))),
+			success: true,
+			result:  "{v={false }, next=<id>/abc}",
+			error:   "Unable to consume token `abc` expect `<num>` in pos 1-4 line 1 col 1",
+		},
+		{
+			name:    "Parser: EOF",
+			input:   "",
+			p:       wrap(EOF[tokKind]()),
+			success: true,
+			result:  "{v={}, next=}",
+			error:   "",
+		},
+		{
+			name:    "Parser: EOF",
+			input:   "1 2",
+			p:       wrap(KLeft(Tok(Number), EOF[tokKind]())),
+			success: false,
+			result:  "",
+			error:   "Unable to consume token `2` expect `end of input` in pos 3-4 line 1 col 3",
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			toks := mustLex(tt.input)
@@ -1306,6 +1523,35 @@ func TestAmbParser(t *testing.T) {
 	}
 }
 
+func TestAmbOrder(t *testing.T) {
+	num := Apply(Tok(Number), func(t token) string { return t.Lexeme() })
+	join := func(xs []string) string { return strings.Join(xs, " ") }
+	p := Alt(
+		Apply(Seq(num), join),
+		Apply(Seq(num, num), join),
+		Apply(Seq(num), func(xs []string) string { return "(" + join(xs) + ")" }),
+	)
+	toks := mustLex("1 2")
+	for i := 0; i < 20; i++ {
+		_, out, _ := outOf(Amb(p).Parse(toks))
+		if expect := "{v=[1 2], next=}🍊{v=[1 (1)], next=<num>/2}"; out != expect {
+			t.Fatalf("expect %s actual %s", expect, out)
+		}
+	}
+
+	merged := AmbWith(p, func(xs []string) string { return "amb(" + strings.Join(xs, ", ") + ")" })
+	if _, out, _ := outOf(merged.Parse(toks)); out != "{v=1 2, next=}🍊{v=amb(1, (1)), next=<num>/2}" {
+		t.Errorf("unexpected %s", out)
+	}
+
+	if !Ambiguous(p.Parse(toks)) {
+		t.Errorf("expect ambiguous")
+	}
+	if Ambiguous(Amb(p).Parse(toks)) || Ambiguous(merged.Parse(toks)) {
+		t.Errorf("expect unambiguous")
+	}
+}
+
 func TestFailure(t *testing.T) {
 	for _, tt := range []struct {
 		name    string
@@ -1427,6 +1673,22 @@ func TestFailure(t *testing.T) {
 			result:  "",
 			error:   "This is not a number! in pos 1-2 line 1 col 1",
 		},
+		{
+			name:    "Failure: err with",
+			input:   "a",
+			p:       wrap(ErrWith(Tok(Number), errNotNumber)),
+			success: false,
+			result:  "",
+			error:   "not a number in pos 1-2 line 1 col 1",
+		},
+		{
+			name:    "Failure: err code",
+			input:   "a",
+			p:       wrap(ErrCode(Tok(Number), "E001")),
+			success: false,
+			result:  "",
+			error:   "Unable to consume token `a` expect `<num>` in pos 1-2 line 1 col 1",
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			toks := mustLex(tt.input)
@@ -1444,6 +1706,148 @@ func TestFailure(t *testing.T) {
 	}
 }
 
+var errNotNumber = errors.New("not a number")
+
+func TestTypedError(t *testing.T) {
+	p := ErrCode(Seq(Tok(Ident), ErrWith(Tok(Number), errNotNumber)), "E001")
+	_, err := ExpectSingleResult(ExpectEOF(p.Parse(mustLex("a b"))))
+
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expect *Error actual %#v", err)
+	}
+	if e.Code != "E001" {
+		t.Errorf("expect code E001 actual %s", e.Code)
+	}
+	if !errors.Is(err, errNotNumber) || errors.Unwrap(err) != errNotNumber {
+		t.Errorf("expect cause %v", errNotNumber)
+	}
+	if _, _, col, _ := e.Loc(); col != 2 {
+		t.Errorf("expect col 2 actual %d", col)
+	}
+
+	// 未设置 cause
+	_, err = ExpectSingleResult(Tok(Number).Parse(mustLex("a")))
+	if errors.Unwrap(err) != nil || errors.Is(err, errNotNumber) {
+		t.Errorf("unexpected cause %v", errors.Unwrap(err))
+	}
+}
+
+func TestApplyE(t *testing.T) {
+	errRange := errors.New("number out of range")
+	u8 := ApplyE(Tok(Number), func(t token) (int, error) {
+		n, err := strconv.Atoi(t.Lexeme())
+		if err != nil || n > 255 {
+			return 0, errRange
+		}
+		return n, nil
+	})
+	unique := func(xs []token) bool {
+		seen := map[string]bool{}
+		for _, x := range xs {
+			if seen[x.Lexeme()] {
+				return false
+			}
+			seen[x.Lexeme()] = true
+		}
+		return true
+	}
+	lexemes := func(xs []token) string {
+		ys := make([]string, len(xs))
+		for i, x := range xs {
+			ys[i] = x.Lexeme()
+		}
+		return strings.Join(ys, " ")
+	}
+
+	for _, tt := range []struct {
+		name    string
+		input   string
+		p       func(toks []token) (bool, string, string)
+		success bool
+		result  string
+		error   string
+	}{
+		{
+			name:    "ApplyE: success",
+			input:   "255",
+			p:       wrap(u8),
+			success: true,
+			result:  "{v=255, next=}",
+		},
+		{
+			name:  "ApplyE: range",
+			input: "256",
+			p:     wrap(u8),
+			error: "number out of range in pos 1-4 line 1 col 1",
+		},
+		{
+			// 语义错误与其他分支的错误在同一位置, 保留语义错误的信息
+			name:  "ApplyE: alt",
+			input: "256",
+			p:     wrap(Alt(u8, Apply(Tok(Ident), func(token) int { return 0 }))),
+			error: "number out of range in pos 1-4 line 1 col 1",
+		},
+		{
+			name:    "ApplyE: alt success",
+			input:   "256",
+			p:       wrap(Alt(u8, Apply(Tok(Number), func(token) int { return -1 }))),
+			success: true,
+			result:  "{v=-1, next=}",
+			error:   "number out of range in pos 1-4 line 1 col 1",
+		},
+		{
+			// 错误位置为整个候选结果的范围, 比较远近时按范围之后的位置
+			name:  "Guard: duplicate",
+			input: "a b a",
+			p:     wrap(Apply(Guard(RepSc(Tok(Ident)), unique, "duplicate key"), lexemes)),
+			error: "duplicate key in pos 1-6 line 1 col 1",
+		},
+		{
+			name:    "Guard: rep",
+			input:   "a b a",
+			p:       wrap(Apply(Guard(Rep(Tok(Ident)), unique, "duplicate key"), lexemes)),
+			success: true,
+			result:  "{v=a b, next=<id>/a}🍊{v=a, next=<id>/b🍌<id>/a}🍊{v=, next=<id>/a🍌<id>/b🍌<id>/a}",
+			error:   "duplicate key in pos 1-6 line 1 col 1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			succ, out, err := tt.p(mustLex(tt.input))
+			if tt.success != succ {
+				t.Errorf("[succ]expect %v actual %v", tt.success, succ)
+			}
+			if out != tt.result {
+				t.Errorf("[out]expect %s actual %s", tt.result, out)
+			}
+			if err != tt.error {
+				t.Errorf("[err]expect %s actual %s", tt.error, err)
+			}
+		})
+	}
+
+	_, err := ExpectSingleResult(ExpectEOF(u8.Parse(mustLex("1000"))))
+	if !errors.Is(err, errRange) {
+		t.Errorf("expect %v actual %v", errRange, err)
+	}
+	out := ExpectEOF(Guard(Rep(Tok(Ident)), unique, "duplicate key").Parse(mustLex("a b a")))
+	if out.Success || out.Error.Error() != "duplicate key in pos 1-6 line 1 col 1" {
+		t.Errorf("unexpected %v", out)
+	}
+
+	// 多个语义错误在同一位置时, Parse 与 Enum 报告相同的错误
+	same := ApplyE(Alt(
+		Apply(Tok(Number), func(token) string { return "first" }),
+		Apply(Tok(Number), func(token) string { return "second" }),
+	), func(s string) (int, error) { return 0, errors.New(s) })
+	toks := mustLex("1")
+	perr := same.Parse(toks).Error
+	eerr := Enum(same, toks, func(Result[tokKind, int]) bool { return true })
+	if perr == nil || eerr == nil || perr.Error() != eerr.Error() {
+		t.Errorf("expect same error actual %v %v", perr, eerr)
+	}
+}
+
 func wrap[R any](p Parser[tokKind, R]) func(toks []token) (bool, string, string) {
 	return func(toks []token) (bool, string, string) {
 		return outOf(p.Parse(toks))
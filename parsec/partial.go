@@ -0,0 +1,78 @@
+package parsec
+
+// ----------------------------------------------------------------
+// Partial Result, 失败时的部分结果
+// ----------------------------------------------------------------
+
+// Partial 解析失败时已经成功的部分, 用于 IDE 大纲等在语法错误时仍然展示已解析的内容
+type Partial[R any] struct {
+	Val      R        // 消费 token 最多的成功前缀的结果, Ok 为 false 时为零值
+	Ok       bool     // 是否存在成功的前缀
+	Consumed int      // 成功前缀消费的 token 数
+	Rules    []string // 失败处的规则栈, 由外向内
+	Farthest Fragment // 解析过程中结束位置最远的具名规则的结果, 根失败时(e.g. 未闭合的括号)仍然可用
+}
+
+// Fragment 具名规则成功解析的片段, Rule 为空表示没有
+type Fragment struct {
+	Rule       string
+	Val        any // 规则的结果
+	Start, End int // 消费的 token 下标范围 [Start, End)
+}
+
+// farthest ParsePartial 期间记录结束位置最远的具名规则的结果, 同 betterError 记录最远的错误
+type farthest struct {
+	total int
+	frag  Fragment
+}
+
+// record 记录规则 rule 从剩余 from 个 token 处开始, 到剩余 to 个 token 处结束的结果;
+// 结束位置相同时后返回的外层规则覆盖内层的
+func (f *farthest) record(rule string, from, to int, val any) {
+	if end := f.total - to; f.frag.Rule == "" || end >= f.frag.End {
+		f.frag = Fragment{Rule: rule, Val: val, Start: f.total - from, End: end}
+	}
+}
+
+// recordRule 记录具名规则 rule 的候选结果中消费最多的一个
+func recordRule[K TK, R any](st *State[K], rule string, toks []Token[K], xs []Result[K, R]) {
+	best := -1
+	for i, x := range xs {
+		if best < 0 || len(x.next) < len(xs[best].next) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		st.partial.record(rule, len(toks), len(xs[best].next), xs[best].Val)
+	}
+}
+
+// ParsePartial 即 ExpectEOF(p.Parse(toks)), 失败时 Output.Partial 记录消费 token 最多的成功前缀, 失败处的规则栈,
+// 以及解析过程中结束位置最远的具名规则的结果
+// e.g. 文法的根为 Rep(decl), 出现语法错误时 Partial.Val 为错误之前的所有声明; 根为 '[' list ']' 而缺少 ']' 时 Farthest 为 list
+func ParsePartial[K TK, R any](p Parser[K, R], toks []Token[K]) Output[K, R] {
+	st := &State[K]{partial: &farthest{total: len(toks)}}
+	out := ParseIn(st, p, toks)
+	res := ExpectEOF(out)
+	if res.Success {
+		return res
+	}
+	partial := &Partial[R]{Rules: ruleStack(res.Error), Farthest: st.partial.frag}
+	if out.Success {
+		for _, candidate := range out.Candidates {
+			if consumed := len(toks) - len(candidate.next); !partial.Ok || consumed > partial.Consumed {
+				partial.Val, partial.Ok, partial.Consumed = candidate.Val, true, consumed
+			}
+		}
+	}
+	res.Partial = partial
+	return res
+}
+
+// ruleStack 错误处的规则栈, 见 Error.Context
+func ruleStack(e *Error) []string {
+	if e == nil {
+		return nil
+	}
+	return e.more().context.names()
+}
@@ -0,0 +1,39 @@
+package parsec
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParsePartial(t *testing.T) {
+	num := Apply(Tok(bNum), func(t Token[benchKind]) string { return t.Lexeme() })
+	item := NewRule[benchKind, string]()
+	list := NewRule[benchKind, []string]()
+	item.SetPattern("item", KLeft(num, op(",")))
+	list.SetPattern("list", Rep(item.Parser()))
+	bracket := KMid(op("["), list.Parser(), op("]"))
+
+	for _, tt := range []struct {
+		name   string
+		p      Parser[benchKind, []string]
+		input  string
+		expect string
+	}{
+		{"success", list, "1, 2,", "<nil>"},
+		{"prefix", list, "1, 2, 3 4,", "&{Val:[1 2] Ok:true Consumed:4 Rules:[list item] Farthest:{Rule:list Val:[1 2] Start:0 End:4}}"},
+		// 根失败时, 仍然记录已经成功的具名规则, 结束位置相同时外层的 list 优先于 item
+		{"unclosed", bracket, "[1, 2", "&{Val:[] Ok:false Consumed:0 Rules:[list item] Farthest:{Rule:list Val:[1] Start:1 End:3}}"},
+		{"unclosed", bracket, "[1, 2,", "&{Val:[] Ok:false Consumed:0 Rules:[list item] Farthest:{Rule:list Val:[1 2] Start:1 End:5}}"},
+		{"nothing", bracket, "1", "&{Val:[] Ok:false Consumed:0 Rules:[] Farthest:{Rule: Val:<nil> Start:0 End:0}}"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := ParsePartial(tt.p, benchLex(tt.input))
+			if actual := fmt.Sprintf("%+v", out.Partial); actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+			if out.Success == (out.Partial != nil) {
+				t.Errorf("unexpected %v", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Package peg PEG 语义的组合子, 用于逐条移植 PEG 文法
+//
+// 与 parsec 的区别: 每个 PEG 表达式至多返回一个结果, 选择是有序的, 重复是贪婪且不回溯的, 谓词不消费 token;
+// parsec 的解析器(e.g. Str, Tok, Any)可以通过 First 当做 PEG 表达式使用
+//
+//	e1 e2     Seq(e1, e2)
+//	e1 / e2   Choice(e1, e2)
+//	e*        ZeroOrMore(e)
+//	e+        OneOrMore(e)
+//	e?        Optional(e)
+//	&e        And(e)
+//	!e        Not(e)
+//	.         Any()
+//	!.        EOF()
+package peg
+
+import (
+	"fmt"
+
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+// First 只保留 p 的第一个结果, 将任意解析器转换为 PEG 表达式
+func First[K parsec.TK, R any](p parsec.Parser[K, R]) parsec.Parser[K, R] {
+	return parsec.NewStatefulParser(func(st *parsec.State[K], toks []parsec.Token[K]) parsec.Output[K, R] {
+		out := parsec.ParseIn(st, p, toks)
+		if out.Success && len(out.Candidates) > 1 {
+			out.Candidates = out.Candidates[:1]
+		}
+		return out
+	})
+}
+
+// Seq e1 e2 ..., 任意一个失败则失败
+func Seq[K parsec.TK, R any](ps ...parsec.Parser[K, R]) parsec.Parser[K, []R] {
+	return First(parsec.Seq(firsts(ps)...))
+}
+
+// Choice e1 / e2 / ..., 有序选择, 返回第一个成功的分支, 之后不再尝试其他分支
+func Choice[K parsec.TK, R any](ps ...parsec.Parser[K, R]) parsec.Parser[K, R] {
+	return First(parsec.AltSc(firsts(ps)...))
+}
+
+// ZeroOrMore e*, 尽可能多的匹配, 不回溯, 即 a* a 永远失败
+func ZeroOrMore[K parsec.TK, R any](p parsec.Parser[K, R]) parsec.Parser[K, []R] {
+	return First(parsec.RepSc(First(p)))
+}
+
+// OneOrMore e+
+func OneOrMore[K parsec.TK, R any](p parsec.Parser[K, R]) parsec.Parser[K, []R] {
+	return parsec.Apply(parsec.Seq2(First(p), ZeroOrMore(p)), func(v parsec.Cons[R, []R]) []R {
+		return append([]R{v.Car}, v.Cdr...)
+	})
+}
+
+// Optional e?, 失败时返回零值
+func Optional[K parsec.TK, R any](p parsec.Parser[K, R]) parsec.Parser[K, R] {
+	return First(parsec.OptSc(First(p)))
+}
+
+// And &e, e 成功时成功并返回 e 的值, 不消费 token
+func And[K parsec.TK, R any](p parsec.Parser[K, R]) parsec.Parser[K, R] {
+	return parsec.NewStatefulParser(func(st *parsec.State[K], toks []parsec.Token[K]) parsec.Output[K, R] {
+		out := parsec.ParseIn(st, p, toks)
+		if !out.Success {
+			return out
+		}
+		res := parsec.ParseIn(st, parsec.Succ[K](out.Candidates[0].Val), toks)
+		res.Error = out.Error
+		return res
+	})
+}
+
+// Not !e, e 失败时成功, 不消费 token
+func Not[K parsec.TK, R any](p parsec.Parser[K, R]) parsec.Parser[K, struct{}] {
+	return parsec.NewStatefulParser(func(st *parsec.State[K], toks []parsec.Token[K]) parsec.Output[K, struct{}] {
+		out := parsec.ParseIn(st, p, toks)
+		if out.Success {
+			msg := fmt.Sprintf("unexpect `%v`", out.Candidates[0].Val)
+			return parsec.ParseIn(st, parsec.Fail[K, struct{}](msg), toks)
+		}
+		return parsec.ParseIn(st, parsec.Succ[K](struct{}{}), toks)
+	})
+}
+
+// Any . 任意一个 token
+func Any[K parsec.TK]() parsec.Parser[K, parsec.Token[K]] {
+	return parsec.Any[K]()
+}
+
+// EOF !., 输入结束时成功
+func EOF[K parsec.TK]() parsec.Parser[K, struct{}] {
+	return parsec.EOF[K]()
+}
+
+func firsts[K parsec.TK, R any](ps []parsec.Parser[K, R]) []parsec.Parser[K, R] {
+	xs := make([]parsec.Parser[K, R], len(ps))
+	for i, p := range ps {
+		xs[i] = First(p)
+	}
+	return xs
+}
@@ -0,0 +1,170 @@
+package peg
+
+import (
+	"testing"
+
+	"github.com/goghcrow/go-parsec/lexer"
+	"github.com/goghcrow/go-parsec/parsec"
+)
+
+type kind int
+
+const (
+	kOpen kind = iota + 1
+	kClose
+	kChar
+)
+
+func (k kind) String() string {
+	return map[kind]string{kOpen: "/*", kClose: "*/", kChar: "<char>"}[k]
+}
+
+var charLexer = lexer.BuildLexer(func(lex *lexer.Lexicon[kind]) {
+	lex.Str(kOpen, "/*")
+	lex.Str(kClose, "*/")
+	lex.Regex(kChar, `[\s\S]`)
+})
+
+func lex(s string) []parsec.Token[kind] {
+	toks := charLexer.MustLex(s)
+	xs := make([]parsec.Token[kind], len(toks))
+	for i, t := range toks {
+		xs[i] = t
+	}
+	return xs
+}
+
+func lit(s string) parsec.Parser[kind, parsec.Token[kind]] { return parsec.Str[kind](s) }
+
+func parse[R any](p parsec.Parser[kind, R], s string) (R, bool) {
+	out := p.Parse(lex(s))
+	if !out.Success {
+		return *new(R), false
+	}
+	return out.Candidates[0].Val, true
+}
+
+// S <- &(A 'c') 'a'+ B !.
+// A <- 'a' A? 'b'
+// B <- 'b' B? 'c'
+func TestAnBnCn(t *testing.T) {
+	count := func(v parsec.Cons[parsec.Token[kind], parsec.Cons[int, parsec.Token[kind]]]) int {
+		return v.Cdr.Car + 1
+	}
+	A := parsec.NewRule[kind, int]()
+	B := parsec.NewRule[kind, int]()
+	A.Pattern = parsec.Apply(parsec.Seq3(lit("a"), Optional[kind, int](A), lit("b")), count)
+	B.Pattern = parsec.Apply(parsec.Seq3(lit("b"), Optional[kind, int](B), lit("c")), count)
+	S := parsec.KLeft(
+		parsec.KRight(And(parsec.Seq2(A.Parser(), lit("c"))), parsec.KRight(OneOrMore(lit("a")), B.Parser())),
+		EOF[kind](),
+	)
+
+	for _, tt := range []struct {
+		input string
+		n     int
+		ok    bool
+	}{
+		{"abc", 1, true},
+		{"aabbcc", 2, true},
+		{"aaabbbccc", 3, true},
+		{"", 0, false},
+		{"aabbc", 0, false},
+		{"aabcc", 0, false},
+		{"abbcc", 0, false},
+		{"aabbccc", 0, false},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			n, ok := parse(S, tt.input)
+			if ok != tt.ok || n != tt.n {
+				t.Errorf("expect %d %t actual %d %t", tt.n, tt.ok, n, ok)
+			}
+		})
+	}
+}
+
+// Comment <- '/*' (Comment / !'*/' .)* '*/'
+func TestNestedComment(t *testing.T) {
+	comment := parsec.NewRule[kind, int]()
+	other := parsec.Apply(parsec.KRight(Not(lit("*/")), Any[kind]()), func(parsec.Token[kind]) int { return 0 })
+	comment.Pattern = parsec.Apply(
+		parsec.KMid(lit("/*"), ZeroOrMore(Choice[kind, int](comment, other)), lit("*/")),
+		func(xs []int) int {
+			depth := 0
+			for _, x := range xs {
+				if x > depth {
+					depth = x
+				}
+			}
+			return depth + 1
+		},
+	)
+	p := parsec.KLeft[kind, int, struct{}](comment, EOF[kind]())
+
+	for _, tt := range []struct {
+		input string
+		depth int
+		ok    bool
+	}{
+		{"/**/", 1, true},
+		{"/* a */", 1, true},
+		{"/* a /* b */ c */", 2, true},
+		{"/* /* /* */ */ /* */ */", 3, true},
+		{"/* a /* b */", 0, false},
+		{"/* a */ */", 0, false},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			depth, ok := parse(p, tt.input)
+			if ok != tt.ok || depth != tt.depth {
+				t.Errorf("expect %d %t actual %d %t", tt.depth, tt.ok, depth, ok)
+			}
+		})
+	}
+}
+
+func TestSemantics(t *testing.T) {
+	a := lit("a")
+
+	// 贪婪不回溯: a* a 永远失败, parsec 的 Rep 会回溯
+	if xs, ok := parse(ZeroOrMore(a), "aa"); !ok || len(xs) != 2 {
+		t.Errorf("expect a* consume aa actual %v", xs)
+	}
+	if xs, ok := parse(Seq(a, a), "aa"); !ok || len(xs) != 2 {
+		t.Errorf("expect a a success actual %v", xs)
+	}
+	if _, ok := parse(parsec.Seq2(ZeroOrMore(a), a), "aa"); ok {
+		t.Errorf("expect a* a fail")
+	}
+	if _, ok := parse(parsec.Seq2(parsec.Rep(a), a), "aa"); !ok {
+		t.Errorf("expect parsec Rep backtrack")
+	}
+
+	// 有序选择: 第一个分支成功后不尝试第二个
+	ab := parsec.Apply(parsec.Seq(a, lit("b")), func([]parsec.Token[kind]) string { return "ab" })
+	one := parsec.Apply(a, func(parsec.Token[kind]) string { return "a" })
+	if _, ok := parse(parsec.Seq2(Choice(one, ab), EOF[kind]()), "ab"); ok {
+		t.Errorf("expect (a / ab) !. fail on ab")
+	}
+	if out := Choice(one, ab).Parse(lex("ab")); len(out.Candidates) != 1 {
+		t.Errorf("expect single result actual %v", out)
+	}
+
+	// 谓词不消费 token
+	toks := lex("ab")
+	out := parsec.Seq2(And(a), a).Parse(toks)
+	if !out.Success || out.Candidates[0].Val.Car.Lexeme() != "a" {
+		t.Errorf("expect &a a success actual %v", out)
+	}
+	if out := parsec.Seq2(Not(lit("b")), a).Parse(toks); !out.Success {
+		t.Errorf("expect !b a success actual %v", out)
+	}
+	if out := Not(a).Parse(toks); out.Success || out.Msg != "unexpect `a`" {
+		t.Errorf("expect !a fail actual %v", out)
+	}
+	if out := EOF[kind]().Parse(toks); out.Success {
+		t.Errorf("expect !. fail")
+	}
+	if out := EOF[kind]().Parse(nil); !out.Success {
+		t.Errorf("expect !. success at end of input")
+	}
+}
@@ -1,6 +1,9 @@
 package parsec
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // ----------------------------------------------------------------
 // Token Filter
@@ -9,7 +12,7 @@ import "fmt"
 // Nil
 // 不消耗 token, 返回 nil
 func Nil[K TK, R any]() Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
 		return success([]Result[K, R]{{next: toks}})
 	})
 }
@@ -17,7 +20,7 @@ func Nil[K TK, R any]() Parser[K, R] {
 // Succ
 // 即 Unit, Return, 不消耗 token, 返回固定值
 func Succ[K TK, R any](v R) Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
 		return success([]Result[K, R]{{Val: v, next: toks}})
 	})
 }
@@ -25,7 +28,7 @@ func Succ[K TK, R any](v R) Parser[K, R] {
 // Fail
 // 不消耗 token, 永远失败
 func Fail[K TK, R any](msg string) Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
+	return parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
 		var pos Pos = EOFPos
 		if len(toks) != 0 {
 			pos = toks[0]
@@ -37,7 +40,7 @@ func Fail[K TK, R any](msg string) Parser[K, R] {
 // Any
 // 消耗任意一个 token
 func Any[K TK]() Parser[K, Token[K]] {
-	return parser[K, Token[K]](func(toks []Token[K]) Output[K, Token[K]] {
+	return parser[K, Token[K]](func(st *State[K], toks []Token[K]) Output[K, Token[K]] {
 		if len(toks) == 0 {
 			return fail[K, Token[K]](unableToConsumeToken(EOFToken[K](), "any token"))
 		}
@@ -48,12 +51,15 @@ func Any[K TK]() Parser[K, Token[K]] {
 // Str
 // 按 文本匹配 token
 func Str[K TK](toMatch string) Parser[K, Token[K]] {
-	return parser[K, Token[K]](func(toks []Token[K]) Output[K, Token[K]] {
+	expected := []expectation{{text: toMatch, isLit: true}}
+	// Error 创建后不再修改, 输入结束的错误可以共享
+	eof := unableToConsumeToken(EOFToken[K](), toMatch).expect(expected)
+	return parser[K, Token[K]](func(st *State[K], toks []Token[K]) Output[K, Token[K]] {
 		if len(toks) == 0 {
-			return fail[K, Token[K]](unableToConsumeToken(EOFToken[K](), toMatch))
+			return fail[K, Token[K]](eof)
 		}
 		if toks[0].Lexeme() != toMatch {
-			return fail[K, Token[K]](unableToConsumeToken(toks[0], toMatch))
+			return fail[K, Token[K]](unableToConsumeToken(toks[0], toMatch).expect(expected))
 		}
 		return success([]Result[K, Token[K]]{{Val: toks[0], next: toks[1:]}})
 	})
@@ -62,13 +68,85 @@ func Str[K TK](toMatch string) Parser[K, Token[K]] {
 // Tok
 // 按 TokenKind 匹配 token
 func Tok[K TK](toMatch K) Parser[K, Token[K]] {
-	return parser[K, Token[K]](func(toks []Token[K]) Output[K, Token[K]] {
+	expect := fmt.Sprintf("%v", toMatch)
+	expected := []expectation{{kind: toMatch}}
+	eof := unableToConsumeToken(EOFToken[K](), expect).expect(expected)
+	return parser[K, Token[K]](func(st *State[K], toks []Token[K]) Output[K, Token[K]] {
 		if len(toks) == 0 {
-			return fail[K, Token[K]](unableToConsumeToken(EOFToken[K](), fmt.Sprintf("%v", toMatch)))
+			return fail[K, Token[K]](eof)
 		}
 		if toks[0].Kind() != toMatch {
-			return fail[K, Token[K]](unableToConsumeToken(toks[0], fmt.Sprintf("%v", toMatch)))
+			return fail[K, Token[K]](unableToConsumeToken(toks[0], expect).expect(expected))
 		}
 		return success([]Result[K, Token[K]]{{Val: toks[0], next: toks[1:]}})
 	})
 }
+
+// Satisfy
+// 消耗满足 pred 的 token, 失败时期望 label, 同 Tok 记录在错误中, 见 Expected
+func Satisfy[K TK](pred func(Token[K]) bool, label string) Parser[K, Token[K]] {
+	expected := []expectation{{text: label, isLabel: true}}
+	eof := unableToConsumeToken(EOFToken[K](), label).expect(expected)
+	return parser[K, Token[K]](func(st *State[K], toks []Token[K]) Output[K, Token[K]] {
+		if len(toks) == 0 {
+			return fail[K, Token[K]](eof)
+		}
+		if !pred(toks[0]) {
+			return fail[K, Token[K]](unableToConsumeToken(toks[0], label).expect(expected))
+		}
+		return success([]Result[K, Token[K]]{{Val: toks[0], next: toks[1:]}})
+	})
+}
+
+// OneOf
+// 消耗 TokenKind 为 kinds 之一的 token, 失败时期望所有 kinds, 同 Alt(Tok(k1), Tok(k2), ...) 但只有一个错误
+func OneOf[K TK](kinds ...K) Parser[K, Token[K]] {
+	xs := make([]string, len(kinds))
+	expected := make([]expectation, len(kinds))
+	for i, k := range kinds {
+		xs[i] = fmt.Sprintf("%v", k)
+		expected[i] = expectation{kind: k}
+	}
+	// unableToConsumeToken 会用 ` 包裹 expect, e.g. expect `<num>` or `<id>`
+	expect := strings.Join(xs, "` or `")
+	eof := unableToConsumeToken(EOFToken[K](), expect).expect(expected)
+	return parser[K, Token[K]](func(st *State[K], toks []Token[K]) Output[K, Token[K]] {
+		if len(toks) == 0 {
+			return fail[K, Token[K]](eof)
+		}
+		for _, k := range kinds {
+			if toks[0].Kind() == k {
+				return success([]Result[K, Token[K]]{{Val: toks[0], next: toks[1:]}})
+			}
+		}
+		return fail[K, Token[K]](unableToConsumeToken(toks[0], expect).expect(expected))
+	})
+}
+
+// NoneOf
+// 消耗 TokenKind 不为 kinds 之一的任意 token, 失败时期望 any token except kinds
+func NoneOf[K TK](kinds ...K) Parser[K, Token[K]] {
+	xs := make([]string, len(kinds))
+	for i, k := range kinds {
+		xs[i] = fmt.Sprintf("%v", k)
+	}
+	return Satisfy(func(t Token[K]) bool {
+		for _, k := range kinds {
+			if t.Kind() == k {
+				return false
+			}
+		}
+		return true
+	}, "any token except "+strings.Join(xs, ", "))
+}
+
+// EOF
+// 不消耗 token, 输入结束时成功, 可以在文法中使用, ExpectEOF 用于检查解析结果
+func EOF[K TK]() Parser[K, struct{}] {
+	return parser[K, struct{}](func(st *State[K], toks []Token[K]) Output[K, struct{}] {
+		if len(toks) != 0 {
+			return fail[K, struct{}](unableToConsumeToken(toks[0], "end of input"))
+		}
+		return success([]Result[K, struct{}]{{next: toks}})
+	})
+}
@@ -0,0 +1,194 @@
+package parsec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// Grammar, 规则容器
+// ----------------------------------------------------------------
+
+// Grammar 创建并命名规则, 规则的 Pattern 可以按任意顺序定义, Build 之后冻结;
+// 文法写成 Module 时可以被其他文法导入, 导入方通过 Extend, Override, Hide 修改导入的规则
+// e.g.
+//
+//	g := NewGrammar[K]()
+//	expr := Define[K, int](g, "expr")
+//	term := Define[K, int](g, "term")
+//	expr.SetPattern("expr", Chainl1(term.Parser(), add))
+//	term.SetPattern("term", Alt(num, KMid(lpar, expr.Parser(), rpar)))
+//	err := g.Build()
+type Grammar[K TK] struct {
+	rules  []rule
+	byName map[string]rule
+	hidden map[string]bool
+	errs   []string // Build 时报告的定义错误
+	built  bool
+}
+
+// Module 可复用的文法定义, 在 g 中定义规则;
+// 规则之间通过 g 中的 SyntaxRule 引用, 所以导入方修改规则后, 所有引用处看到的都是修改后的规则
+type Module[K TK] func(g *Grammar[K])
+
+// rule 不同返回类型的 SyntaxRule
+type rule interface {
+	Name() string
+	ID() int
+	initialized() bool
+	freeze(id int)
+}
+
+func NewGrammar[K TK]() *Grammar[K] {
+	return &Grammar[K]{byName: map[string]rule{}, hidden: map[string]bool{}}
+}
+
+// Import 在 g 中依次执行 ms, 规则直接定义在 g 中, 没有命名空间, 与已有的规则同名时 Build 报告重复定义;
+// Module 每次导入都会重新执行, 所以导入同一个 Module 的不同 Grammar 各自得到一份规则, 在 g 中的修改不影响其他 Grammar
+func (g *Grammar[K]) Import(ms ...Module[K]) *Grammar[K] {
+	for _, m := range ms {
+		m(g)
+	}
+	return g
+}
+
+// Define 在 g 中创建名为 name 的规则, Pattern 在 Build 之前通过 SetPattern 设置
+// Go 的方法不能有类型参数, 所以 Define 是函数
+func Define[K TK, R any](g *Grammar[K], name string) *SyntaxRule[K, R] {
+	if g.built {
+		panic(fmt.Sprintf("Grammar has been built, cannot define rule %s.", name))
+	}
+	r := &SyntaxRule[K, R]{name: name, owned: true}
+	if _, ok := g.byName[name]; ok {
+		g.errs = append(g.errs, fmt.Sprintf("duplicate rule %s", name))
+	} else {
+		g.byName[name] = r
+	}
+	g.rules = append(g.rules, r)
+	return r
+}
+
+// Build 检查所有规则都已设置 Pattern 且名称不重复, 按 Define 的顺序分配 id 并冻结规则
+// 冻结之后 SetPattern 会 panic, 直接修改 Pattern 字段会在解析时 panic
+func (g *Grammar[K]) Build() error {
+	if g.built {
+		return nil
+	}
+	errs := g.errs
+	for _, r := range g.rules {
+		if !r.initialized() {
+			errs = append(errs, fmt.Sprintf("rule %s has not been initialized", r.Name()))
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New("Grammar build failed: " + strings.Join(errs, ", ") + ".")
+	}
+	for i, r := range g.rules {
+		r.freeze(i + 1)
+	}
+	g.built = true
+	return nil
+}
+
+// MustBuild 同 Build, 失败时 panic, 用于文法定义在代码中的场景
+func (g *Grammar[K]) MustBuild() *Grammar[K] {
+	if err := g.Build(); err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// Names 按 Define 顺序的规则名, 不包括隐藏的规则
+func (g *Grammar[K]) Names() []string {
+	var xs []string
+	for _, r := range g.rules {
+		if !g.hidden[r.Name()] {
+			xs = append(xs, r.Name())
+		}
+	}
+	return xs
+}
+
+// Lookup 按名称查找规则, 不存在, 已隐藏或返回类型不是 R 时 ok 为 false
+func Lookup[K TK, R any](g *Grammar[K], name string) (r *SyntaxRule[K, R], ok bool) {
+	if g.hidden[name] {
+		return nil, false
+	}
+	r, ok = g.byName[name].(*SyntaxRule[K, R])
+	return
+}
+
+// Ref 引用 g 中名为 name 的规则, 尚未定义时先定义, 用于引用导入的规则或者前向声明;
+// 与隐藏无关, 返回类型不是 R 时 Build 失败
+func Ref[K TK, R any](g *Grammar[K], name string) *SyntaxRule[K, R] {
+	x, ok := g.byName[name]
+	if !ok {
+		return Define[K, R](g, name)
+	}
+	r, ok := x.(*SyntaxRule[K, R])
+	if !ok {
+		g.errs = append(g.errs, fmt.Sprintf("rule %s is defined with a different type", name))
+		return &SyntaxRule[K, R]{name: name}
+	}
+	return r
+}
+
+// Extend 为导入的规则添加候选, 即 Alt(原 Pattern, alts...)
+// 在 Import 之后调用, 所有引用该规则的地方都会看到添加的候选
+func Extend[K TK, R any](g *Grammar[K], name string, alts ...Parser[K, R]) {
+	r := modify[K, R](g, name, "extend")
+	if r == nil {
+		return
+	}
+	if r.Pattern == nil {
+		g.errs = append(g.errs, fmt.Sprintf("cannot extend uninitialized rule %s", name))
+		return
+	}
+	r.Pattern = Alt(append([]Parser[K, R]{r.Pattern}, alts...)...)
+}
+
+// Override 替换导入的规则的 Pattern, 所有引用该规则的地方都会使用新的 Pattern
+func Override[K TK, R any](g *Grammar[K], name string, p Parser[K, R]) {
+	if r := modify[K, R](g, name, "override"); r != nil {
+		r.Pattern = p
+	}
+}
+
+// Hide 隐藏规则, 隐藏的规则仍然被其他规则引用, 但是 Lookup 与 Names 找不到, 用于不对外暴露导入的内部规则
+func (g *Grammar[K]) Hide(names ...string) {
+	for _, name := range names {
+		if _, ok := g.byName[name]; !ok {
+			g.errs = append(g.errs, fmt.Sprintf("cannot hide undefined rule %s", name))
+		}
+		g.hidden[name] = true
+	}
+}
+
+// modify 查找要修改的规则, 未定义或类型不匹配时记录错误并返回 nil
+func modify[K TK, R any](g *Grammar[K], name, op string) *SyntaxRule[K, R] {
+	if g.built {
+		panic(fmt.Sprintf("Grammar has been built, cannot %s rule %s.", op, name))
+	}
+	x, ok := g.byName[name]
+	if !ok {
+		g.errs = append(g.errs, fmt.Sprintf("cannot %s undefined rule %s", op, name))
+		return nil
+	}
+	r, ok := x.(*SyntaxRule[K, R])
+	if !ok {
+		g.errs = append(g.errs, fmt.Sprintf("rule %s is defined with a different type", name))
+		return nil
+	}
+	return r
+}
+
+func (r *SyntaxRule[K, R]) initialized() bool {
+	return r.Pattern != nil
+}
+
+func (r *SyntaxRule[K, R]) freeze(id int) {
+	r.id = id
+	r.frozen = &frozenPattern[K, R]{Trace(r.name, r.Pattern)}
+	r.Pattern = r.frozen
+}
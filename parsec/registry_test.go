@@ -0,0 +1,117 @@
+package parsec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGrammar(t *testing.T) {
+	num := Apply(Tok(bNum), func(t Token[benchKind]) int {
+		n, _ := strconv.Atoi(t.Lexeme())
+		return n
+	})
+	add := func(l int, r Cons[Token[benchKind], int]) int { return l + r.Cdr }
+
+	g := NewGrammar[benchKind]()
+	expr := Define[benchKind, int](g, "expr")
+	term := Define[benchKind, int](g, "term")
+	// 先定义 expr 再定义其引用的 term
+	expr.Pattern = LRecSc(term.Parser(), Seq2(op("+"), term.Parser()), add)
+	term.SetPattern("term", AltSc(num, KMid(op("("), expr.Parser(), op(")"))))
+
+	if err := g.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if expr.ID() != 1 || term.ID() != 2 {
+		t.Errorf("expect ids 1 2 actual %d %d", expr.ID(), term.ID())
+	}
+	if actual := fmt.Sprint(g.Names()); actual != "[expr term]" {
+		t.Errorf("expect [expr term] actual %s", actual)
+	}
+
+	r, ok := Lookup[benchKind, int](g, "expr")
+	if !ok || r != expr {
+		t.Fatalf("expect expr found")
+	}
+	if _, ok := Lookup[benchKind, string](g, "expr"); ok {
+		t.Errorf("expect type mismatch")
+	}
+	if _, ok := Lookup[benchKind, int](g, "factor"); ok {
+		t.Errorf("expect factor not found")
+	}
+
+	v, err := ExpectSingleResult(ExpectEOF(r.Parse(benchLex("1 + (2 + 3)"))))
+	if err != nil || v != 6 {
+		t.Errorf("expect 6 actual %d %v", v, err)
+	}
+
+	// 冻结之后不能修改 Pattern, Define 创建的规则不能改名
+	for _, tt := range []struct {
+		name   string
+		f      func()
+		expect string
+	}{
+		{"SetPattern", func() { term.SetPattern("term", num) }, "Rule term has been frozen by Grammar.Build."},
+		{"Pattern", func() { expr.Pattern = num; expr.Parse(benchLex("1 + 2")) }, "Pattern of rule expr has been modified after Grammar.Build."},
+		{"rename", func() { Define[benchKind, int](NewGrammar[benchKind](), "a").SetPattern("b", num) }, "Rule a is defined by Grammar, cannot rename to b."},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != tt.expect {
+					t.Errorf("expect panic %s actual %v", tt.expect, r)
+				}
+			}()
+			tt.f()
+		})
+	}
+}
+
+func TestGrammarBuildError(t *testing.T) {
+	g := NewGrammar[benchKind]()
+	a := Define[benchKind, int](g, "a")
+	Define[benchKind, int](g, "b")
+	Define[benchKind, string](g, "a")
+	a.Pattern = Apply(Tok(bNum), func(Token[benchKind]) int { return 0 })
+
+	expect := "Grammar build failed: duplicate rule a, rule b has not been initialized, rule a has not been initialized."
+	if err := g.Build(); err == nil || err.Error() != expect {
+		t.Errorf("expect %s actual %v", expect, err)
+	}
+	if a.ID() != 0 {
+		t.Errorf("expect not frozen")
+	}
+}
+
+func TestGrammarImport(t *testing.T) {
+	lexeme := func(t Token[benchKind]) string { return t.Lexeme() }
+	base := func(g *Grammar[benchKind]) {
+		atom := Define[benchKind, string](g, "atom")
+		list := Define[benchKind, string](g, "list")
+		atom.Pattern = Apply(Tok(bNum), lexeme)
+		list.Pattern = Apply(Rep(atom.Parser()), func(xs []string) string { return fmt.Sprint(xs) })
+	}
+
+	// 每个 Grammar 导入时得到独立的规则, list 引用的 atom 为扩展后的规则
+	g1 := NewGrammar[benchKind]().Import(base)
+	Extend(g1, "atom", Apply(Tok(bStr), lexeme))
+	g2 := NewGrammar[benchKind]().Import(base)
+	g1.MustBuild()
+	g2.MustBuild()
+
+	l1, _ := Lookup[benchKind, string](g1, "list")
+	l2, _ := Lookup[benchKind, string](g2, "list")
+	toks := benchLex(`1 "a" 2`)
+	if v, _ := ExpectSingleResult(ExpectEOF(Greedy(l1.Parser()).Parse(toks))); v != `[1 "a" 2]` {
+		t.Errorf("actual %s", v)
+	}
+	if out := ExpectEOF(l2.Parse(toks)); out.Success {
+		t.Errorf("expect base grammar unchanged")
+	}
+
+	// 同一个 Grammar 中没有命名空间, 重复导入即重复定义
+	if err := NewGrammar[benchKind]().Import(base, base).Build(); err == nil || !strings.Contains(err.Error(), "duplicate rule atom") {
+		t.Errorf("expect duplicate rule actual %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package parsec
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// Source & Error Rendering
+// ----------------------------------------------------------------
+
+// Source 保留原始输入, 按位置截取源码并渲染错误
+type Source struct {
+	Name  string
+	runes []rune
+	lines []int // 每行起始下标
+}
+
+func NewSource(name, input string) *Source {
+	s := &Source{Name: name, runes: []rune(input), lines: []int{0}}
+	for i, r := range s.runes {
+		if r == '\n' {
+			s.lines = append(s.lines, i+1)
+		}
+	}
+	return s
+}
+
+// Span 返回 pos 覆盖的源码, 虚拟位置返回空串
+func (s *Source) Span(pos Pos) string {
+	idx, end, ok := s.rangeOf(pos)
+	if !ok {
+		return ""
+	}
+	return string(s.runes[idx:end])
+}
+
+// Line 返回第 ln 行(从 0 开始)源码, 不含换行
+func (s *Source) Line(ln int) string {
+	if ln < 0 || ln >= len(s.lines) {
+		return ""
+	}
+	end := len(s.runes)
+	if ln+1 < len(s.lines) {
+		end = s.lines[ln+1] - 1
+	}
+	return strings.TrimSuffix(string(s.runes[s.lines[ln]:end]), "\r")
+}
+
+// Note 附加说明, Pos 为 nil 或虚拟位置时只输出文本
+type Note struct {
+	Pos Pos
+	Msg string
+}
+
+// Render 渲染 rustc 风格的错误片段
+//
+//	error: Unable to consume token `;` expect `)`
+//	 --> calc.txt:2:17
+//	  |
+//	2 | TERM = ( NUMBER ;
+//	  |                 ^
+//	  = note: ...
+//
+// color 为 true 时输出 ANSI 颜色; EOFPos 指向输入末尾, 其他虚拟位置只输出信息
+func (s *Source) Render(err error, color bool, notes ...Note) string {
+	var pos Pos = UnknownPos
+	msg := err.Error()
+	var e *Error
+	if errors.As(err, &e) {
+		pos, msg = e.Pos, e.Msg
+		notes = concat(e.more().notes, notes...)
+	} else {
+		var l interface{ Loc() (int, int, int, int) }
+		if errors.As(err, &l) {
+			pos = l
+		}
+	}
+
+	r := renderer{Source: s, color: color}
+	r.header(msg)
+
+	var located, plain []Note
+	if pos == EOFPos {
+		located = append(located, Note{Pos: pos, Msg: string(EOFPos)})
+	} else if _, _, ok := s.rangeOf(pos); ok {
+		located = append(located, Note{Pos: pos})
+	} else if vp, ok := pos.(VirtualPos); ok && vp != UnknownPos {
+		plain = append(plain, Note{Msg: string(vp)})
+	}
+	for _, n := range notes {
+		if _, _, ok := s.rangeOf(n.Pos); ok {
+			located = append(located, n)
+		} else {
+			plain = append(plain, n)
+		}
+	}
+	r.snippet(located)
+	for _, n := range plain {
+		r.note(n.Msg)
+	}
+	return r.String()
+}
+
+// rangeOf 将位置转换为 [idx, end) 下标, EOFPos 为输入末尾
+func (s *Source) rangeOf(pos Pos) (idx, end int, ok bool) {
+	if pos == nil {
+		return 0, 0, false
+	}
+	if pos == EOFPos {
+		return len(s.runes), len(s.runes), true
+	}
+	if _, virtual := pos.(VirtualPos); virtual {
+		return 0, 0, false
+	}
+	idx, end, _, _ = pos.Loc()
+	if idx < 0 || idx > len(s.runes) {
+		return 0, 0, false
+	}
+	if end < idx {
+		end = idx
+	}
+	if end > len(s.runes) {
+		end = len(s.runes)
+	}
+	return idx, end, true
+}
+
+// lineOf 下标所在行(从 0 开始)
+func (s *Source) lineOf(idx int) int {
+	return sort.Search(len(s.lines), func(i int) bool { return s.lines[i] > idx }) - 1
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiError = "\x1b[1;31m"
+	ansiNote  = "\x1b[1;36m"
+	ansiGut   = "\x1b[1;34m"
+	ansiBold  = "\x1b[1m"
+)
+
+type renderer struct {
+	*Source
+	strings.Builder
+	color bool
+	width int // 行号宽度
+}
+
+func (r *renderer) paint(style, s string) string {
+	if !r.color || s == "" {
+		return s
+	}
+	return style + s + ansiReset
+}
+
+func (r *renderer) header(msg string) {
+	r.WriteString(r.paint(ansiError, "error") + r.paint(ansiBold, ": "+msg) + "\n")
+}
+
+func (r *renderer) note(msg string) {
+	r.WriteString(fmt.Sprintf("%s %s %s\n",
+		strings.Repeat(" ", r.width), r.paint(ansiGut, "="), r.paint(ansiNote, "note")+": "+msg))
+}
+
+// snippet 第一个位置为主位置(^), 其余为附加说明(-)
+func (r *renderer) snippet(xs []Note) {
+	if len(xs) == 0 {
+		return
+	}
+	maxLn := 0
+	for _, n := range xs {
+		idx, _, _ := r.rangeOf(n.Pos)
+		if ln := r.lineOf(idx); ln > maxLn {
+			maxLn = ln
+		}
+	}
+	r.width = len(strconv.Itoa(maxLn + 1))
+
+	idx, _, _ := r.rangeOf(xs[0].Pos)
+	ln := r.lineOf(idx)
+	name := r.Name
+	if name == "" {
+		name = "<input>"
+	}
+	pad := strings.Repeat(" ", r.width)
+	r.WriteString(fmt.Sprintf("%s%s %s:%d:%d\n", pad, r.paint(ansiGut, "-->"), name, ln+1, idx-r.lines[ln]+1))
+	r.WriteString(pad + " " + r.paint(ansiGut, "|") + "\n")
+
+	for i, n := range xs {
+		idx, end, _ := r.rangeOf(n.Pos)
+		ln := r.lineOf(idx)
+		line := []rune(r.Line(ln))
+		col := idx - r.lines[ln]
+		// 跨行只标记到行尾
+		width := end - idx
+		if col+width > len(line) {
+			width = len(line) - col
+		}
+		if width < 1 {
+			width = 1
+		}
+		mark, style := "-", ansiNote
+		if i == 0 {
+			mark, style = "^", ansiError
+		}
+		// 保留 tab, 对齐
+		indent := make([]rune, 0, col)
+		for j := 0; j < col; j++ {
+			if j < len(line) && line[j] == '\t' {
+				indent = append(indent, '\t')
+			} else {
+				indent = append(indent, ' ')
+			}
+		}
+		label := strings.Repeat(mark, width)
+		if n.Msg != "" {
+			label += " " + n.Msg
+		}
+		lnStr := strconv.Itoa(ln + 1)
+		r.WriteString(r.paint(ansiGut, strings.Repeat(" ", r.width-len(lnStr))+lnStr+" |") + " " + string(line) + "\n")
+		r.WriteString(pad + " " + r.paint(ansiGut, "|") + " " + string(indent) + r.paint(style, label) + "\n")
+	}
+}
@@ -0,0 +1,78 @@
+package parsec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	input := "1, 2\nabc,\t456 x"
+	src := NewSource("test.txt", input)
+	toks := mustLex(input)
+
+	for _, tt := range []struct {
+		name   string
+		err    error
+		notes  []Note
+		expect string
+	}{
+		{
+			name: "token",
+			err:  Seq(Tok(Number), Tok(Number), Tok(Number)).Parse(toks).Error,
+			expect: `error: Unable to consume token ` + "`abc`" + ` expect ` + "`<num>`" + `
+ --> test.txt:2:1
+  |
+2 | abc,	456 x
+  | ^^^
+`,
+		},
+		{
+			name: "tab",
+			err:  newError(toks[4], "unexpected"),
+			notes: []Note{
+				{toks[0], "first"},
+				{nil, "plain note"},
+			},
+			expect: `error: unexpected
+ --> test.txt:2:10
+  |
+2 | abc,	456 x
+  |     	    ^
+1 | 1, 2
+  | - first
+  = note: plain note
+`,
+		},
+		{
+			name: "eof",
+			err:  Tok(Number).Parse(nil).Error,
+			expect: `error: Nothing to consume expect ` + "`<num>`" + `
+ --> test.txt:2:11
+  |
+2 | abc,	456 x
+  |     	     ^ end of input
+`,
+		},
+		{
+			name:   "unknown",
+			err:    errors.New("oops"),
+			expect: "error: oops\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := src.Render(tt.err, false, tt.notes...)
+			if actual != tt.expect {
+				t.Errorf("\nexpect:\n%s\nactual:\n%s", tt.expect, actual)
+			}
+		})
+	}
+
+	if s := src.Span(toks[3]); s != "456" {
+		t.Errorf("expect 456 actual %s", s)
+	}
+	colored := src.Render(newError(toks[0], "bad"), true)
+	if !strings.Contains(colored, ansiError+"^"+ansiReset) {
+		t.Errorf("expect ansi color, actual %q", colored)
+	}
+}
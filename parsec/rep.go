@@ -5,109 +5,285 @@ package parsec
 // ----------------------------------------------------------------
 
 // Rep :: p[a] -> p[list[a]]
-// 重复 n 次(n>=0), 按路径从长到短返回结果
+// 重复 n 次(n>=0), 按路径从长到短返回结果; GreedyMode 下即 RepSc, 见 Mode
 func Rep[K TK, R any](p Parser[K, R]) Parser[K, []R] {
-	repR := RepR[K, R](p)
-	return parser[K, []R](func(toks []Token[K]) Output[K, []R] {
-		out := repR.Parse(toks)
+	return moded(repAll(p), RepSc(p))
+}
+
+func repAll[K TK, R any](p Parser[K, R]) Parser[K, []R] {
+	repR := repRAll[K, R](p)
+	return withEnum(parser[K, []R](func(st *State[K], toks []Token[K]) Output[K, []R] {
+		out := ParseIn(st, repR, toks)
 		if out.Success {
 			return successWithErr(reverse(out.Candidates), out.Error)
 		}
 		return out
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, []R]) bool) *Error {
+		var err *Error
+		// 深度优先, 先尝试继续重复, 再 yield 当前路径, 即从长到短
+		var walk func(l *plist[R], ns *nodes, toks []Token[K]) bool
+		walk = func(l *plist[R], ns *nodes, toks []Token[K]) bool {
+			cont := true
+			err = betterError(err, EnumIn(st, p, toks, func(r Result[K, R]) bool {
+				// 必须消费掉 token, 重复 nil 死循环
+				if toksEqual(toks, r.next) {
+					return true
+				}
+				cont = walk(l.push(r.Val), ns.concat(r.nodes), r.next)
+				return cont
+			}))
+			return cont && yield(Result[K, []R]{Val: l.slice(), next: toks, nodes: ns})
+		}
+		walk(nil, nil, toks)
+		return err
 	})
 }
 
 // RepSc :: p[a] -> p[list[a]]
 // 消费尽可能多的 p, 如果零次, 则返回 p[empty_list], 不会失败
-// Rep|RepR 返回所有层的结果, RepSc 返回最深一层结果
+// Rep|RepR 返回所有层的结果, RepSc 返回最深一层结果; 不受 Mode 影响
 func RepSc[K TK, R any](p Parser[K, R]) Parser[K, []R] {
-	return parser[K, []R](func(toks []Token[K]) Output[K, []R] {
+	pool := &slicePool[acc[K, R]]{}
+	return parser[K, []R](func(st *State[K], toks []Token[K]) Output[K, []R] {
 		var err *Error
 		// 层序遍历, 每层更新结果(从 root 到该层节点的路径), 返回最后一层的结果(根节点到叶子节点路径)
-		xs := []Result[K, []R]{{Val: []R{}, next: toks}}
+		xs, nxs := pool.get(), pool.get()
+		defer pool.put(xs)
+		defer pool.put(nxs)
+		*xs = append(*xs, acc[K, R]{next: toks})
 		for {
-			var nxs []Result[K, []R]
-			for _, x := range xs {
-				out := p.Parse(x.next)
+			*nxs = (*nxs)[:0]
+			for _, x := range *xs {
+				out := ParseIn(st, p, x.next)
 				err = betterError(err, out.Error)
 				if out.Success {
 					for _, candidate := range out.Candidates {
 						// 必须消费掉 token, 重复 nil 死循环
 						if !toksEqual(x.next, candidate.next) {
-							nxs = append(nxs, Result[K, []R]{
-								Val:  concat(x.Val, candidate.Val),
-								next: candidate.next,
-							})
+							*nxs = append(*nxs, acc[K, R]{l: x.l.push(candidate.Val), next: candidate.next, nodes: x.nodes.concat(candidate.nodes)})
 						}
 					}
 				}
 			}
-			if len(nxs) == 0 {
+			if len(*nxs) == 0 {
 				break
 			}
-			xs = nxs
+			*nxs = prune(st, *nxs, restOfAcc[K, R])
+			xs, nxs = nxs, xs
 		}
-		return successWithErr(xs, err)
+		return successWithErr(results(*xs), err)
 	})
 }
 
 // RepR :: p[a] -> p[list[a]]
-// 重复 n 次(n>=0), 按路径从短(empty)到长返回结果
+// 重复 n 次(n>=0), 按路径从短(empty)到长返回结果; GreedyMode 下即 RepSc, 见 Mode
 func RepR[K TK, R any](p Parser[K, R]) Parser[K, []R] {
-	return parser[K, []R](func(toks []Token[K]) Output[K, []R] {
+	return moded(repRAll(p), RepSc(p))
+}
+
+func repRAll[K TK, R any](p Parser[K, R]) Parser[K, []R] {
+	pool := &slicePool[acc[K, R]]{}
+	return withEnum(parser[K, []R](func(st *State[K], toks []Token[K]) Output[K, []R] {
 		var err *Error
 		// 层序遍历, 穷举所有根节点到非根节点的路径, Candidates 为每个节点的分叉数
-		xs := []Result[K, []R]{{Val: []R{}, next: toks}}
+		xs := pool.get()
+		defer pool.put(xs)
+		*xs = append(*xs, acc[K, R]{next: toks})
+		for start := 0; start < len(*xs); {
+			// [start, end) 为同一层, WithBeam 期间逐层剪枝
+			end := len(*xs)
+			if level := prune(st, (*xs)[start:end], restOfAcc[K, R]); len(level) != end-start {
+				*xs = append((*xs)[:start], level...)
+				end = len(*xs)
+			}
+			for i := start; i < end; i++ {
+				step := (*xs)[i]
+				out := ParseIn(st, p, step.next)
+				err = betterError(err, out.Error)
+				if out.Success {
+					for _, candidate := range out.Candidates {
+						// 必须消费掉 token, 重复 nil 死循环
+						if !toksEqual(step.next, candidate.next) {
+							*xs = append(*xs, acc[K, R]{l: step.l.push(candidate.Val), next: candidate.next, nodes: step.nodes.concat(candidate.nodes)})
+						}
+					}
+				}
+			}
+			start = end
+		}
+		return successWithErr(results(*xs), err)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, []R]) bool) *Error {
+		// 按需枚举时与 Parse 一致按层展开, 但只转换 yield 的结果
+		var err *Error
+		xs := []acc[K, R]{{next: toks}}
 		for i := 0; i < len(xs); i++ {
 			step := xs[i]
-			out := p.Parse(step.next)
+			if !yield(Result[K, []R]{Val: step.l.slice(), next: step.next, nodes: step.nodes}) {
+				return err
+			}
+			out := ParseIn(st, p, step.next)
 			err = betterError(err, out.Error)
 			if out.Success {
 				for _, candidate := range out.Candidates {
-					// 必须消费掉 token, 重复 nil 死循环
 					if !toksEqual(step.next, candidate.next) {
-						xs = append(xs, Result[K, []R]{
-							Val:  concat(step.Val, candidate.Val),
-							next: candidate.next,
-						})
+						xs = append(xs, acc[K, R]{l: step.l.push(candidate.Val), next: candidate.next, nodes: step.nodes.concat(candidate.nodes)})
 					}
 				}
 			}
 		}
-		return successWithErr(xs, err)
+		return err
 	})
 }
 
 // RepN :: p[a] -> int -> p[list[a]]
 // 即 Count, 重复 n 次
 func RepN[K TK, R any](p Parser[K, R], cnt int) Parser[K, []R] {
-	return parser[K, []R](func(toks []Token[K]) Output[K, []R] {
+	pool := &slicePool[acc[K, R]]{}
+	return parser[K, []R](func(st *State[K], toks []Token[K]) Output[K, []R] {
 		var err *Error
 		// 层序遍历, 每层更新结果(从 root 到该层节点的路径), 返回最后一层的结果(根节点到叶子节点路径)
-		xs := []Result[K, []R]{{Val: []R{}, next: toks}}
+		xs, nxs := pool.get(), pool.get()
+		defer pool.put(xs)
+		defer pool.put(nxs)
+		*xs = append(*xs, acc[K, R]{next: toks})
 		for i := 0; i < cnt; i++ {
-			var nxs []Result[K, []R]
-			for _, x := range xs {
-				out := p.Parse(x.next)
+			*nxs = (*nxs)[:0]
+			for _, x := range *xs {
+				out := ParseIn(st, p, x.next)
 				err = betterError(err, out.Error)
 				if out.Success {
 					// if !x.next.equals(candidate.next) {}
 					for _, candidate := range out.Candidates {
-						nxs = append(nxs, Result[K, []R]{
-							Val:  concat(x.Val, candidate.Val),
-							next: candidate.next,
-						})
+						*nxs = append(*nxs, acc[K, R]{l: x.l.push(candidate.Val), next: candidate.next, nodes: x.nodes.concat(candidate.nodes)})
 					}
 				}
 			}
 
-			if len(nxs) == 0 {
+			if len(*nxs) == 0 {
 				return fail[K, []R](err)
 			}
-			xs = nxs
+			*nxs = prune(st, *nxs, restOfAcc[K, R])
+			xs, nxs = nxs, xs
+		}
+
+		return successWithErr(results(*xs), err)
+	})
+}
+
+// RepBetween :: p[a] -> int -> int -> p[list[a]]
+// 重复 min 到 max 次, max < 0 时不限制次数, 按路径从长到短返回结果; GreedyMode 下即 RepBetweenSc, 见 Mode
+func RepBetween[K TK, R any](p Parser[K, R], min, max int) Parser[K, []R] {
+	return moded(repBetween(p, min, max, false), RepBetweenSc(p, min, max))
+}
+
+// RepBetweenSc :: p[a] -> int -> int -> p[list[a]]
+// 至少重复 min 次, 消费尽可能多的 p(至多 max 次), 返回最深一层结果
+func RepBetweenSc[K TK, R any](p Parser[K, R], min, max int) Parser[K, []R] {
+	return repBetween(p, min, max, true)
+}
+
+func repBetween[K TK, R any](p Parser[K, R], min, max int, sc bool) Parser[K, []R] {
+	pool := &slicePool[acc[K, R]]{}
+	return parser[K, []R](func(st *State[K], toks []Token[K]) Output[K, []R] {
+		var err *Error
+		var levels [][]Result[K, []R]
+		xs, nxs := pool.get(), pool.get()
+		defer pool.put(xs)
+		defer pool.put(nxs)
+		*xs = append(*xs, acc[K, R]{next: toks})
+		for i := 0; ; i++ {
+			if i >= min {
+				levels = append(levels, results(*xs))
+			}
+			if i == max {
+				break
+			}
+			*nxs = (*nxs)[:0]
+			for _, x := range *xs {
+				out := ParseIn(st, p, x.next)
+				err = betterError(err, out.Error)
+				if out.Success {
+					for _, candidate := range out.Candidates {
+						// 满足 min 之后必须消费掉 token, 重复 nil 死循环
+						if i >= min && toksEqual(x.next, candidate.next) {
+							continue
+						}
+						*nxs = append(*nxs, acc[K, R]{l: x.l.push(candidate.Val), next: candidate.next, nodes: x.nodes.concat(candidate.nodes)})
+					}
+				}
+			}
+			if len(*nxs) == 0 {
+				break
+			}
+			*nxs = prune(st, *nxs, restOfAcc[K, R])
+			xs, nxs = nxs, xs
+		}
+		if len(levels) == 0 {
+			return fail[K, []R](err)
+		}
+		if sc {
+			return successWithErr(levels[len(levels)-1], err)
+		}
+		var rs []Result[K, []R]
+		for i := len(levels) - 1; i >= 0; i-- {
+			rs = append(rs, levels[i]...)
 		}
+		return successWithErr(rs, err)
+	})
+}
+
+// ManyTill :: p[a] -> p[e] -> p[list[a]]
+// 重复 p 直到 end 成功, 丢弃 end 的结果; 返回所有以 end 结束的路径, 按路径从短到长;
+// GreedyMode 下即 ManyTillSc, 见 Mode
+// e.g. 注释 KRight(Str("/*"), ManyTill(Any(), Str("*/")))
+func ManyTill[K TK, R, E any](p Parser[K, R], end Parser[K, E]) Parser[K, []R] {
+	return moded(manyTill(p, end, false), ManyTillSc(p, end))
+}
 
-		return successWithErr(xs, err)
+// ManyTillSc :: p[a] -> p[e] -> p[list[a]]
+// 即 Parsec 的 manyTill p end, 每次先尝试 end, end 成功即结束, 返回最短的路径
+// scan = do{ end; return [] } <|> do{ x <- p; xs <- scan; return (x:xs) }
+func ManyTillSc[K TK, R, E any](p Parser[K, R], end Parser[K, E]) Parser[K, []R] {
+	return manyTill(p, end, true)
+}
+
+func manyTill[K TK, R, E any](p Parser[K, R], end Parser[K, E], sc bool) Parser[K, []R] {
+	pool := &slicePool[acc[K, R]]{}
+	return parser[K, []R](func(st *State[K], toks []Token[K]) Output[K, []R] {
+		var err *Error
+		var rs []Result[K, []R]
+		xs, nxs := pool.get(), pool.get()
+		defer pool.put(xs)
+		defer pool.put(nxs)
+		*xs = append(*xs, acc[K, R]{next: toks})
+		for len(*xs) != 0 {
+			*nxs = (*nxs)[:0]
+			for _, x := range *xs {
+				out := ParseIn(st, end, x.next)
+				err = betterError(err, out.Error)
+				if out.Success {
+					for _, candidate := range out.Candidates {
+						rs = append(rs, Result[K, []R]{Val: x.l.slice(), next: candidate.next, nodes: x.nodes.concat(candidate.nodes)})
+					}
+					if sc {
+						continue
+					}
+				}
+				pout := ParseIn(st, p, x.next)
+				err = betterError(err, pout.Error)
+				if pout.Success {
+					for _, candidate := range pout.Candidates {
+						// 必须消费掉 token, 重复 nil 死循环
+						if !toksEqual(x.next, candidate.next) {
+							*nxs = append(*nxs, acc[K, R]{l: x.l.push(candidate.Val), next: candidate.next, nodes: x.nodes.concat(candidate.nodes)})
+						}
+					}
+				}
+			}
+			*nxs = prune(st, *nxs, restOfAcc[K, R])
+			xs, nxs = nxs, xs
+		}
+		return newOutput(rs, err, len(rs) != 0)
 	})
 }
 
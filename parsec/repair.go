@@ -0,0 +1,242 @@
+package parsec
+
+import (
+	"container/heap"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------
+// Error Correcting, 基于代价的错误修复
+// ----------------------------------------------------------------
+
+// EditKind 修复操作
+type EditKind int
+
+const (
+	InsertEdit     EditKind = iota // 插入 token
+	DeleteEdit                     // 删除 token
+	SubstituteEdit                 // 替换 token
+)
+
+// Edit 一次修复, 位置均为原输入中的位置
+type Edit[K TK] struct {
+	Kind EditKind
+	Pos  Pos      // 插入时为插入处之后的 token 或 EOFPos
+	Old  Token[K] // 删除或替换的 token
+	New  Token[K] // 插入或替换后的 token
+}
+
+func (e Edit[K]) String() string {
+	switch e.Kind {
+	case InsertEdit:
+		return fmt.Sprintf("inserted `%s` at %s", e.New, locString(e.Pos))
+	case DeleteEdit:
+		return fmt.Sprintf("deleted `%s` at %s", e.Old, locString(e.Pos))
+	default:
+		return fmt.Sprintf("replaced `%s` with `%s` at %s", e.Old, e.New, locString(e.Pos))
+	}
+}
+
+// RepairOptions 修复代价, 代价函数为 nil 时代价为 1, 返回负数表示不允许该操作
+// 插入与替换的 token 来自最远错误处期望的 Tok / Str, Tok 插入的文本为 TokenKind 的字符串形式
+type RepairOptions[K TK] struct {
+	Insert     func(kind K, lexeme string) int
+	Delete     func(tok Token[K]) int
+	Substitute func(old Token[K], kind K, lexeme string) int
+	MaxCost    int // 最大总代价, 默认 3
+	MaxSteps   int // 最多尝试解析的次数, 默认 1000
+}
+
+// Repair 解析失败时, 搜索代价最小的插入, 删除, 替换序列, 使 ExpectEOF(p.Parse(toks)) 成功
+// 每次只在最远错误(Output.Error)的位置修改, 候选 token 为该位置期望的 token, 按代价从小到大(同代价按生成顺序)尝试;
+// 返回修复后的解析结果与修复列表, 无需修复时修复列表为空, 无法修复时返回原始的失败结果
+// e.g. out, edits := Repair(expr, toks, RepairOptions[K]{}), edits[0].String() == "inserted `)` at line 3 col 7"
+func Repair[K TK, R any](p Parser[K, R], toks []Token[K], opts RepairOptions[K]) (Output[K, R], []Edit[K]) {
+	first := ExpectEOF(p.Parse(toks))
+	if first.Success {
+		return first, nil
+	}
+	if opts.MaxCost <= 0 {
+		opts.MaxCost = 3
+	}
+	if opts.MaxSteps <= 0 {
+		opts.MaxSteps = 1000
+	}
+	if opts.Insert == nil {
+		opts.Insert = func(K, string) int { return 1 }
+	}
+	if opts.Delete == nil {
+		opts.Delete = func(Token[K]) int { return 1 }
+	}
+	if opts.Substitute == nil {
+		opts.Substitute = func(Token[K], K, string) int { return 1 }
+	}
+
+	q := &repairQueue[K]{}
+	seen := map[string]bool{}
+	tokens := 0 // 已经构造的修复 token 数, 见 newRepairToken
+	out := first
+	cur := &repairState[K]{toks: toks}
+	for steps := 0; ; steps++ {
+		// 最远错误处可以进行的修复
+		i := errorIndex(cur.toks, out.Error)
+		var at Pos = EOFPos
+		if i < len(cur.toks) {
+			at = cur.toks[i]
+			if vt, ok := at.(virtualToken[K]); ok {
+				at = vt.Pos
+			}
+		}
+		push := func(c int, toks []Token[K], e Edit[K]) {
+			if c < 0 || cur.cost+c > opts.MaxCost {
+				return
+			}
+			if key := repairKey(toks); !seen[key] {
+				seen[key] = true
+				heap.Push(q, &repairState[K]{toks: toks, edits: concat(cur.edits, e), cost: cur.cost + c, seq: len(seen)})
+			}
+		}
+		expects := expectsOf[K](out.Error)
+		for _, x := range expects {
+			tokens++
+			t := newRepairToken(x, at, tokens)
+			push(opts.Insert(x.Kind, x.Literal), splice(cur.toks, i, 0, t), Edit[K]{Kind: InsertEdit, Pos: at, New: t})
+		}
+		if i < len(cur.toks) {
+			old := cur.toks[i]
+			push(opts.Delete(old), splice[K](cur.toks, i, 1), Edit[K]{Kind: DeleteEdit, Pos: at, Old: old})
+			for _, x := range expects {
+				tokens++
+				t := newRepairToken(x, at, tokens)
+				push(opts.Substitute(old, x.Kind, x.Literal), splice(cur.toks, i, 1, t),
+					Edit[K]{Kind: SubstituteEdit, Pos: at, Old: old, New: t})
+			}
+		}
+
+		if q.Len() == 0 || steps >= opts.MaxSteps {
+			return first, nil
+		}
+		cur = heap.Pop(q).(*repairState[K])
+		out = ExpectEOF(p.Parse(cur.toks))
+		if out.Success {
+			return out, cur.edits
+		}
+	}
+}
+
+// newRepairToken 修复时插入或替换的 token, 即 kind 为期望的 TokenKind 的虚拟 token,
+// 位置为插入处原 token 的位置, seq 为 Repair 中的序号
+func newRepairToken[K TK](x Expectation[K], at Pos, seq int) Token[K] {
+	lexeme := x.Literal
+	if lexeme == "" {
+		lexeme = fmt.Sprintf("%v", x.Kind)
+	}
+	return virtualToken[K]{Pos: at, kind: x.Kind, name: lexeme, seq: seq}
+}
+
+type repairState[K TK] struct {
+	toks  []Token[K]
+	edits []Edit[K]
+	cost  int
+	seq   int // 同代价按生成顺序尝试
+}
+
+type repairQueue[K TK] []*repairState[K]
+
+func (q repairQueue[K]) Len() int { return len(q) }
+func (q repairQueue[K]) Less(i, j int) bool {
+	if q[i].cost != q[j].cost {
+		return q[i].cost < q[j].cost
+	}
+	return q[i].seq < q[j].seq
+}
+func (q repairQueue[K]) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *repairQueue[K]) Push(x any)   { *q = append(*q, x.(*repairState[K])) }
+func (q *repairQueue[K]) Pop() any {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// errorIndex 错误在 toks 中的下标, 输入结束时为 len(toks)
+func errorIndex[K TK](toks []Token[K], e *Error) int {
+	if e == nil || e.Pos == EOFPos {
+		return len(toks)
+	}
+	for i, t := range toks {
+		if Pos(t) == e.Pos {
+			return i
+		}
+	}
+	idx, _, _, _ := e.Loc()
+	if idx < 0 {
+		return len(toks)
+	}
+	for i, t := range toks {
+		if j, _, _, _ := t.Loc(); j >= idx {
+			return i
+		}
+	}
+	return len(toks)
+}
+
+// expectsOf 错误处期望的 token, 按 (Kind, Literal) 去重;
+// Satisfy 的期望只有描述, 无法构造对应的 token, 不作为插入或替换的候选
+func expectsOf[K TK](e *Error) []Expectation[K] {
+	if e == nil {
+		return nil
+	}
+	var xs []Expectation[K]
+	for _, x := range e.expects {
+		if x.isLabel {
+			continue
+		}
+		var y Expectation[K]
+		if x.isLit {
+			y.Literal = x.text
+		} else {
+			y.Kind = x.kind.(K)
+		}
+		dup := false
+		for _, z := range xs {
+			dup = dup || (z.Kind == y.Kind && z.Literal == y.Literal)
+		}
+		if !dup {
+			xs = append(xs, y)
+		}
+	}
+	return xs
+}
+
+// splice 删除 toks[i:i+n], 插入 xs, 返回新的切片
+func splice[K TK](toks []Token[K], i, n int, xs ...Token[K]) []Token[K] {
+	ys := make([]Token[K], 0, len(toks)-n+len(xs))
+	ys = append(ys, toks[:i]...)
+	ys = append(ys, xs...)
+	return append(ys, toks[i+n:]...)
+}
+
+// repairKey 修复后 token 序列的标识, 原始 token 按位置, 插入的 token 按文本
+func repairKey[K TK](toks []Token[K]) string {
+	var b strings.Builder
+	for _, t := range toks {
+		if vt, ok := t.(virtualToken[K]); ok {
+			b.WriteString("+" + strconv.Quote(vt.name) + fmt.Sprintf("%v", vt.kind))
+		} else {
+			idx, _, _, _ := t.Loc()
+			b.WriteString("#" + strconv.Itoa(idx))
+		}
+	}
+	return b.String()
+}
+
+func locString(pos Pos) string {
+	if vp, ok := pos.(VirtualPos); ok {
+		return string(vp)
+	}
+	_, _, col, ln := pos.Loc()
+	return fmt.Sprintf("line %d col %d", ln+1, col+1)
+}
@@ -0,0 +1,66 @@
+package parsec
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	calc := benchCalc()
+	expensiveInsert := RepairOptions[benchKind]{Insert: func(benchKind, string) int { return 2 }}
+
+	for _, tt := range []struct {
+		input  string
+		opts   RepairOptions[benchKind]
+		expect string
+	}{
+		{"1 + 2", RepairOptions[benchKind]{}, "3 []"},
+		{"(1 + 2", RepairOptions[benchKind]{}, "3 [inserted `)` at end of input]"},
+		{"1 + 2)", RepairOptions[benchKind]{}, "3 [deleted `)` at line 1 col 6]"},
+		{"2 * (1 + 2 ]", RepairOptions[benchKind]{}, "6 [replaced `]` with `)` at line 1 col 12]"},
+		{"((1 + 2", RepairOptions[benchKind]{}, "3 [inserted `)` at end of input inserted `)` at end of input]"},
+		// 插入的 <num> 计算为 0
+		{"1 + * 2", RepairOptions[benchKind]{}, "1 [inserted `<num>` at line 1 col 5]"},
+		// 按 kind 设置代价
+		{"1 + * 2", expensiveInsert, "3 [deleted `*` at line 1 col 5]"},
+		{"((1 + 2", RepairOptions[benchKind]{MaxCost: 1}, "Nothing to consume expect `*`"},
+		{"((1 + 2", RepairOptions[benchKind]{Insert: func(_ benchKind, lit string) int {
+			if lit == ")" {
+				return -1
+			}
+			return 1
+		}}, "Nothing to consume expect `*`"},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			out, edits := Repair(calc, benchLex(tt.input), tt.opts)
+			actual := ""
+			if out.Success {
+				actual = fmt.Sprintf("%d %v", out.Candidates[0].Val, edits)
+			} else {
+				actual = out.Msg
+			}
+			if actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func TestVirtualToken(t *testing.T) {
+	if EOFToken[benchKind]() != EOFToken[benchKind]() {
+		t.Errorf("expect comparable EOFToken")
+	}
+	// 在输入末尾插入的 token 不是 EOF
+	x := newRepairToken(Expectation[benchKind]{Literal: "x"}, EOFPos, 1)
+	out := op("y").Parse([]Token[benchKind]{x})
+	if out.Success || out.Pos != EOFPos || out.Msg != "Unable to consume token `x` expect `y`" {
+		t.Errorf("unexpected %v", out.Error)
+	}
+	out = op("y").Parse(nil)
+	if out.Success || out.Pos != EOFPos || out.Msg != "Nothing to consume expect `y`" {
+		t.Errorf("unexpected %v", out.Error)
+	}
+	if newRepairToken(Expectation[benchKind]{Literal: "x"}, EOFPos, 1) == newRepairToken(Expectation[benchKind]{Literal: "x"}, EOFPos, 2) {
+		t.Errorf("expect distinct repair tokens")
+	}
+}
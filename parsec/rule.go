@@ -9,18 +9,107 @@ func NewRule[K TK, R any]() *SyntaxRule[K, R] {
 }
 
 type SyntaxRule[K TK, R any] struct {
-	Pattern Parser[K, R]
+	Pattern Parser[K, R] // 建议通过 SetPattern 设置; Grammar.Build 之后不能修改, 否则解析时 panic
+	name    string
+	id      int                  // Grammar.Build 分配的 id, 不属于 Grammar 时为 0
+	frozen  *frozenPattern[K, R] // Grammar.Build 冻结的 Pattern, 同时赋值给 Pattern 以便检查修改
+	owned   bool                 // 由 Grammar 创建, Build 时统一包装 Trace
 }
 
+// frozenPattern 包装冻结的 Pattern, 指针可以比较, 用来发现 Build 之后对 Pattern 字段的修改
+type frozenPattern[K TK, R any] struct {
+	Parser[K, R]
+}
+
+// SetPattern 设置规则名与 Pattern; Grammar.Build 之后调用会 panic, Define 创建的规则不能改名
 func (r *SyntaxRule[K, R]) SetPattern(name string, p Parser[K, R]) {
-	r.Pattern = Trace(name, p)
+	if r.frozen != nil {
+		panic(fmt.Sprintf("Rule %s has been frozen by Grammar.Build.", r.name))
+	}
+	if r.owned && name != r.name {
+		panic(fmt.Sprintf("Rule %s is defined by Grammar, cannot rename to %s.", r.name, name))
+	}
+	r.name = name
+	if r.owned {
+		r.Pattern = p
+	} else {
+		r.Pattern = Trace(name, p)
+	}
 }
 
-func (r *SyntaxRule[K, R]) Parse(toks []Token[K]) Output[K, R] {
+// Name SetPattern 设置的规则名
+func (r *SyntaxRule[K, R]) Name() string {
+	return r.name
+}
+
+// ID Grammar.Build 分配的 id, 按 Define 的顺序从 1 开始, 可用于 memo 与 trace; 不属于 Grammar 时为 0
+func (r *SyntaxRule[K, R]) ID() int {
+	return r.id
+}
+
+func (r *SyntaxRule[K, R]) pattern() Parser[K, R] {
+	if r.frozen != nil {
+		if r.Pattern != Parser[K, R](r.frozen) {
+			panic(fmt.Sprintf("Pattern of rule %s has been modified after Grammar.Build.", r.name))
+		}
+		return r.frozen.Parser
+	}
 	if r.Pattern == nil {
 		panic("Rule has not been initialized. Pattern is required before calling parse.")
 	}
-	return r.Pattern.Parse(toks)
+	return r.Pattern
+}
+
+func (r *SyntaxRule[K, R]) Parse(toks []Token[K]) Output[K, R] {
+	return r.parseIn(new(State[K]), toks)
+}
+
+func (r *SyntaxRule[K, R]) parseIn(st *State[K], toks []Token[K]) Output[K, R] {
+	p := r.pattern()
+	if r.name == "" {
+		return ParseIn(st, p, toks)
+	}
+	st.pushRule(r.name, toks)
+	out := ParseIn(st, p, toks)
+	out.Error = st.within(out.Error)
+	st.popRule()
+	if out.Success && st.partial != nil {
+		recordRule(st, r.name, toks, out.Candidates)
+	}
+	if out.Success && st.build != nil {
+		xs := make([]Result[K, R], len(out.Candidates))
+		for i, candidate := range out.Candidates {
+			xs[i] = buildNode(st, r.name, toks, candidate)
+		}
+		out.Candidates = xs
+	}
+	return out
+}
+
+func (r *SyntaxRule[K, R]) Enum(toks []Token[K], yield func(Result[K, R]) bool) *Error {
+	return r.enumIn(new(State[K]), toks, yield)
+}
+
+func (r *SyntaxRule[K, R]) enumIn(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+	p := r.pattern()
+	if r.name == "" {
+		return EnumIn(st, p, toks, yield)
+	}
+	st.pushRule(r.name, toks)
+	err := EnumIn(st, p, toks, func(res Result[K, R]) bool {
+		// yield 会继续解析规则之后的部分, 不在规则中
+		n := len(st.rules) - 1
+		call := st.rules[n]
+		st.rules = st.rules[:n]
+		defer func() { st.rules = append(st.rules[:n], call) }()
+		if st.partial != nil {
+			recordRule(st, r.name, toks, []Result[K, R]{res})
+		}
+		return yield(buildNode(st, r.name, toks, res))
+	})
+	err = st.within(err)
+	st.popRule()
+	return err
 }
 
 // Parser
@@ -43,15 +132,18 @@ func ExpectEOF[K TK, R any](out Output[K, R]) Output[K, R] {
 		if len(candidate.next) == 0 {
 			xs = append(xs, candidate)
 		} else {
-			pso := beginPos(candidate.next)
-			msg := fmt.Sprintf("The parser cannot reach the end of file, stops %s in %s",
-				candidate.next[0], Pos(candidate.next[0]))
-			err = betterError(err, newError(pso, msg))
+			err = betterError(err, notReachEOF(candidate.next))
 		}
 	}
 	return newOutput(xs, err, len(xs) != 0)
 }
 
+func notReachEOF[K TK](rest []Token[K]) *Error {
+	pso := beginPos(rest)
+	msg := fmt.Sprintf("The parser cannot reach the end of file, stops %s in %s", rest[0], Pos(rest[0]))
+	return newError(pso, msg)
+}
+
 func ExpectSingleResult[K TK, R any](out Output[K, R]) (R, error) {
 	if !out.Success {
 		return *new(R), out.Error
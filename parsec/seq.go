@@ -7,31 +7,50 @@ package parsec
 // Seq :: p[a] -> p[b] -> p[c] -> ... -> p[(a,b,c...)]
 // 顺次匹配, 对 ps 进行 foldLeft, append 收集数据
 func Seq[K TK, R any](ps ...Parser[K, R]) Parser[K, []R] {
-	return parser[K, []R](func(toks []Token[K]) Output[K, []R] {
+	pool := &slicePool[acc[K, R]]{}
+	return withEnum(parser[K, []R](func(st *State[K], toks []Token[K]) Output[K, []R] {
 		var err *Error
 		// 层序遍历, ps 代表层次(每层使用的 p), 每层更新结果(从 root 到该层节点的路径),
-		// 返回根节点到所有叶子节点的路径
-		xs := []Result[K, []R]{{Val: []R{}, next: toks}}
+		// 返回根节点到所有叶子节点的路径, 两层交替使用池中的切片
+		xs, nxs := pool.get(), pool.get()
+		defer pool.put(xs)
+		defer pool.put(nxs)
+		*xs = append(*xs, acc[K, R]{next: toks})
 		for _, p := range ps {
-			var nxs []Result[K, []R]
-			for _, x := range xs {
-				out := p.Parse(x.next)
+			*nxs = (*nxs)[:0]
+			for _, x := range *xs {
+				out := ParseIn(st, p, x.next)
 				err = betterError(err, out.Error)
 				if out.Success {
 					for _, candidate := range out.Candidates {
-						nxs = append(nxs, Result[K, []R]{
-							Val:  concat(x.Val, candidate.Val),
-							next: candidate.next,
-						})
+						*nxs = append(*nxs, acc[K, R]{l: x.l.push(candidate.Val), next: candidate.next, nodes: x.nodes.concat(candidate.nodes)})
 					}
 				}
 			}
-			if len(nxs) == 0 {
+			if len(*nxs) == 0 {
 				return fail[K, []R](err)
 			}
-			xs = nxs
+			*nxs = prune(st, *nxs, restOfAcc[K, R])
+			xs, nxs = nxs, xs
 		}
-		return newOutput(xs, err, len(xs) != 0)
+		return newOutput(results(*xs), err, len(*xs) != 0)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, []R]) bool) *Error {
+		var err *Error
+		// 深度优先, 路径用持久化链表累积, 只转换 yield 的结果
+		var walk func(i int, l *plist[R], ns *nodes, toks []Token[K]) bool
+		walk = func(i int, l *plist[R], ns *nodes, toks []Token[K]) bool {
+			if i == len(ps) {
+				return yield(Result[K, []R]{Val: l.slice(), next: toks, nodes: ns})
+			}
+			cont := true
+			err = betterError(err, EnumIn(st, ps[i], toks, func(r Result[K, R]) bool {
+				cont = walk(i+1, l.push(r.Val), ns.concat(r.nodes), r.next)
+				return cont
+			}))
+			return cont
+		}
+		walk(0, nil, nil, toks)
+		return err
 	})
 }
 
@@ -39,26 +58,42 @@ func Seq2[K TK, R1, R2 any](
 	p1 Parser[K, R1],
 	p2 Parser[K, R2],
 ) Parser[K, Cons[R1, R2]] {
-	return parser[K, Cons[R1, R2]](func(toks []Token[K]) Output[K, Cons[R1, R2]] {
-		out1 := p1.Parse(toks)
+	return withEnum(parser[K, Cons[R1, R2]](func(st *State[K], toks []Token[K]) Output[K, Cons[R1, R2]] {
+		out1 := ParseIn(st, p1, toks)
 		if !out1.Success {
 			return failOf[K, R1, Cons[R1, R2]](out1)
 		}
 		var xs []Result[K, Cons[R1, R2]]
 		err := out1.Error
 		for _, step := range out1.Candidates {
-			out2 := p2.Parse(step.next)
+			out2 := ParseIn(st, p2, step.next)
 			err = betterError(err, out2.Error)
 			if out2.Success {
 				for _, candidate := range out2.Candidates {
 					xs = append(xs, Result[K, Cons[R1, R2]]{
-						Val:  Cons[R1, R2]{Car: step.Val, Cdr: candidate.Val},
-						next: candidate.next,
+						Val:   Cons[R1, R2]{Car: step.Val, Cdr: candidate.Val},
+						next:  candidate.next,
+						nodes: step.nodes.concat(candidate.nodes),
 					})
 				}
 			}
 		}
 		return newOutput(xs, err, len(xs) != 0)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, Cons[R1, R2]]) bool) *Error {
+		var err *Error
+		err = betterError(err, EnumIn(st, p1, toks, func(step Result[K, R1]) bool {
+			cont := true
+			err = betterError(err, EnumIn(st, p2, step.next, func(r Result[K, R2]) bool {
+				cont = yield(Result[K, Cons[R1, R2]]{
+					Val:   Cons[R1, R2]{Car: step.Val, Cdr: r.Val},
+					next:  r.next,
+					nodes: step.nodes.concat(r.nodes),
+				})
+				return cont
+			}))
+			return cont
+		}))
+		return err
 	})
 }
 func Seq3[K TK, R1, R2, R3 any](
@@ -104,8 +139,8 @@ func Combine[K TK, R any](
 	p Parser[K, R],
 	ks ...func(R) Parser[K, R], // continuations
 ) Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
-		out1 := p.Parse(toks)
+	return withEnum(parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		out1 := ParseIn(st, p, toks)
 		if !out1.Success {
 			return out1
 		}
@@ -116,19 +151,39 @@ func Combine[K TK, R any](
 		for _, k := range ks {
 			var nxs []Result[K, R]
 			for _, x := range xs {
-				out := k(x.Val).Parse(x.next)
+				out := ParseIn(st, k(x.Val), x.next)
 				err = betterError(err, out.Error)
 				if out.Success {
 					// 如果需要 concat 用 seq
-					nxs = append(nxs, out.Candidates...)
+					for _, candidate := range out.Candidates {
+						nxs = append(nxs, candidate.after(x.nodes))
+					}
 				}
 			}
 			if len(nxs) == 0 {
 				return fail[K, R](err)
 			}
-			xs = nxs
+			xs = prune(st, nxs, restOfResult[K, R])
 		}
 		return newOutput(xs, err, len(xs) != 0)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+		var err *Error
+		var walk func(i int, x Result[K, R]) bool
+		walk = func(i int, x Result[K, R]) bool {
+			if i == len(ks) {
+				return yield(x)
+			}
+			cont := true
+			err = betterError(err, EnumIn(st, ks[i](x.Val), x.next, func(r Result[K, R]) bool {
+				cont = walk(i+1, r.after(x.nodes))
+				return cont
+			}))
+			return cont
+		}
+		err = betterError(err, EnumIn(st, p, toks, func(r Result[K, R]) bool {
+			return walk(0, r)
+		}))
+		return err
 	})
 }
 
@@ -137,8 +192,8 @@ func Combine2[K TK, R1, R2 any](
 	p Parser[K, R1],
 	k func(R1) Parser[K, R2],
 ) Parser[K, R2] {
-	return parser[K, R2](func(toks []Token[K]) Output[K, R2] {
-		out1 := p.Parse(toks)
+	return withEnum(parser[K, R2](func(st *State[K], toks []Token[K]) Output[K, R2] {
+		out1 := ParseIn(st, p, toks)
 		if !out1.Success {
 			return failOf[K, R1, R2](out1)
 		}
@@ -146,14 +201,27 @@ func Combine2[K TK, R1, R2 any](
 		var xs []Result[K, R2]
 		err := out1.Error
 		for _, step := range out1.Candidates {
-			out := k(step.Val).Parse(step.next)
+			out := ParseIn(st, k(step.Val), step.next)
 			err = betterError(err, out.Error)
 			if out.Success {
-				xs = append(xs, out.Candidates...)
+				for _, candidate := range out.Candidates {
+					xs = append(xs, candidate.after(step.nodes))
+				}
 			}
 		}
 
 		return newOutput(xs, err, len(xs) != 0)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, R2]) bool) *Error {
+		var err *Error
+		err = betterError(err, EnumIn(st, p, toks, func(step Result[K, R1]) bool {
+			cont := true
+			err = betterError(err, EnumIn(st, k(step.Val), step.next, func(r Result[K, R2]) bool {
+				cont = yield(r.after(step.nodes))
+				return cont
+			}))
+			return cont
+		}))
+		return err
 	})
 }
 func Combine3[K TK, R1, R2, R3 any](
@@ -161,8 +229,8 @@ func Combine3[K TK, R1, R2, R3 any](
 	k1 func(R1) Parser[K, R2],
 	k2 func(R2) Parser[K, R3],
 ) Parser[K, R3] {
-	return parser[K, R3](func(toks []Token[K]) Output[K, R3] {
-		return Combine2(Combine2(p, k1), k2).Parse(toks)
+	return parser[K, R3](func(st *State[K], toks []Token[K]) Output[K, R3] {
+		return ParseIn(st, Combine2(Combine2(p, k1), k2), toks)
 	})
 }
 func Combine4[K TK, R1, R2, R3, R4 any](
@@ -171,8 +239,8 @@ func Combine4[K TK, R1, R2, R3, R4 any](
 	k2 func(R2) Parser[K, R3],
 	k3 func(R3) Parser[K, R4],
 ) Parser[K, R4] {
-	return parser[K, R4](func(toks []Token[K]) Output[K, R4] {
-		return Combine2(Combine3(p, k1, k2), k3).Parse(toks)
+	return parser[K, R4](func(st *State[K], toks []Token[K]) Output[K, R4] {
+		return ParseIn(st, Combine2(Combine3(p, k1, k2), k3), toks)
 	})
 }
 func Combine5[K TK, R1, R2, R3, R4, R5 any](
@@ -182,8 +250,8 @@ func Combine5[K TK, R1, R2, R3, R4, R5 any](
 	k3 func(R3) Parser[K, R4],
 	k4 func(R4) Parser[K, R5],
 ) Parser[K, R5] {
-	return parser[K, R5](func(toks []Token[K]) Output[K, R5] {
-		return Combine2(Combine4(p, k1, k2, k3), k4).Parse(toks)
+	return parser[K, R5](func(st *State[K], toks []Token[K]) Output[K, R5] {
+		return ParseIn(st, Combine2(Combine4(p, k1, k2, k3), k4), toks)
 	})
 }
 
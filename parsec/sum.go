@@ -0,0 +1,322 @@
+package parsec
+
+import "fmt"
+
+// ----------------------------------------------------------------
+// Sum Type, 扁平的和类型
+// ----------------------------------------------------------------
+
+// OneOfN 为 T1 ~ TN 之一, 对应 AltN 的 N 个分支, 代替嵌套的 Either;
+// Index 为值的类型序号(1 ~ N), 零值的 Index 为 0;
+// Match 与 FoldN 需要为每个分支提供函数, 增删分支时无法编译, 即穷尽匹配
+// e.g.
+//
+//	v := FromEither3(e) // Either[A, Either[B, C]]
+//	s := Fold3(v, showA, showB, showC)
+
+// OneOf2 T1 | T2 之一
+type OneOf2[T1, T2 any] struct {
+	idx int
+	v1  T1
+	v2  T2
+}
+
+func Case1Of2[T1, T2 any](v T1) OneOf2[T1, T2] { return OneOf2[T1, T2]{idx: 1, v1: v} }
+func Case2Of2[T1, T2 any](v T2) OneOf2[T1, T2] { return OneOf2[T1, T2]{idx: 2, v2: v} }
+
+// Index 值的类型序号 1 ~ 2
+func (o OneOf2[T1, T2]) Index() int     { return o.idx }
+func (o OneOf2[T1, T2]) V1() (T1, bool) { return o.v1, o.idx == 1 }
+func (o OneOf2[T1, T2]) V2() (T2, bool) { return o.v2, o.idx == 2 }
+
+// Match 按值的类型调用对应的函数
+func (o OneOf2[T1, T2]) Match(f1 func(T1), f2 func(T2)) {
+	switch o.idx {
+	case 1:
+		f1(o.v1)
+	case 2:
+		f2(o.v2)
+	default:
+		panic("OneOf2: zero value")
+	}
+}
+
+func (o OneOf2[T1, T2]) String() string {
+	switch o.idx {
+	case 1:
+		return fmt.Sprintf("%v", o.v1)
+	case 2:
+		return fmt.Sprintf("%v", o.v2)
+	default:
+		return "<nil>"
+	}
+}
+
+// Fold2 按值的类型调用对应的函数, 返回其结果
+func Fold2[T1, T2, R any](o OneOf2[T1, T2], f1 func(T1) R, f2 func(T2) R) R {
+	switch o.idx {
+	case 1:
+		return f1(o.v1)
+	case 2:
+		return f2(o.v2)
+	default:
+		panic("OneOf2: zero value")
+	}
+}
+
+// FromEither2 转换 Alt2 返回的嵌套 Either
+func FromEither2[T1, T2 any](e Either[T1, T2]) OneOf2[T1, T2] {
+	if e.IsLeft() {
+		return Case1Of2[T1, T2](e.Left)
+	}
+	return Case2Of2[T1, T2](e.Right)
+}
+
+// OneOf3 T1 | T2 | T3 之一
+type OneOf3[T1, T2, T3 any] struct {
+	idx int
+	v1  T1
+	v2  T2
+	v3  T3
+}
+
+func Case1Of3[T1, T2, T3 any](v T1) OneOf3[T1, T2, T3] { return OneOf3[T1, T2, T3]{idx: 1, v1: v} }
+func Case2Of3[T1, T2, T3 any](v T2) OneOf3[T1, T2, T3] { return OneOf3[T1, T2, T3]{idx: 2, v2: v} }
+func Case3Of3[T1, T2, T3 any](v T3) OneOf3[T1, T2, T3] { return OneOf3[T1, T2, T3]{idx: 3, v3: v} }
+
+// Index 值的类型序号 1 ~ 3
+func (o OneOf3[T1, T2, T3]) Index() int     { return o.idx }
+func (o OneOf3[T1, T2, T3]) V1() (T1, bool) { return o.v1, o.idx == 1 }
+func (o OneOf3[T1, T2, T3]) V2() (T2, bool) { return o.v2, o.idx == 2 }
+func (o OneOf3[T1, T2, T3]) V3() (T3, bool) { return o.v3, o.idx == 3 }
+
+// Match 按值的类型调用对应的函数
+func (o OneOf3[T1, T2, T3]) Match(f1 func(T1), f2 func(T2), f3 func(T3)) {
+	switch o.idx {
+	case 1:
+		f1(o.v1)
+	case 2:
+		f2(o.v2)
+	case 3:
+		f3(o.v3)
+	default:
+		panic("OneOf3: zero value")
+	}
+}
+
+func (o OneOf3[T1, T2, T3]) String() string {
+	switch o.idx {
+	case 1:
+		return fmt.Sprintf("%v", o.v1)
+	case 2:
+		return fmt.Sprintf("%v", o.v2)
+	case 3:
+		return fmt.Sprintf("%v", o.v3)
+	default:
+		return "<nil>"
+	}
+}
+
+// Fold3 按值的类型调用对应的函数, 返回其结果
+func Fold3[T1, T2, T3, R any](o OneOf3[T1, T2, T3], f1 func(T1) R, f2 func(T2) R, f3 func(T3) R) R {
+	switch o.idx {
+	case 1:
+		return f1(o.v1)
+	case 2:
+		return f2(o.v2)
+	case 3:
+		return f3(o.v3)
+	default:
+		panic("OneOf3: zero value")
+	}
+}
+
+// FromEither3 转换 Alt3 返回的嵌套 Either
+func FromEither3[T1, T2, T3 any](e Either[T1, Either[T2, T3]]) OneOf3[T1, T2, T3] {
+	if e.IsLeft() {
+		return Case1Of3[T1, T2, T3](e.Left)
+	}
+	r := FromEither2(e.Right)
+	return OneOf3[T1, T2, T3]{idx: r.idx + 1, v2: r.v1, v3: r.v2}
+}
+
+// OneOf4 T1 | T2 | T3 | T4 之一
+type OneOf4[T1, T2, T3, T4 any] struct {
+	idx int
+	v1  T1
+	v2  T2
+	v3  T3
+	v4  T4
+}
+
+func Case1Of4[T1, T2, T3, T4 any](v T1) OneOf4[T1, T2, T3, T4] {
+	return OneOf4[T1, T2, T3, T4]{idx: 1, v1: v}
+}
+func Case2Of4[T1, T2, T3, T4 any](v T2) OneOf4[T1, T2, T3, T4] {
+	return OneOf4[T1, T2, T3, T4]{idx: 2, v2: v}
+}
+func Case3Of4[T1, T2, T3, T4 any](v T3) OneOf4[T1, T2, T3, T4] {
+	return OneOf4[T1, T2, T3, T4]{idx: 3, v3: v}
+}
+func Case4Of4[T1, T2, T3, T4 any](v T4) OneOf4[T1, T2, T3, T4] {
+	return OneOf4[T1, T2, T3, T4]{idx: 4, v4: v}
+}
+
+// Index 值的类型序号 1 ~ 4
+func (o OneOf4[T1, T2, T3, T4]) Index() int     { return o.idx }
+func (o OneOf4[T1, T2, T3, T4]) V1() (T1, bool) { return o.v1, o.idx == 1 }
+func (o OneOf4[T1, T2, T3, T4]) V2() (T2, bool) { return o.v2, o.idx == 2 }
+func (o OneOf4[T1, T2, T3, T4]) V3() (T3, bool) { return o.v3, o.idx == 3 }
+func (o OneOf4[T1, T2, T3, T4]) V4() (T4, bool) { return o.v4, o.idx == 4 }
+
+// Match 按值的类型调用对应的函数
+func (o OneOf4[T1, T2, T3, T4]) Match(f1 func(T1), f2 func(T2), f3 func(T3), f4 func(T4)) {
+	switch o.idx {
+	case 1:
+		f1(o.v1)
+	case 2:
+		f2(o.v2)
+	case 3:
+		f3(o.v3)
+	case 4:
+		f4(o.v4)
+	default:
+		panic("OneOf4: zero value")
+	}
+}
+
+func (o OneOf4[T1, T2, T3, T4]) String() string {
+	switch o.idx {
+	case 1:
+		return fmt.Sprintf("%v", o.v1)
+	case 2:
+		return fmt.Sprintf("%v", o.v2)
+	case 3:
+		return fmt.Sprintf("%v", o.v3)
+	case 4:
+		return fmt.Sprintf("%v", o.v4)
+	default:
+		return "<nil>"
+	}
+}
+
+// Fold4 按值的类型调用对应的函数, 返回其结果
+func Fold4[T1, T2, T3, T4, R any](o OneOf4[T1, T2, T3, T4], f1 func(T1) R, f2 func(T2) R, f3 func(T3) R, f4 func(T4) R) R {
+	switch o.idx {
+	case 1:
+		return f1(o.v1)
+	case 2:
+		return f2(o.v2)
+	case 3:
+		return f3(o.v3)
+	case 4:
+		return f4(o.v4)
+	default:
+		panic("OneOf4: zero value")
+	}
+}
+
+// FromEither4 转换 Alt4 返回的嵌套 Either
+func FromEither4[T1, T2, T3, T4 any](e Either[T1, Either[T2, Either[T3, T4]]]) OneOf4[T1, T2, T3, T4] {
+	if e.IsLeft() {
+		return Case1Of4[T1, T2, T3, T4](e.Left)
+	}
+	r := FromEither3(e.Right)
+	return OneOf4[T1, T2, T3, T4]{idx: r.idx + 1, v2: r.v1, v3: r.v2, v4: r.v3}
+}
+
+// OneOf5 T1 | T2 | T3 | T4 | T5 之一
+type OneOf5[T1, T2, T3, T4, T5 any] struct {
+	idx int
+	v1  T1
+	v2  T2
+	v3  T3
+	v4  T4
+	v5  T5
+}
+
+func Case1Of5[T1, T2, T3, T4, T5 any](v T1) OneOf5[T1, T2, T3, T4, T5] {
+	return OneOf5[T1, T2, T3, T4, T5]{idx: 1, v1: v}
+}
+func Case2Of5[T1, T2, T3, T4, T5 any](v T2) OneOf5[T1, T2, T3, T4, T5] {
+	return OneOf5[T1, T2, T3, T4, T5]{idx: 2, v2: v}
+}
+func Case3Of5[T1, T2, T3, T4, T5 any](v T3) OneOf5[T1, T2, T3, T4, T5] {
+	return OneOf5[T1, T2, T3, T4, T5]{idx: 3, v3: v}
+}
+func Case4Of5[T1, T2, T3, T4, T5 any](v T4) OneOf5[T1, T2, T3, T4, T5] {
+	return OneOf5[T1, T2, T3, T4, T5]{idx: 4, v4: v}
+}
+func Case5Of5[T1, T2, T3, T4, T5 any](v T5) OneOf5[T1, T2, T3, T4, T5] {
+	return OneOf5[T1, T2, T3, T4, T5]{idx: 5, v5: v}
+}
+
+// Index 值的类型序号 1 ~ 5
+func (o OneOf5[T1, T2, T3, T4, T5]) Index() int     { return o.idx }
+func (o OneOf5[T1, T2, T3, T4, T5]) V1() (T1, bool) { return o.v1, o.idx == 1 }
+func (o OneOf5[T1, T2, T3, T4, T5]) V2() (T2, bool) { return o.v2, o.idx == 2 }
+func (o OneOf5[T1, T2, T3, T4, T5]) V3() (T3, bool) { return o.v3, o.idx == 3 }
+func (o OneOf5[T1, T2, T3, T4, T5]) V4() (T4, bool) { return o.v4, o.idx == 4 }
+func (o OneOf5[T1, T2, T3, T4, T5]) V5() (T5, bool) { return o.v5, o.idx == 5 }
+
+// Match 按值的类型调用对应的函数
+func (o OneOf5[T1, T2, T3, T4, T5]) Match(f1 func(T1), f2 func(T2), f3 func(T3), f4 func(T4), f5 func(T5)) {
+	switch o.idx {
+	case 1:
+		f1(o.v1)
+	case 2:
+		f2(o.v2)
+	case 3:
+		f3(o.v3)
+	case 4:
+		f4(o.v4)
+	case 5:
+		f5(o.v5)
+	default:
+		panic("OneOf5: zero value")
+	}
+}
+
+func (o OneOf5[T1, T2, T3, T4, T5]) String() string {
+	switch o.idx {
+	case 1:
+		return fmt.Sprintf("%v", o.v1)
+	case 2:
+		return fmt.Sprintf("%v", o.v2)
+	case 3:
+		return fmt.Sprintf("%v", o.v3)
+	case 4:
+		return fmt.Sprintf("%v", o.v4)
+	case 5:
+		return fmt.Sprintf("%v", o.v5)
+	default:
+		return "<nil>"
+	}
+}
+
+// Fold5 按值的类型调用对应的函数, 返回其结果
+func Fold5[T1, T2, T3, T4, T5, R any](o OneOf5[T1, T2, T3, T4, T5], f1 func(T1) R, f2 func(T2) R, f3 func(T3) R, f4 func(T4) R, f5 func(T5) R) R {
+	switch o.idx {
+	case 1:
+		return f1(o.v1)
+	case 2:
+		return f2(o.v2)
+	case 3:
+		return f3(o.v3)
+	case 4:
+		return f4(o.v4)
+	case 5:
+		return f5(o.v5)
+	default:
+		panic("OneOf5: zero value")
+	}
+}
+
+// FromEither5 转换 Alt5 返回的嵌套 Either
+func FromEither5[T1, T2, T3, T4, T5 any](e Either[T1, Either[T2, Either[T3, Either[T4, T5]]]]) OneOf5[T1, T2, T3, T4, T5] {
+	if e.IsLeft() {
+		return Case1Of5[T1, T2, T3, T4, T5](e.Left)
+	}
+	r := FromEither4(e.Right)
+	return OneOf5[T1, T2, T3, T4, T5]{idx: r.idx + 1, v2: r.v1, v3: r.v2, v4: r.v3, v5: r.v4}
+}
@@ -0,0 +1,79 @@
+package parsec
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestOneOf(t *testing.T) {
+	num := Apply(Tok(Number), func(t token) int { n, _ := strconv.Atoi(t.Lexeme()); return n })
+	id := Apply(Tok(Ident), token.Lexeme)
+	add := Apply(Tok(Add), func(token) bool { return true })
+	show := func(v OneOf3[int, string, bool]) string {
+		return Fold3(v,
+			func(n int) string { return fmt.Sprintf("num %d", n) },
+			func(s string) string { return "id " + s },
+			func(bool) string { return "add" },
+		)
+	}
+
+	for _, tt := range []struct {
+		input  string
+		expect string
+		index  int
+	}{
+		{"1", "num 1", 1},
+		{"abc", "id abc", 2},
+		{"+", "add", 3},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			for _, p := range []Parser[tokKind, OneOf3[int, string, bool]]{AltOf3(num, id, add), AltScOf3(num, id, add)} {
+				out := p.Parse(mustLex(tt.input))
+				if !out.Success || len(out.Candidates) != 1 {
+					t.Fatalf("expect 1 candidate actual %v", out)
+				}
+				v := out.Candidates[0].Val
+				if actual := show(v); actual != tt.expect {
+					t.Errorf("expect %s actual %s", tt.expect, actual)
+				}
+				if v.Index() != tt.index {
+					t.Errorf("expect index %d actual %d", tt.index, v.Index())
+				}
+				matched := 0
+				v.Match(func(int) { matched = 1 }, func(string) { matched = 2 }, func(bool) { matched = 3 })
+				if matched != tt.index {
+					t.Errorf("expect match %d actual %d", tt.index, matched)
+				}
+			}
+		})
+	}
+}
+
+func TestFromEither(t *testing.T) {
+	e := Right[int, Either[string, Either[bool, float64]]](Right[string, Either[bool, float64]](Left[bool, float64](true)))
+	v := FromEither4(e)
+	if b, ok := v.V3(); v.Index() != 3 || !ok || !b {
+		t.Errorf("expect case 3 true actual %d %v", v.Index(), v)
+	}
+	if _, ok := v.V1(); ok {
+		t.Errorf("expect not case 1")
+	}
+	if v.String() != "true" {
+		t.Errorf("expect true actual %s", v)
+	}
+
+	if v := FromEither2(Left[int, string](1)); v.Index() != 1 {
+		t.Errorf("expect case 1 actual %d", v.Index())
+	}
+	if v := FromEither5(Right[int](Right[int](Right[int](Right[int, int](5))))); v.Index() != 5 || v.String() != "5" {
+		t.Errorf("expect case 5 actual %d %v", v.Index(), v)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expect zero value panic")
+		}
+	}()
+	Fold2(OneOf2[int, int]{}, func(int) int { return 1 }, func(int) int { return 2 })
+}
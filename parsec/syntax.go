@@ -0,0 +1,150 @@
+package parsec
+
+// ----------------------------------------------------------------
+// Syntax Extension, 解析过程中扩展语法
+// ----------------------------------------------------------------
+
+// Syntax 可扩展的语法点, 候选为基础候选与当前作用域内激活的扩展(后激活的在前);
+// 扩展通过 Scoped 在解析过程中激活, 只作用于其 body, 回溯时自然撤销;
+// 激活的扩展记录在每次解析的 State 中, 同一个 Syntax 可以并发使用
+type Syntax[K TK, R any] struct {
+	base []Parser[K, R]
+	alt  Parser[K, R] // 基础候选, 没有激活的扩展时使用
+}
+
+func NewSyntax[K TK, R any](ps ...Parser[K, R]) *Syntax[K, R] {
+	return &Syntax[K, R]{base: ps, alt: Alt(ps...)}
+}
+
+// Extension 语法扩展, 在 st 中激活, 返回撤销函数
+type Extension[K TK] func(st *State[K]) (undo func())
+
+// scope 解析过程中激活的扩展, 内层在前
+type scope struct {
+	syntax any // *Syntax[K, R]
+	alt    any // Parser[K, R], 扩展与外层的候选
+	outer  *scope
+}
+
+// Add 向 s 添加候选的扩展
+func (s *Syntax[K, R]) Add(ps ...Parser[K, R]) Extension[K] {
+	ext := Alt(ps...)
+	// 最常见的情况, 外层没有激活的扩展
+	top := Alt(append(append([]Parser[K, R]{}, ps...), s.base...)...)
+	return func(st *State[K]) func() {
+		old := st.scopes
+		alt := top
+		if outer, ok := s.lookup(st); ok {
+			alt = Alt(ext, outer)
+		}
+		st.scopes = &scope{syntax: s, alt: alt, outer: old}
+		return func() { st.scopes = old }
+	}
+}
+
+// Extensions 组合多个扩展, 按顺序激活, 逆序撤销
+func Extensions[K TK](xs ...Extension[K]) Extension[K] {
+	return func(st *State[K]) func() {
+		undos := make([]func(), len(xs))
+		for i, x := range xs {
+			undos[i] = x(st)
+		}
+		return func() {
+			for i := len(undos) - 1; i >= 0; i-- {
+				undos[i]()
+			}
+		}
+	}
+}
+
+// lookup st 中 s 最内层激活的候选
+func (s *Syntax[K, R]) lookup(st *State[K]) (Parser[K, R], bool) {
+	for sc := st.scopes; sc != nil; sc = sc.outer {
+		if sc.syntax == s {
+			return sc.alt.(Parser[K, R]), true
+		}
+	}
+	return nil, false
+}
+
+func (s *Syntax[K, R]) current(st *State[K]) Parser[K, R] {
+	if p, ok := s.lookup(st); ok {
+		return p
+	}
+	return s.alt
+}
+
+func (s *Syntax[K, R]) Parse(toks []Token[K]) Output[K, R] {
+	return s.parseIn(new(State[K]), toks)
+}
+
+func (s *Syntax[K, R]) parseIn(st *State[K], toks []Token[K]) Output[K, R] {
+	return ParseIn(st, s.current(st), toks)
+}
+
+func (s *Syntax[K, R]) Enum(toks []Token[K], yield func(Result[K, R]) bool) *Error {
+	return s.enumIn(new(State[K]), toks, yield)
+}
+
+func (s *Syntax[K, R]) enumIn(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+	return EnumIn(st, s.current(st), toks, yield)
+}
+
+// Parser 同 SyntaxRule.Parser, 方便类型推导
+func (s *Syntax[K, R]) Parser() Parser[K, R] {
+	return s
+}
+
+// Scoped :: p[d] -> (d -> ext) -> p[r] -> p[(d, r)]
+// decl 成功后激活 ext(d), 在其作用域内解析 body, body 结束后撤销;
+// body 通常为文件或块的剩余部分, e.g. Scoped(infixDecl, addOper, stmts) 声明的操作符作用于后续语句;
+// 每个 decl 候选各自激活与撤销, 所以失败的分支中的扩展不会影响其他分支
+func Scoped[K TK, D, R any](decl Parser[K, D], ext func(D) Extension[K], body Parser[K, R]) Parser[K, Cons[D, R]] {
+	parseBody := func(st *State[K], step Result[K, D]) Output[K, R] {
+		defer ext(step.Val)(st)()
+		return ParseIn(st, body, step.next)
+	}
+	return withEnum(parser[K, Cons[D, R]](func(st *State[K], toks []Token[K]) Output[K, Cons[D, R]] {
+		out1 := ParseIn(st, decl, toks)
+		if !out1.Success {
+			return failOf[K, D, Cons[D, R]](out1)
+		}
+		var xs []Result[K, Cons[D, R]]
+		err := out1.Error
+		for _, step := range out1.Candidates {
+			out2 := parseBody(st, step)
+			err = betterError(err, out2.Error)
+			if out2.Success {
+				for _, candidate := range out2.Candidates {
+					xs = append(xs, Result[K, Cons[D, R]]{
+						Val:   Cons[D, R]{Car: step.Val, Cdr: candidate.Val},
+						next:  candidate.next,
+						nodes: step.nodes.concat(candidate.nodes),
+					})
+				}
+			}
+		}
+		return newOutput(xs, err, len(xs) != 0)
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, Cons[D, R]]) bool) *Error {
+		var err *Error
+		err = betterError(err, EnumIn(st, decl, toks, func(step Result[K, D]) bool {
+			cont := true
+			activate := ext(step.Val)
+			undo := activate(st)
+			defer func() { undo() }()
+			err = betterError(err, EnumIn(st, body, step.next, func(r Result[K, R]) bool {
+				// yield 之后的解析在作用域之外
+				undo()
+				defer func() { undo = activate(st) }()
+				cont = yield(Result[K, Cons[D, R]]{
+					Val:   Cons[D, R]{Car: step.Val, Cdr: r.Val},
+					next:  r.next,
+					nodes: step.nodes.concat(r.nodes),
+				})
+				return cont
+			}))
+			return cont
+		}))
+		return err
+	})
+}
@@ -0,0 +1,124 @@
+package parsec
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSyntax(t *testing.T) {
+	lexeme := func(t Token[benchKind]) string { return t.Lexeme() }
+	// item = <num> | 已声明的 <str>
+	// prog = ':' <str> prog | item prog | '{' prog '}' prog | ε
+	item := NewSyntax(Apply(Tok(bNum), lexeme))
+	declare := func(s Token[benchKind]) Extension[benchKind] {
+		return item.Add(Apply(Str[benchKind](s.Lexeme()), lexeme))
+	}
+	decl := KRight(op(":"), Tok(bStr))
+
+	prog := NewRule[benchKind, []string]()
+	block := Apply(KMid(op("{"), prog.Parser(), op("}")), func(xs []string) string { return fmt.Sprint(xs) })
+	prepend := func(c Cons[string, []string]) []string { return append([]string{c.Car}, c.Cdr...) }
+	prog.Pattern = Alt(
+		Apply(Scoped(decl, declare, prog.Parser()), func(c Cons[Token[benchKind], []string]) []string { return c.Cdr }),
+		Apply(Seq2(item.Parser(), prog.Parser()), prepend),
+		Apply(Seq2(block, prog.Parser()), prepend),
+		Succ[benchKind, []string](nil),
+	)
+
+	for _, tt := range []struct {
+		input  string
+		expect string
+	}{
+		{`1 : "a" "a" 2`, `[1 "a" 2]`},
+		{`: "a" : "b" "b" "a"`, `["b" "a"]`},
+		{`"a"`, "Unable to consume token `\"a\"` expect `:`"},
+		// 作用域为块的剩余部分
+		{`{ : "a" "a" } 1`, `[["a"] 1]`},
+		{`{ : "a" "a" } "a"`, "Unable to consume token `\"a\"` expect `:`"},
+		// 块内可以使用外层的声明
+		{`: "a" { "a" }`, `[["a"]]`},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			toks := benchLex(tt.input)
+			v, err := ExpectSingleResult(ExpectEOF(prog.Parse(toks)))
+			actual := fmt.Sprint(v)
+			if err != nil {
+				actual = err.(*Error).Msg
+			}
+			if actual != tt.expect {
+				t.Errorf("expect %s actual %s", tt.expect, actual)
+			}
+
+			var xs []string
+			Enum[benchKind, []string](prog, toks, func(r Result[benchKind, []string]) bool {
+				if len(r.next) == 0 {
+					xs = append(xs, fmt.Sprint(r.Val))
+				}
+				return true
+			})
+			if err == nil && fmt.Sprint(xs) != "["+tt.expect+"]" {
+				t.Errorf("expect [%s] actual %v", tt.expect, xs)
+			}
+			if out := item.Parse(benchLex(`"a"`)); out.Success {
+				t.Errorf("expect extensions undone actual %v", out)
+			}
+		})
+	}
+}
+
+func TestSyntaxBacktrack(t *testing.T) {
+	lexeme := func(t Token[benchKind]) string { return t.Lexeme() }
+	item := NewSyntax(Apply(Tok(bNum), lexeme))
+	declare := func(s Token[benchKind]) Extension[benchKind] {
+		return item.Add(Apply(Str[benchKind](s.Lexeme()), lexeme))
+	}
+	decl := KRight(op(":"), Tok(bStr))
+
+	// 第一个分支声明后失败, 第二个分支不受其声明的影响
+	p := Alt(
+		Apply(KLeft(Scoped(decl, declare, item.Parser()), op("^")), func(c Cons[Token[benchKind], string]) string { return c.Cdr }),
+		Apply(Seq2(decl, item.Parser()), func(c Cons[Token[benchKind], string]) string { return c.Cdr }),
+	)
+	if out := p.Parse(benchLex(`: "a" "a"`)); out.Success {
+		t.Errorf("expect fail actual %v", out)
+	}
+	if out := p.Parse(benchLex(`: "a" "a" ^`)); !out.Success {
+		t.Errorf("expect success actual %v", out)
+	}
+	if out := p.Parse(benchLex(`: "a" 1`)); !out.Success || len(out.Candidates) != 1 {
+		t.Errorf("expect 1 candidate actual %v", out)
+	}
+}
+
+// 激活的扩展属于每次解析, 并发的解析互不影响
+func TestSyntaxConcurrent(t *testing.T) {
+	lexeme := func(t Token[benchKind]) string { return t.Lexeme() }
+	item := NewSyntax(Apply(Tok(bNum), lexeme))
+	declare := func(s Token[benchKind]) Extension[benchKind] {
+		return item.Add(Apply(Str[benchKind](s.Lexeme()), lexeme))
+	}
+	p := Apply(Scoped(KRight(op(":"), Tok(bStr)), declare, item.Parser()), func(c Cons[Token[benchKind], string]) string { return c.Cdr })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		// lexer 不能并发使用
+		s := fmt.Sprintf("%q", fmt.Sprint(i))
+		scoped, bare := benchLex(": "+s+" "+s), benchLex(s)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if v, err := ExpectSingleResult(ExpectEOF(p.Parse(scoped))); err != nil || v != s {
+					t.Errorf("expect %s actual %v %v", s, v, err)
+					return
+				}
+				if out := item.Parse(bare); out.Success {
+					t.Errorf("expect fail actual %v", out)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
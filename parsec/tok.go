@@ -28,19 +28,27 @@ type Token[K TK] interface {
 	String() string
 }
 
+// virtualToken 不来自 lexer 的 token, e.g. EOFToken, Repair 插入或替换的 token;
+// 值类型, 可以用 == 比较, e.g. tok == EOFToken[K]()
 type virtualToken[K TK] struct {
-	VirtualPos
+	Pos  // VirtualToken 为 VirtualPos, Repair 为插入处原 token 的位置, 以便与其他错误比较远近
+	kind K
 	name string
+	seq  int // Repair 中的序号, 文本与位置相同的两个插入的 token 也互不相等, 以便 tokenRange 区分
 }
 
-func (virtualToken[K]) Kind() K          { return *new(K) }
+func (v virtualToken[K]) Kind() K        { return v.kind }
 func (v virtualToken[K]) Lexeme() string { return v.name }
 func (v virtualToken[K]) String() string { return v.name }
 
 func EOFToken[K TK]() Token[K] {
-	return VirtualToken[K]("<EOF>", EOFPos)
+	return eofToken[K]()
+}
+
+func eofToken[K TK]() virtualToken[K] {
+	return virtualToken[K]{Pos: EOFPos, name: "<EOF>"}
 }
 
 func VirtualToken[K TK](name string, pos VirtualPos) Token[K] {
-	return virtualToken[K]{pos, name}
+	return virtualToken[K]{Pos: pos, name: name}
 }
@@ -21,25 +21,33 @@ func EnableTrace(f func(), errF func(error) string) {
 }
 
 func Trace[K TK, R any](name string, p Parser[K, R]) Parser[K, R] {
-	return parser[K, R](func(toks []Token[K]) Output[K, R] {
-		if traceFlag {
-			// fmt.Println(toks)
-			fmt.Printf("[%-3d] %s\n", num, name)
+	return withEnum(parser[K, R](func(st *State[K], toks []Token[K]) Output[K, R] {
+		if !traceFlag {
+			// 未开启时不修改计数, 解析可以并发
+			return ParseIn(st, p, toks)
 		}
+		// fmt.Println(toks)
+		fmt.Printf("[%-3d] %s\n", num, name)
 		num++
-		out := p.Parse(toks)
+		out := ParseIn(st, p, toks)
 		num--
-		if traceFlag {
-			if out.Success {
-				fmt.Printf("[%-3d] Success(%v)\n", num, out.Candidates)
+		if out.Success {
+			fmt.Printf("[%-3d] Success(%v)\n", num, out.Candidates)
+		} else {
+			if errFmt == nil {
+				fmt.Printf("[%-3d] Error(%v)\n", num, out.Error)
 			} else {
-				if errFmt == nil {
-					fmt.Printf("[%-3d] Error(%v)\n", num, out.Error)
-				} else {
-					fmt.Printf("[%-3d] %s\n", num, errFmt(out.Error))
-				}
+				fmt.Printf("[%-3d] %s\n", num, errFmt(out.Error))
 			}
 		}
 		return out
+	}), func(st *State[K], toks []Token[K], yield func(Result[K, R]) bool) *Error {
+		if !traceFlag {
+			return EnumIn(st, p, toks, yield)
+		}
+		fmt.Printf("[%-3d] %s (enum)\n", num, name)
+		num++
+		defer func() { num-- }()
+		return EnumIn(st, p, toks, yield)
 	})
 }
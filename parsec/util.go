@@ -50,6 +50,8 @@ type Option[T any] struct {
 func Some[T any](v T) Option[T] { return Option[T]{ok: true, V: v} }
 func None[T any]() Option[T]    { return Option[T]{} }
 
+func (o Option[T]) IsSome() bool { return o.ok }
+
 // ----------------------------------------------------------------
 // Output
 // ----------------------------------------------------------------
@@ -61,7 +63,7 @@ func success[K TK, R any](xs []Result[K, R]) Output[K, R] {
 	return Output[K, R]{Success: true, Candidates: xs}
 }
 func successWithErr[K TK, R any](xs []Result[K, R], err *Error) Output[K, R] {
-	return Output[K, R]{true, xs, err}
+	return Output[K, R]{Success: true, Candidates: xs, Error: err}
 }
 func newOutput[K TK, R any](xs []Result[K, R], err *Error, success bool) Output[K, R] {
 	if success {
@@ -74,8 +76,9 @@ func newOutput[K TK, R any](xs []Result[K, R], err *Error, success bool) Output[
 func resultOf[K TK, TFrom, TTo any](f func(TFrom) TTo) func(from Result[K, TFrom]) Result[K, TTo] {
 	return func(from Result[K, TFrom]) Result[K, TTo] {
 		return Result[K, TTo]{
-			Val:  f(from.Val),
-			next: from.next,
+			Val:   f(from.Val),
+			next:  from.next,
+			nodes: from.nodes,
 		}
 	}
 }
@@ -95,16 +98,29 @@ func newError(pos Pos, msg string) *Error {
 }
 func unableToConsumeToken[K TK](tok Token[K], expect string) *Error {
 	var pos Pos = tok
+	eof := false
 	if vt, ok := tok.(virtualToken[K]); ok {
-		pos = vt.VirtualPos
+		if vp, ok := vt.Pos.(VirtualPos); ok {
+			pos = vp
+		}
+		// 只有 EOFToken 表示没有 token, Repair 在输入末尾插入的 token 位置同样为 EOFPos
+		eof = vt == eofToken[K]()
 	}
-	if pos == EOFPos {
+	if eof {
 		return newError(pos, "Nothing to consume expect `"+expect+"`")
 	} else {
 		return newError(pos, "Unable to consume token `"+tok.String()+"` expect `"+expect+"`")
 	}
 }
 
+// farthest 解析到的最远位置, 语义错误(ApplyE)覆盖一段 token, 按其后的位置比较
+func (e *Error) farthest() Pos {
+	if r := e.more().reach; r != nil {
+		return r
+	}
+	return e.Pos
+}
+
 // 返回最远的错误
 func betterError(e1, e2 *Error) *Error {
 	if e1 == nil {
@@ -113,17 +129,25 @@ func betterError(e1, e2 *Error) *Error {
 	if e2 == nil {
 		return e1
 	}
-	if e1.Pos == EOFPos {
+	p1, p2 := e1.farthest(), e2.farthest()
+	if p1 == EOFPos {
+		if p2 == EOFPos {
+			return mergeExpects(e1, e2)
+		}
 		return e1
 	}
-	if e2.Pos == EOFPos {
+	if p2 == EOFPos {
 		return e2
 	}
-	idx1, _, _, _ := e1.Loc()
-	idx2, _, _, _ := e2.Loc()
+	idx1, _, _, _ := p1.Loc()
+	idx2, _, _, _ := p2.Loc()
 	if idx1 < idx2 {
 		return e2
 	}
+	if idx1 == idx2 {
+		// 同一位置, 合并期望, 见 Expected
+		return mergeExpects(e1, e2)
+	}
 	return e1
 }
 